@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	mangolog "github.com/bitstep-ie/mango-go/pkg/logger"
+)
+
+// decryptOptions are the flags of the decrypt command
+type decryptOptions struct {
+	inputOptions
+	keyFile string
+}
+
+func runDecrypt(args []string, stdout io.Writer, stderr io.Writer) error {
+	opts := decryptOptions{}
+	flags := newFlagSet("mangolog decrypt", "mangolog decrypt -key-file keys [flags] [file|glob ...]", &opts.inputOptions, stderr)
+	flags.StringVar(&opts.keyFile, "key-file", "", "file of the keys, one <key id>=<base64 key> per line")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if opts.keyFile == "" {
+		return errors.New("missing -key-file")
+	}
+	keys, err := readKeyFile(opts.keyFile)
+	if err != nil {
+		return err
+	}
+
+	match, err := opts.filter(time.Now())
+	if err != nil {
+		return err
+	}
+	files, err := expandInputs(flags.Args(), opts.rotated)
+	if err != nil {
+		return err
+	}
+
+	_, err = readInputs(files, func(line []byte) error {
+		e, ok := parseEntry(line)
+		if !ok || !match.match(e) {
+			return nil
+		}
+		decrypted, err := mangolog.DecryptLogLine(line, keys)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "mangolog: failed to decrypt entry: %s\n", err.Error())
+		}
+		_, err = fmt.Fprintln(stdout, string(decrypted))
+		return err
+	})
+	return err
+}
+
+// readKeyFile reads the <key id>=<base64 key> lines, skipping the empty lines and the # comments
+func readKeyFile(name string) (mangolog.StaticKeyProvider, error) {
+	keys := mangolog.StaticKeyProvider{Keys: map[string][]byte{}}
+	file, err := os.Open(name)
+	if err != nil {
+		return keys, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(line, "=")
+		if !ok || id == "" {
+			return keys, fmt.Errorf("%s:%d: expected <key id>=<base64 key>", name, n)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return keys, fmt.Errorf("%s:%d: invalid key: %w", name, n, err)
+		}
+		keys.Keys[id] = key
+	}
+	return keys, scanner.Err()
+}
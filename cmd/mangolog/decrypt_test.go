@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	mangolog "github.com/bitstep-ie/mango-go/pkg/logger"
+)
+
+func writeEncryptedLogs(t *testing.T, key []byte) string {
+	name := filepath.Join(t.TempDir(), "app.log")
+	logger := slog.New(mangolog.NewMangoLogger(&mangolog.LogConfig{
+		Out: &mangolog.OutConfig{
+			Enabled: true,
+			File:    &mangolog.FileOutputConfig{Enabled: true, Path: name},
+			Cli:     &mangolog.CliConfig{},
+			Syslog:  &mangolog.SyslogConfig{},
+		},
+		MangoConfig: &mangolog.MangoConfig{
+			CorrelationId: &mangolog.CorrelationIdConfig{AutoGenerate: true},
+			Encryption: &mangolog.EncryptionConfig{
+				Enabled:     true,
+				Paths:       []string{"user.email"},
+				KeyProvider: mangolog.StaticKeyProvider{CurrentId: "k1", Keys: map[string][]byte{"k1": key}},
+			},
+		},
+	}))
+	ctx := context.WithValue(context.Background(), mangolog.TYPE, mangolog.SecurityType)
+	logger.InfoContext(ctx, "login", slog.Group("user", "email", "ann@example.com", "id", 7))
+	logger.WarnContext(ctx, "plain", "count", 1)
+	return name
+}
+
+func writeKeyFile(t *testing.T, content string) string {
+	name := filepath.Join(t.TempDir(), "keys")
+	assert.NoError(t, os.WriteFile(name, []byte(content), 0o600))
+	return name
+}
+
+func TestRunDecrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	logs := writeEncryptedLogs(t, key)
+	content, _ := os.ReadFile(logs)
+	assert.NotContains(t, string(content), "ann@example.com")
+
+	keyFile := writeKeyFile(t, "# current\n\nk1="+base64.StdEncoding.EncodeToString(key)+"\n")
+	code, stdout, stderr := runTest(t, "decrypt", "-key-file", keyFile, logs)
+	assert.Equal(t, 0, code)
+	assert.Empty(t, stderr)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"attributes":{"user":{"email":"ann@example.com","id":7}}`)
+	assert.Contains(t, lines[1], `"attributes":{"count":1}`)
+
+	code, stdout, _ = runTest(t, "decrypt", "-key-file", keyFile, "-level", "warn", logs)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, 1, strings.Count(stdout, "\n"))
+}
+
+func TestRunDecrypt_UnknownKey(t *testing.T) {
+	logs := writeEncryptedLogs(t, bytes.Repeat([]byte{7}, 32))
+	keyFile := writeKeyFile(t, "k2="+base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{8}, 32)))
+
+	code, stdout, stderr := runTest(t, "decrypt", "-key-file", keyFile, logs)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stderr, `mangolog: failed to decrypt entry: user.email: key not found: "k1"`)
+	assert.Contains(t, stdout, `"email":"enc:v1:k1:`)
+}
+
+func TestRunDecrypt_InvalidArgs(t *testing.T) {
+	for want, args := range map[string][]string{
+		"missing -key-file":              {"decrypt"},
+		"no such file or directory":      {"decrypt", "-key-file", "/nonexistent/keys"},
+		"expected <key id>=<base64 key>": {"decrypt", "-key-file", writeKeyFile(t, "nokey")},
+		"keys:1: invalid key":            {"decrypt", "-key-file", writeKeyFile(t, "k1=%%%")},
+		"invalid -level":                 {"decrypt", "-key-file", writeKeyFile(t, ""), "-level", "loud"},
+	} {
+		code, _, stderr := runTest(t, args...)
+		assert.Equal(t, 1, code, want)
+		assert.Contains(t, stderr, want)
+	}
+}
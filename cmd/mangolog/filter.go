@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	mangolog "github.com/bitstep-ie/mango-go/pkg/logger"
+	"github.com/itchyny/gojq"
+)
+
+// maxLineSize is the longest log line read, longer lines are reported as errors
+const maxLineSize = 16 * 1024 * 1024
+
+// entry is a parsed mango log line
+type entry struct {
+	raw    []byte
+	fields map[string]interface{}
+	ts     time.Time
+	level  slog.Level
+}
+
+// parseEntry returns false for lines that are not mango json logs
+func parseEntry(line []byte) (*entry, bool) {
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return nil, false
+	}
+	e := &entry{raw: line, fields: fields}
+	e.ts, _ = time.Parse(mangolog.RFC3339NanoMC, e.str("ts"))
+	if err := e.level.UnmarshalText([]byte(e.str("level"))); err != nil {
+		e.level = slog.LevelInfo
+	}
+	return e, true
+}
+
+// str returns the string field, or "" when missing or not a string
+func (e *entry) str(name string) string {
+	value, _ := e.fields[name].(string)
+	return value
+}
+
+// filter holds the criteria an entry must all match
+type filter struct {
+	minLevel      *slog.Level
+	types         []string
+	operations    []string
+	correlationId string
+	since         time.Time
+	until         time.Time
+}
+
+func (f *filter) match(e *entry) bool {
+	if f.minLevel != nil && e.level < *f.minLevel {
+		return false
+	}
+	if len(f.types) > 0 && !slices.Contains(f.types, e.str("type")) {
+		return false
+	}
+	if len(f.operations) > 0 && !slices.Contains(f.operations, e.str("operation")) {
+		return false
+	}
+	if f.correlationId != "" && e.str("correlationid") != f.correlationId {
+		return false
+	}
+	if !f.since.IsZero() && (e.ts.IsZero() || e.ts.Before(f.since)) {
+		return false
+	}
+	if !f.until.IsZero() && (e.ts.IsZero() || !e.ts.Before(f.until)) {
+		return false
+	}
+	return true
+}
+
+// parseList splits a comma separated flag value, dropping empty items
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseTime accepts an absolute time (RFC3339 or the mango timestamp format) or a duration back from now, e.g. 15m
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, mangolog.RFC3339NanoMC, time.DateTime, time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected RFC3339, %q or a duration such as 15m", value, time.DateTime)
+}
+
+// formatter prints entries with a jq expression compiled once, or as they are
+type formatter struct {
+	code *gojq.Code
+}
+
+// newFormatter compiles the jq expression, an empty expression prints the raw json lines
+func newFormatter(query string) (*formatter, error) {
+	if query == "" {
+		return &formatter{}, nil
+	}
+	parsed, err := gojq.Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jq expression: %w", err)
+	}
+	code, err := gojq.Compile(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jq expression: %w", err)
+	}
+	return &formatter{code: code}, nil
+}
+
+// format returns one output line per jq result, strings are printed raw as with jq -r
+func (f *formatter) format(e *entry) ([]string, error) {
+	if f.code == nil {
+		return []string{string(e.raw)}, nil
+	}
+	var lines []string
+	iter := f.code.Run(e.fields)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			return lines, nil
+		}
+		if err, ok := v.(error); ok {
+			return lines, err
+		}
+		if s, ok := v.(string); ok {
+			lines = append(lines, s)
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return lines, err
+		}
+		lines = append(lines, string(b))
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEntry(t *testing.T) {
+	e, ok := parseEntry([]byte(`{"ts":"2025-01-15T09:53:34.717Z","level":"WARN","type":"Business"}`))
+	assert.True(t, ok)
+	assert.Equal(t, slog.LevelWarn, e.level)
+	assert.Equal(t, time.Date(2025, 1, 15, 9, 53, 34, 717000000, time.UTC), e.ts.UTC())
+	assert.Equal(t, "Business", e.str("type"))
+	assert.Equal(t, "", e.str("missing"))
+
+	_, ok = parseEntry([]byte("plain text"))
+	assert.False(t, ok)
+}
+
+func TestFilter_Match(t *testing.T) {
+	warn := slog.LevelWarn
+	e, _ := parseEntry([]byte(`{"ts":"2025-01-15T09:53:34.717Z","level":"ERROR","type":"Security","operation":"login","correlationid":"abc"}`))
+	at := time.Date(2025, 1, 15, 9, 53, 34, 717000000, time.UTC)
+
+	tests := []struct {
+		name   string
+		filter filter
+		want   bool
+	}{
+		{"no criteria", filter{}, true},
+		{"level", filter{minLevel: &warn}, true},
+		{"type", filter{types: []string{"Business", "Security"}}, true},
+		{"other type", filter{types: []string{"Business"}}, false},
+		{"operation", filter{operations: []string{"logout"}}, false},
+		{"correlation id", filter{correlationId: "abc"}, true},
+		{"other correlation id", filter{correlationId: "xyz"}, false},
+		{"since inclusive", filter{since: at}, true},
+		{"until exclusive", filter{until: at}, false},
+		{"window", filter{since: at.Add(-time.Minute), until: at.Add(time.Minute)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.match(e))
+		})
+	}
+
+	info := slog.LevelInfo
+	debug, _ := parseEntry([]byte(`{"level":"DEBUG"}`))
+	assert.False(t, (&filter{minLevel: &info}).match(debug))
+	assert.False(t, (&filter{since: at}).match(debug), "entries without a timestamp never match a time window")
+}
+
+func TestParseList(t *testing.T) {
+	assert.Equal(t, []string{"a", "b"}, parseList(" a, ,b,"))
+	assert.Nil(t, parseList(""))
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	got, err := parseTime("15m", now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(-15*time.Minute), got)
+
+	got, err = parseTime("2025-01-15T09:00:00Z", now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(-time.Hour), got)
+
+	got, err = parseTime("2025-01-15T09:53:34.717-0500", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 15, 14, 53, 34, 717000000, time.UTC), got.UTC())
+
+	got, err = parseTime("2025-01-15", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 15, 0, 0, 0, 0, time.Local), got)
+
+	got, err = parseTime("", now)
+	assert.NoError(t, err)
+	assert.True(t, got.IsZero())
+
+	_, err = parseTime("yesterday", now)
+	assert.Error(t, err)
+}
+
+func TestFormatter(t *testing.T) {
+	e, _ := parseEntry([]byte(`{"message":"hi","attributes":{"n":1}}`))
+
+	raw, err := newFormatter("")
+	assert.NoError(t, err)
+	lines, err := raw.format(e)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"message":"hi","attributes":{"n":1}}`}, lines)
+
+	jq, err := newFormatter(".message, .attributes")
+	assert.NoError(t, err)
+	lines, err = jq.format(e)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hi", `{"n":1}`}, lines)
+
+	failing, err := newFormatter(".message | error")
+	assert.NoError(t, err)
+	_, err = failing.format(e)
+	assert.Error(t, err)
+
+	_, err = newFormatter(".message |")
+	assert.Error(t, err)
+}
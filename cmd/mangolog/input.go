@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	mangolog "github.com/bitstep-ie/mango-go/pkg/logger"
+)
+
+// stdinName is the input name reading from stdin
+const stdinName = "-"
+
+// followPollInterval is how often a followed file is checked for new lines or rotation
+var followPollInterval = 250 * time.Millisecond
+
+// expandInputs resolves the globs and adds the rotated backups of each file when asked to
+// The files are returned oldest first, ordered on the timestamp of their first entry
+func expandInputs(args []string, withRotated bool) ([]string, error) {
+	if len(args) == 0 {
+		return []string{stdinName}, nil
+	}
+
+	var files []string
+	for _, arg := range args {
+		if arg == stdinName {
+			if len(args) > 1 {
+				return nil, fmt.Errorf("stdin can't be combined with other inputs")
+			}
+			return []string{stdinName}, nil
+		}
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no such file: %s", arg)
+		}
+		files = append(files, matches...)
+		if withRotated {
+			for _, match := range matches {
+				files = append(files, rotatedBackups(match)...)
+			}
+		}
+	}
+	slices.Sort(files)
+	files = slices.Compact(files)
+
+	starts := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		starts[file] = firstTimestamp(file)
+	}
+	slices.SortStableFunc(files, func(a, b string) int { return starts[a].Compare(starts[b]) })
+	return files, nil
+}
+
+// rotatedStamp matches lumberjack's 2006-01-02T15-04-05.000 and mango's daily, hourly and on-demand stamps, with the _<n> of a clash
+var rotatedStamp = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(T\d{2}(-\d{2}-\d{2}(\.\d{3})?)?)?(_\d+)?$`)
+
+// rotatedBackups finds the lumberjack and mango rotated files of name: <prefix>-<stamp><ext>[.gz]
+// Other files sharing the prefix, e.g. the app-security.log route of app.log, are not backups
+func rotatedBackups(name string) []string {
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext)
+	matches, _ := filepath.Glob(prefix + "-*" + ext)
+	compressed, _ := filepath.Glob(prefix + "-*" + ext + ".gz")
+	return slices.DeleteFunc(append(matches, compressed...), func(match string) bool {
+		stamp := strings.TrimSuffix(strings.TrimSuffix(match, ".gz"), ext)
+		return !rotatedStamp.MatchString(strings.TrimPrefix(stamp, prefix+"-"))
+	})
+}
+
+// firstTimestamp of the first entry of the file, falling back to the modification time
+func firstTimestamp(name string) time.Time {
+	reader, err := openInput(name)
+	if err != nil {
+		return time.Time{}
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var entry struct {
+			Timestamp string `json:"ts"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			if ts, err := time.Parse(mangolog.RFC3339NanoMC, entry.Timestamp); err == nil {
+				return ts
+			}
+		}
+	}
+	if info, err := os.Stat(name); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// openInput opens the file, decompressing gzip files transparently
+func openInput(name string) (io.ReadCloser, error) {
+	if name == stdinName {
+		return io.NopCloser(os.Stdin), nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(name, ".gz") {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return &gzipFile{Reader: gz, file: f}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	return errors.Join(g.Reader.Close(), g.file.Close())
+}
+
+// readLines calls handle for each line of reader, returning the number of bytes consumed
+// A last line without a newline is only handled when complete is set, as it may still be being written
+func readLines(reader io.Reader, complete bool, handle func(line []byte) error) (int64, error) {
+	r := bufio.NewReaderSize(reader, 64*1024)
+	var consumed int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 && (err == nil || (err == io.EOF && complete)) {
+			consumed += int64(len(line))
+			if handleErr := handle(bytes.TrimRight(line, "\r\n")); handleErr != nil {
+				return consumed, handleErr
+			}
+		}
+		if err == io.EOF {
+			return consumed, nil
+		}
+		if err != nil {
+			return consumed, err
+		}
+	}
+}
+
+// follow keeps reading the lines appended to name from offset until ctx is done
+// When the file is rotated (replaced by a new one) or truncated, it carries on from the start of the new file
+func follow(ctx context.Context, name string, offset int64, handle func(line []byte) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	var partial []byte
+	r := bufio.NewReaderSize(f, 64*1024)
+	for {
+		line, err := r.ReadBytes('\n')
+		partial = append(partial, line...)
+		if err == nil {
+			offset += int64(len(partial))
+			if handleErr := handle(bytes.TrimRight(partial, "\r\n")); handleErr != nil {
+				return handleErr
+			}
+			partial = nil
+			continue
+		}
+		if err != io.EOF {
+			return err
+		}
+
+		// caught up, wait for more or for a rotation
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(followPollInterval):
+		}
+
+		current, statErr := f.Stat()
+		latest, latestErr := os.Stat(name)
+		switch {
+		case statErr != nil:
+			return statErr
+		case latestErr != nil:
+			// rotated away and not recreated yet
+			continue
+		case !os.SameFile(current, latest):
+			// finish what was left in the rotated file, then move on to the new one
+			if _, err := readLines(io.MultiReader(bytes.NewReader(partial), r), true, handle); err != nil {
+				return err
+			}
+			_ = f.Close()
+			if f, err = os.Open(name); err != nil {
+				return err
+			}
+			offset, partial = 0, nil
+			r.Reset(f)
+		case latest.Size() < offset:
+			// truncated in place
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			offset, partial = 0, nil
+			r.Reset(f)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func logLine(ts string, message string) string {
+	return `{"ts":"` + ts + `","type":"Business","level":"INFO","message":"` + message + `"}` + "\n"
+}
+
+func writeGzip(t *testing.T, name string, content string) {
+	f, err := os.Create(name)
+	assert.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write([]byte(content))
+	assert.NoError(t, err)
+	assert.NoError(t, gz.Close())
+	assert.NoError(t, f.Close())
+}
+
+func TestExpandInputs_OrdersRotatedBackups(t *testing.T) {
+	dir := t.TempDir()
+	current := filepath.Join(dir, "app.log")
+	assert.NoError(t, os.WriteFile(current, []byte(logLine("2025-01-15T12:00:00.000Z", "current")), 0o644))
+	writeGzip(t, filepath.Join(dir, "app-2025-01-14.log.gz"), logLine("2025-01-14T12:00:00.000Z", "oldest"))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "app-2025-01-15T10.log"), []byte(logLine("2025-01-15T10:00:00.000Z", "middle")), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "app-2025-01-15T11-00-00.000.log"), []byte(logLine("2025-01-15T11:00:00.000Z", "lumberjack")), 0o644))
+	// routes of app.log, not backups
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "app-security.log"), []byte(logLine("2025-01-13T12:00:00.000Z", "route")), 0o644))
+	writeGzip(t, filepath.Join(dir, "app-errors-2025-01-14.log.gz"), logLine("2025-01-13T12:00:00.000Z", "route backup"))
+
+	files, err := expandInputs([]string{current}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{current}, files)
+
+	files, err = expandInputs([]string{current}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "app-2025-01-14.log.gz"),
+		filepath.Join(dir, "app-2025-01-15T10.log"),
+		filepath.Join(dir, "app-2025-01-15T11-00-00.000.log"),
+		current,
+	}, files)
+
+	// globs matching the backups too are not read twice
+	files, err = expandInputs([]string{filepath.Join(dir, "app-2*.log"), current}, true)
+	assert.NoError(t, err)
+	assert.Len(t, files, 4)
+}
+
+func TestExpandInputs_Errors(t *testing.T) {
+	files, err := expandInputs(nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{stdinName}, files)
+
+	_, err = expandInputs([]string{filepath.Join(t.TempDir(), "missing.log")}, false)
+	assert.ErrorContains(t, err, "no such file")
+
+	_, err = expandInputs([]string{"-", "app.log"}, false)
+	assert.Error(t, err)
+
+	_, err = expandInputs([]string{"[bad"}, false)
+	assert.Error(t, err)
+}
+
+func TestReadLines_PartialLastLine(t *testing.T) {
+	var lines []string
+	handle := func(line []byte) error {
+		lines = append(lines, string(line))
+		return nil
+	}
+
+	consumed, err := readLines(strings.NewReader("a\r\nb\nc"), false, handle)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), consumed)
+	assert.Equal(t, []string{"a", "b"}, lines)
+
+	lines = nil
+	consumed, err = readLines(strings.NewReader("a\nb"), true, handle)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), consumed)
+	assert.Equal(t, []string{"a", "b"}, lines)
+}
+
+func TestFollow_AcrossRotationAndTruncation(t *testing.T) {
+	followPollInterval = 5 * time.Millisecond
+	defer func() { followPollInterval = 250 * time.Millisecond }()
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	assert.NoError(t, os.WriteFile(name, []byte("old\n"), 0o644))
+
+	var mu sync.Mutex
+	var lines []string
+	got := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), lines...)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- follow(ctx, name, 4, func(line []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			lines = append(lines, string(line))
+			return nil
+		})
+	}()
+
+	appendTo := func(content string) {
+		f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o644)
+		assert.NoError(t, err)
+		_, err = f.WriteString(content)
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+	}
+
+	appendTo("one\ntw")
+	assert.Eventually(t, func() bool { return len(got()) == 1 }, time.Second, time.Millisecond)
+	appendTo("o\n")
+	assert.Eventually(t, func() bool { return len(got()) == 2 }, time.Second, time.Millisecond)
+
+	// rotated: the line left in the old file is still read before the new file
+	appendTo("three\n")
+	assert.NoError(t, os.Rename(name, filepath.Join(dir, "app-1.log")))
+	appendTo("four\n")
+	assert.Eventually(t, func() bool { return len(got()) == 4 }, time.Second, time.Millisecond)
+
+	// truncated in place
+	assert.NoError(t, os.Truncate(name, 0))
+	time.Sleep(20 * time.Millisecond)
+	appendTo("5\n")
+	assert.Eventually(t, func() bool { return len(got()) == 5 }, time.Second, time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Equal(t, []string{"one", "two", "three", "four", "5"}, got())
+}
@@ -0,0 +1,209 @@
+// Command mangolog reads, filters and pretty-prints the json logs written by pkg/logger
+//
+// Usage:
+//
+//	mangolog [flags] [file|glob ...]
+//	mangolog stats [flags] [file|glob ...]
+//	mangolog decrypt -key-file keys [flags] [file|glob ...]
+//
+// Without files (or with -) it reads stdin.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	mangolog "github.com/bitstep-ie/mango-go/pkg/logger"
+)
+
+// Output formats of the -format flag
+const (
+	formatFriendly = "friendly"
+	formatVerbose  = "verbose"
+	formatJSON     = "json"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line, returning the exit code
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	var err error
+	if len(args) > 0 && args[0] == "stats" {
+		err = runStats(args[1:], stdout, stderr)
+	} else if len(args) > 0 && args[0] == "decrypt" {
+		err = runDecrypt(args[1:], stdout, stderr)
+	} else {
+		err = runView(ctx, args, stdout, stderr)
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "mangolog: %s\n", err.Error())
+		return 1
+	}
+	return 0
+}
+
+// inputOptions are the input and filter flags shared by the commands
+type inputOptions struct {
+	level       string
+	types       string
+	operations  string
+	correlation string
+	since       string
+	until       string
+	rotated     bool
+}
+
+// viewOptions are the flags of the default command
+type viewOptions struct {
+	inputOptions
+	format string
+	jq     string
+	follow bool
+}
+
+// newFlagSet registers the shared input flags
+func newFlagSet(name string, usage string, opts *inputOptions, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&opts.level, "level", "", "minimum level of the entries read, e.g. WARN")
+	flags.StringVar(&opts.types, "type", "", "comma separated Types to read, e.g. Security,Business")
+	flags.StringVar(&opts.operations, "operation", "", "comma separated Operations to read")
+	flags.StringVar(&opts.correlation, "correlation-id", "", "only read the entries with this correlation id")
+	flags.StringVar(&opts.since, "since", "", "only read the entries at or after this time (RFC3339 or a duration back from now, e.g. 15m)")
+	flags.StringVar(&opts.until, "until", "", "only read the entries before this time (RFC3339 or a duration back from now)")
+	flags.BoolVar(&opts.rotated, "rotated", false, "also read the rotated (and compressed) backups of each file, oldest first")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, "Usage: %s\n\nReads stdin without files.\n\nFlags:\n", usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+func runView(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	opts := viewOptions{}
+	flags := newFlagSet("mangolog", "mangolog [flags] [file|glob ...]\n       mangolog stats [flags] [file|glob ...]\n       mangolog decrypt -key-file keys [flags] [file|glob ...]", &opts.inputOptions, stderr)
+	flags.StringVar(&opts.format, "format", formatFriendly, "output format: friendly (logger.DefaultFriendlyFormat), verbose (logger.DefaultVerboseFormat) or json (lines as they are)")
+	flags.StringVar(&opts.jq, "jq", "", "custom jq expression formatting each entry, overrides -format")
+	flags.BoolVar(&opts.follow, "f", false, "follow the newest file, across rotations, until interrupted")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	match, err := opts.filter(time.Now())
+	if err != nil {
+		return err
+	}
+	out, err := newFormatter(opts.query())
+	if err != nil {
+		return err
+	}
+	files, err := expandInputs(flags.Args(), opts.rotated)
+	if err != nil {
+		return err
+	}
+
+	print := func(line []byte) error {
+		e, ok := parseEntry(line)
+		if !ok || !match.match(e) {
+			return nil
+		}
+		lines, err := out.format(e)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "mangolog: failed to format entry: %s\n", err.Error())
+		}
+		for _, l := range lines {
+			if _, err := fmt.Fprintln(stdout, l); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	offset, err := readInputs(files, print)
+	if err != nil {
+		return err
+	}
+
+	last := files[len(files)-1]
+	if opts.follow && last != stdinName && !strings.HasSuffix(last, ".gz") {
+		return follow(ctx, last, offset, print)
+	}
+	return nil
+}
+
+// readInputs reads the inputs in order, returning the number of bytes read from the last one
+func readInputs(files []string, handle func(line []byte) error) (int64, error) {
+	var offset int64
+	var err error
+	for _, file := range files {
+		if offset, err = readInput(file, handle); err != nil {
+			return offset, err
+		}
+	}
+	return offset, nil
+}
+
+// readInput reads the whole input, returning the number of bytes read for follow to carry on from
+func readInput(name string, handle func(line []byte) error) (int64, error) {
+	reader, err := openInput(name)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	return readLines(reader, name == stdinName || strings.HasSuffix(name, ".gz"), handle)
+}
+
+func (o inputOptions) filter(now time.Time) (*filter, error) {
+	f := &filter{
+		types:         parseList(o.types),
+		operations:    parseList(o.operations),
+		correlationId: o.correlation,
+	}
+	if o.level != "" {
+		level := slog.Level(0)
+		if err := level.UnmarshalText([]byte(o.level)); err != nil {
+			return nil, fmt.Errorf("invalid -level: %w", err)
+		}
+		f.minLevel = &level
+	}
+	var err error
+	if f.since, err = parseTime(o.since, now); err != nil {
+		return nil, fmt.Errorf("invalid -since: %w", err)
+	}
+	if f.until, err = parseTime(o.until, now); err != nil {
+		return nil, fmt.Errorf("invalid -until: %w", err)
+	}
+	return f, nil
+}
+
+// query is the jq expression for the output, empty to print the lines as they are
+func (o viewOptions) query() string {
+	if o.jq != "" {
+		return o.jq
+	}
+	switch o.format {
+	case formatVerbose:
+		return mangolog.DefaultVerboseFormat
+	case formatJSON:
+		return ""
+	default:
+		return mangolog.DefaultFriendlyFormat
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runTest(t *testing.T, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func writeLogs(t *testing.T) string {
+	name := filepath.Join(t.TempDir(), "app.log")
+	content := `{"ts":"2025-01-15T09:00:00.000Z","type":"Business","operation":"cart-create","correlationid":"c1","level":"INFO","message":"cart created","attributes":{"items":3}}
+not json
+{"ts":"2025-01-15T10:00:00.000Z","type":"Security","operation":"login","correlationid":"c2","level":"WARN","message":"bad password","attributes":{}}
+{"ts":"2025-01-15T11:00:00.000Z","type":"Business","operation":"checkout","correlationid":"c1","level":"ERROR","message":"payment failed","attributes":{}}
+`
+	assert.NoError(t, os.WriteFile(name, []byte(content), 0o644))
+	return name
+}
+
+func TestRun_Friendly(t *testing.T) {
+	code, stdout, stderr := runTest(t, "-level", "warn", writeLogs(t))
+	assert.Equal(t, 0, code)
+	assert.Empty(t, stderr)
+	assert.Equal(t, "[WARN] - 2025-01-15T10:00:00.000Z - login - bad password - {}\n"+
+		"[ERROR] - 2025-01-15T11:00:00.000Z - checkout - payment failed - {}\n", stdout)
+}
+
+func TestRun_Filters(t *testing.T) {
+	name := writeLogs(t)
+
+	_, stdout, _ := runTest(t, "-format", "json", "-type", "Business", "-correlation-id", "c1", "-until", "2025-01-15T10:30:00Z", name)
+	assert.Equal(t, 1, bytes.Count([]byte(stdout), []byte("\n")))
+	assert.Contains(t, stdout, `"message":"cart created"`)
+
+	_, stdout, _ = runTest(t, "-jq", ".operation", "-since", "2025-01-15T10:00:00Z", name)
+	assert.Equal(t, "login\ncheckout\n", stdout)
+
+	_, stdout, _ = runTest(t, "-format", "verbose", "-operation", "cart-create", name)
+	assert.Contains(t, stdout, "c1")
+}
+
+func TestRun_InvalidArgs(t *testing.T) {
+	for _, args := range [][]string{
+		{"-level", "loud"},
+		{"-since", "yesterday"},
+		{"-until", "tomorrow"},
+		{"-jq", ".["},
+		{"-unknown"},
+		{filepath.Join(t.TempDir(), "missing.log")},
+	} {
+		code, _, stderr := runTest(t, args...)
+		assert.Equal(t, 1, code, args)
+		assert.NotEmpty(t, stderr, args)
+	}
+
+	code, _, stderr := runTest(t, "-h")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stderr, "Usage: mangolog")
+}
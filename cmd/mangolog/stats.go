@@ -0,0 +1,336 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	mangolog "github.com/bitstep-ie/mango-go/pkg/logger"
+)
+
+// Output formats of the stats -format flag
+const (
+	statsFormatTable = "table"
+	statsFormatJSON  = "json"
+)
+
+// statsOptions are the flags of the stats command
+type statsOptions struct {
+	inputOptions
+	format       string
+	bucket       time.Duration
+	top          int
+	durationAttr string
+}
+
+func runStats(args []string, stdout io.Writer, stderr io.Writer) error {
+	opts := statsOptions{}
+	flags := newFlagSet("mangolog stats", "mangolog stats [flags] [file|glob ...]", &opts.inputOptions, stderr)
+	flags.StringVar(&opts.format, "format", statsFormatTable, "output format: table or json")
+	flags.DurationVar(&opts.bucket, "bucket", time.Hour, "size of the time buckets the counts are split in")
+	flags.IntVar(&opts.top, "top", 10, "number of top error messages and correlation ids")
+	flags.StringVar(&opts.durationAttr, "duration-attr", "durationMs", "attribute of the Performance entries holding the latency, a number of milliseconds or a duration such as 150ms")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if opts.format != statsFormatTable && opts.format != statsFormatJSON {
+		return fmt.Errorf("invalid -format %q, expected %s or %s", opts.format, statsFormatTable, statsFormatJSON)
+	}
+	if opts.bucket <= 0 {
+		return fmt.Errorf("invalid -bucket %s, must be positive", opts.bucket)
+	}
+	if opts.top < 0 {
+		return fmt.Errorf("invalid -top %d, must not be negative", opts.top)
+	}
+
+	match, err := opts.filter(time.Now())
+	if err != nil {
+		return err
+	}
+	files, err := expandInputs(flags.Args(), opts.rotated)
+	if err != nil {
+		return err
+	}
+
+	s := newSummary(opts.bucket, opts.durationAttr)
+	_, err = readInputs(files, func(line []byte) error {
+		if e, ok := parseEntry(line); ok && match.match(e) {
+			s.add(e)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	report := s.report(opts.top)
+	if opts.format == statsFormatJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return writeStatsTable(stdout, report)
+}
+
+// summary accumulates the entries read
+type summary struct {
+	bucket         time.Duration
+	durationAttr   string
+	total          *counts
+	buckets        map[time.Time]*counts
+	errors         map[string]int
+	correlationIds map[string]int
+	latencies      map[string][]float64
+}
+
+// counts of entries per level, Type and Operation
+type counts struct {
+	entries    int
+	errors     int
+	levels     map[string]int
+	types      map[string]int
+	operations map[string]int
+}
+
+func newSummary(bucket time.Duration, durationAttr string) *summary {
+	return &summary{
+		bucket:         bucket,
+		durationAttr:   durationAttr,
+		total:          newCounts(),
+		buckets:        map[time.Time]*counts{},
+		errors:         map[string]int{},
+		correlationIds: map[string]int{},
+		latencies:      map[string][]float64{},
+	}
+}
+
+func newCounts() *counts {
+	return &counts{levels: map[string]int{}, types: map[string]int{}, operations: map[string]int{}}
+}
+
+func (c *counts) add(e *entry) {
+	c.entries++
+	if e.level >= slog.LevelError {
+		c.errors++
+	}
+	c.levels[e.level.String()]++
+	c.types[e.str("type")]++
+	c.operations[e.str("operation")]++
+}
+
+func (s *summary) add(e *entry) {
+	s.total.add(e)
+	if !e.ts.IsZero() {
+		start := e.ts.UTC().Truncate(s.bucket)
+		if s.buckets[start] == nil {
+			s.buckets[start] = newCounts()
+		}
+		s.buckets[start].add(e)
+	}
+	if e.level >= slog.LevelError {
+		s.errors[e.str("message")]++
+	}
+	if id := e.str("correlationid"); id != "" {
+		s.correlationIds[id]++
+	}
+	if e.str("type") == mangolog.PerformanceType {
+		if ms, ok := e.durationMs(s.durationAttr); ok {
+			operation := e.str("operation")
+			s.latencies[operation] = append(s.latencies[operation], ms)
+		}
+	}
+}
+
+// durationMs reads the latency from the attributes, numbers are milliseconds and strings go through time.ParseDuration
+func (e *entry) durationMs(name string) (float64, bool) {
+	attributes, _ := e.fields["attributes"].(map[string]interface{})
+	switch v := attributes[name].(type) {
+	case float64:
+		return v, true
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return float64(d) / float64(time.Millisecond), true
+		}
+	}
+	return 0, false
+}
+
+// statsReport is the output of the stats command
+type statsReport struct {
+	Entries           int            `json:"entries"`
+	Errors            int            `json:"errors"`
+	ErrorRate         float64        `json:"errorRate"`
+	Levels            map[string]int `json:"levels"`
+	Types             map[string]int `json:"types"`
+	Operations        map[string]int `json:"operations"`
+	Buckets           []bucketStats  `json:"buckets"`
+	TopErrors         []countStats   `json:"topErrors"`
+	TopCorrelationIds []countStats   `json:"topCorrelationIds"`
+	Latency           []latencyStats `json:"latency"`
+}
+
+type bucketStats struct {
+	Start      time.Time      `json:"start"`
+	Entries    int            `json:"entries"`
+	Errors     int            `json:"errors"`
+	ErrorRate  float64        `json:"errorRate"`
+	Levels     map[string]int `json:"levels"`
+	Types      map[string]int `json:"types"`
+	Operations map[string]int `json:"operations"`
+}
+
+type countStats struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// latencyStats of the Performance entries of an operation, in milliseconds
+type latencyStats struct {
+	Operation string  `json:"operation"`
+	Count     int     `json:"count"`
+	Min       float64 `json:"min"`
+	P50       float64 `json:"p50"`
+	P90       float64 `json:"p90"`
+	P95       float64 `json:"p95"`
+	P99       float64 `json:"p99"`
+	Max       float64 `json:"max"`
+}
+
+func (s *summary) report(top int) *statsReport {
+	report := &statsReport{
+		Entries:           s.total.entries,
+		Errors:            s.total.errors,
+		ErrorRate:         rate(s.total.errors, s.total.entries),
+		Levels:            s.total.levels,
+		Types:             s.total.types,
+		Operations:        s.total.operations,
+		Buckets:           []bucketStats{},
+		TopErrors:         topCounts(s.errors, top),
+		TopCorrelationIds: topCounts(s.correlationIds, top),
+		Latency:           []latencyStats{},
+	}
+	for _, start := range slices.SortedFunc(maps.Keys(s.buckets), time.Time.Compare) {
+		c := s.buckets[start]
+		report.Buckets = append(report.Buckets, bucketStats{
+			Start:      start,
+			Entries:    c.entries,
+			Errors:     c.errors,
+			ErrorRate:  rate(c.errors, c.entries),
+			Levels:     c.levels,
+			Types:      c.types,
+			Operations: c.operations,
+		})
+	}
+	for _, operation := range slices.Sorted(maps.Keys(s.latencies)) {
+		values := s.latencies[operation]
+		slices.Sort(values)
+		report.Latency = append(report.Latency, latencyStats{
+			Operation: operation,
+			Count:     len(values),
+			Min:       values[0],
+			P50:       percentile(values, 50),
+			P90:       percentile(values, 90),
+			P95:       percentile(values, 95),
+			P99:       percentile(values, 99),
+			Max:       values[len(values)-1],
+		})
+	}
+	return report
+}
+
+func rate(part int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
+
+// topCounts returns the n most frequent values, ties ordered by value
+func topCounts(values map[string]int, n int) []countStats {
+	top := make([]countStats, 0, len(values))
+	for value, count := range values {
+		top = append(top, countStats{Value: value, Count: count})
+	}
+	slices.SortFunc(top, func(a, b countStats) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Value, b.Value))
+	})
+	return top[:min(n, len(top))]
+}
+
+// percentile of sorted values, nearest rank
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// writeStatsTable prints the report as aligned tables
+func writeStatsTable(w io.Writer, report *statsReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	levels := []string{slog.LevelDebug.String(), slog.LevelInfo.String(), slog.LevelWarn.String(), slog.LevelError.String()}
+	for level := range report.Levels {
+		if !slices.Contains(levels, level) {
+			levels = append(levels, level)
+		}
+	}
+
+	_, _ = fmt.Fprintf(tw, "Entries: %d\tErrors: %d\tError rate: %s\n", report.Entries, report.Errors, percent(report.ErrorRate))
+
+	_, _ = fmt.Fprintf(tw, "\nBUCKET\tENTRIES\t%s\tERROR RATE\n", strings.Join(levels, "\t"))
+	for _, b := range report.Buckets {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t", b.Start.Format(time.RFC3339), b.Entries)
+		for _, level := range levels {
+			_, _ = fmt.Fprintf(tw, "%d\t", b.Levels[level])
+		}
+		_, _ = fmt.Fprintf(tw, "%s\n", percent(b.ErrorRate))
+	}
+
+	_, _ = fmt.Fprintln(tw, "\nBUCKET\tTYPE\tENTRIES")
+	for _, b := range report.Buckets {
+		for _, t := range slices.Sorted(maps.Keys(b.Types)) {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\n", b.Start.Format(time.RFC3339), orNone(t), b.Types[t])
+		}
+	}
+
+	_, _ = fmt.Fprintln(tw, "\nBUCKET\tOPERATION\tENTRIES")
+	for _, b := range report.Buckets {
+		for _, operation := range slices.Sorted(maps.Keys(b.Operations)) {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\n", b.Start.Format(time.RFC3339), orNone(operation), b.Operations[operation])
+		}
+	}
+
+	_, _ = fmt.Fprintln(tw, "\nTOP ERRORS\tCOUNT")
+	for _, c := range report.TopErrors {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\n", orNone(c.Value), c.Count)
+	}
+
+	_, _ = fmt.Fprintln(tw, "\nTOP CORRELATION IDS\tCOUNT")
+	for _, c := range report.TopCorrelationIds {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\n", c.Value, c.Count)
+	}
+
+	_, _ = fmt.Fprintln(tw, "\nLATENCY (ms)\tCOUNT\tMIN\tP50\tP90\tP95\tP99\tMAX")
+	for _, l := range report.Latency {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%g\t%g\t%g\t%g\t%g\t%g\n", orNone(l.Operation), l.Count, l.Min, l.P50, l.P90, l.P95, l.P99, l.Max)
+	}
+	return tw.Flush()
+}
+
+func percent(rate float64) string {
+	return fmt.Sprintf("%.1f%%", rate*100)
+}
+
+// orNone shows missing values
+func orNone(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeStatsLogs(t *testing.T) string {
+	name := filepath.Join(t.TempDir(), "app.log")
+	content := `{"ts":"2025-01-15T09:10:00.000Z","type":"Business","operation":"cart","correlationid":"c1","level":"INFO","message":"ok","attributes":{}}
+{"ts":"2025-01-15T09:20:00.000Z","type":"Performance","operation":"cart","correlationid":"c1","level":"INFO","message":"timing","attributes":{"durationMs":120}}
+{"ts":"2025-01-15T10:20:00.000Z","type":"Performance","operation":"cart","correlationid":"c2","level":"INFO","message":"timing","attributes":{"durationMs":"80ms"}}
+{"ts":"2025-01-15T10:25:00.000Z","type":"Performance","operation":"cart","correlationid":"c2","level":"INFO","message":"timing","attributes":{"durationMs":"n/a"}}
+{"ts":"2025-01-15T10:30:00.000Z","type":"Business","operation":"pay","correlationid":"c1","level":"ERROR","message":"payment failed","attributes":{}}
+{"ts":"2025-01-15T10:40:00.000Z","type":"Business","operation":"pay","correlationid":"c3","level":"ERROR","message":"payment failed","attributes":{}}
+not json
+`
+	assert.NoError(t, os.WriteFile(name, []byte(content), 0o644))
+	return name
+}
+
+func TestRunStats_JSON(t *testing.T) {
+	code, stdout, stderr := runTest(t, "stats", "-format", "json", "-top", "2", writeStatsLogs(t))
+	assert.Equal(t, 0, code)
+	assert.Empty(t, stderr)
+
+	report := statsReport{}
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &report))
+	assert.Equal(t, 6, report.Entries)
+	assert.Equal(t, 2, report.Errors)
+	assert.InDelta(t, 1.0/3, report.ErrorRate, 0.0001)
+	assert.Equal(t, map[string]int{"INFO": 4, "ERROR": 2}, report.Levels)
+	assert.Equal(t, map[string]int{"cart": 4, "pay": 2}, report.Operations)
+
+	assert.Len(t, report.Buckets, 2)
+	assert.Equal(t, time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC), report.Buckets[1].Start)
+	assert.Equal(t, 4, report.Buckets[1].Entries)
+	assert.Equal(t, 0.5, report.Buckets[1].ErrorRate)
+	assert.Equal(t, map[string]int{"Business": 2, "Performance": 2}, report.Buckets[1].Types)
+
+	assert.Equal(t, []countStats{{Value: "payment failed", Count: 2}}, report.TopErrors)
+	assert.Equal(t, []countStats{{Value: "c1", Count: 3}, {Value: "c2", Count: 2}}, report.TopCorrelationIds)
+	assert.Equal(t, []latencyStats{{Operation: "cart", Count: 2, Min: 80, P50: 80, P90: 120, P95: 120, P99: 120, Max: 120}}, report.Latency)
+}
+
+func TestRunStats_Table(t *testing.T) {
+	code, stdout, _ := runTest(t, "stats", "-level", "error", "-bucket", "24h", writeStatsLogs(t))
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "Entries: 2  Errors: 2  Error rate: 100.0%")
+	assert.Regexp(t, `2025-01-15T00:00:00Z\s+2\s+0\s+0\s+0\s+2\s+100.0%`, stdout)
+	assert.Regexp(t, `payment failed\s+2`, stdout)
+}
+
+func TestRunStats_InvalidArgs(t *testing.T) {
+	for _, args := range [][]string{
+		{"stats", "-format", "csv"},
+		{"stats", "-bucket", "0s"},
+		{"stats", "-top", "-1"},
+		{"stats", "-since", "yesterday"},
+	} {
+		code, _, stderr := runTest(t, args...)
+		assert.Equal(t, 1, code, args)
+		assert.NotEmpty(t, stderr, args)
+	}
+
+	_, _, stderr := runTest(t, "stats", "-top", "-1", writeStatsLogs(t))
+	assert.Contains(t, stderr, "invalid -top -1, must not be negative")
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	assert.Equal(t, 5.0, percentile(values, 50))
+	assert.Equal(t, 9.0, percentile(values, 90))
+	assert.Equal(t, 10.0, percentile(values, 99))
+	assert.Equal(t, 7.0, percentile([]float64{7}, 50))
+}
@@ -0,0 +1,320 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrAuditChainBroken is returned by VerifyAuditLog when the log shows signs of tampering
+var ErrAuditChainBroken = errors.New("audit chain broken")
+
+// auditMacPrefix precedes the mac, the last field of every sealed entry
+const auditMacPrefix = `,"auditMac":"`
+
+// auditChain seals the Security entries, each one chained to the previous one
+type auditChain struct {
+	mu        sync.Mutex
+	key       []byte
+	seq       uint64
+	prev      string
+	statePath string
+
+	// written is the sequence number of the last entry written, turn is signalled when it moves on
+	// The entries are written in turn after sealing, without holding mu, so the sequence follows the order in the files
+	written uint64
+	turn    *sync.Cond
+}
+
+// auditState is persisted in AuditConfig.StatePath
+type auditState struct {
+	Seq uint64 `json:"seq"`
+	Mac string `json:"mac"`
+}
+
+// newAuditChain returns nil, leaving the entries unsealed, without a Key as anyone could forge the macs
+func newAuditChain(config *AuditConfig, diagnostics *diagnostics) *auditChain {
+	if config.Key == "" {
+		diagnostics.printf("Ignoring config.mango.audit, the key is required to seal the Security entries\n")
+		return nil
+	}
+	chain := &auditChain{key: []byte(config.Key), statePath: config.StatePath}
+	chain.turn = sync.NewCond(&chain.mu)
+	if chain.statePath == "" {
+		return chain
+	}
+	content, err := os.ReadFile(chain.statePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			diagnostics.printf("Failed to read the audit chain state, restarting the chain. %s\n", err.Error())
+		}
+		return chain
+	}
+	state := auditState{}
+	if err := json.Unmarshal(content, &state); err != nil {
+		diagnostics.printf("Failed to read the audit chain state, restarting the chain. %s\n", err.Error())
+		return chain
+	}
+	chain.seq = state.Seq
+	chain.prev = state.Mac
+	chain.written = state.Seq
+	return chain
+}
+
+// isEnabled is nil safe, as the audit node is optional in the configuration
+func (c *AuditConfig) isEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// seal numbers the entry, links it to the previous one and returns its json with the mac as the last field
+// The entry must then be written with inTurn, the later entries waiting for it
+func (c *auditChain) seal(log *StructuredLog) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	log.AuditSeq = c.seq + 1
+	log.AuditPrev = c.prev
+	log.AuditMac = ""
+	body, err := json.Marshal(log)
+	if err != nil {
+		return nil, err
+	}
+
+	log.AuditMac = c.mac(body)
+	jsonOut, err := json.Marshal(log)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.statePath != "" {
+		if err := c.saveState(auditState{Seq: log.AuditSeq, Mac: log.AuditMac}); err != nil {
+			return nil, err
+		}
+	}
+	c.seq = log.AuditSeq
+	c.prev = log.AuditMac
+	return jsonOut, nil
+}
+
+// inTurn calls write once the entries sealed before seq are written, then lets the next one write
+// No lock is held while writing, only the entries sealed after seq wait for it
+func (c *auditChain) inTurn(seq uint64, write func() error) error {
+	c.mu.Lock()
+	for c.written+1 < seq {
+		c.turn.Wait()
+	}
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.written = seq
+		c.turn.Broadcast()
+		c.mu.Unlock()
+	}()
+	return write()
+}
+
+func (c *auditChain) mac(body []byte) string {
+	h := hmac.New(sha256.New, c.key)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// saveState replaces the state file atomically, a crash never leaves it half written
+func (c *auditChain) saveState(state auditState) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.statePath), filepath.Base(c.statePath)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save the audit chain state: %w", err)
+	}
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.statePath)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save the audit chain state: %w", err)
+	}
+	return nil
+}
+
+// AuditGap is a range of sequence numbers missing from the log
+type AuditGap struct {
+	// After is the last sequence number before the gap
+	After uint64 `json:"after"`
+
+	// Next is the first sequence number after the gap
+	Next uint64 `json:"next"`
+}
+
+// AuditReport is the outcome of VerifyAuditLog
+type AuditReport struct {
+	// Entries is the number of sealed entries read
+	Entries int `json:"entries"`
+
+	// FirstSeq and LastSeq are the sequence numbers of the first and last sealed entries read
+	FirstSeq uint64 `json:"firstSeq"`
+	LastSeq  uint64 `json:"lastSeq"`
+
+	// BrokenLine is the line number (1 based) of the first entry failing verification - 0 when none
+	BrokenLine int `json:"brokenLine,omitempty"`
+
+	// BrokenSeq is the sequence number of the first entry failing verification
+	BrokenSeq uint64 `json:"brokenSeq,omitempty"`
+
+	// BrokenReason explains why the first broken entry failed verification
+	BrokenReason string `json:"brokenReason,omitempty"`
+
+	// Gaps are the ranges of missing sequence numbers
+	Gaps []AuditGap `json:"gaps,omitempty"`
+
+	// Restarts counts the points where the chain restarted at sequence 1 (a restart without AuditConfig.StatePath)
+	// The entries sealed before a restart may have been removed unnoticed, so a restart is a finding
+	Restarts int `json:"restarts,omitempty"`
+
+	// Truncated is set when the last line is incomplete
+	Truncated bool `json:"truncated,omitempty"`
+
+	// MissingHead is set when the first sealed entry doesn't follow AuditAnchor.PrevSeq and PrevMac
+	MissingHead bool `json:"missingHead,omitempty"`
+
+	// MissingTail is set when the last sealed entry isn't the one of AuditAnchor.LastSeq and LastMac
+	MissingTail bool `json:"missingTail,omitempty"`
+}
+
+// Ok reports whether no broken link, gap, restart, truncation or missing head or tail was found
+func (r *AuditReport) Ok() bool {
+	return r.BrokenLine == 0 && len(r.Gaps) == 0 && r.Restarts == 0 && !r.Truncated && !r.MissingHead && !r.MissingTail
+}
+
+// AuditAnchor is where the chain of a log is expected to start and end, so entries cut from either end are detected
+type AuditAnchor struct {
+	// PrevSeq and PrevMac are of the entry sealed before the first one of the log, zero values when it starts the chain
+	PrevSeq uint64 `json:"prevSeq"`
+	PrevMac string `json:"prevMac"`
+
+	// LastSeq and LastMac are of the last entry sealed, e.g. from AuditConfig.StatePath - zero values to not check the end
+	LastSeq uint64 `json:"lastSeq"`
+	LastMac string `json:"lastMac"`
+}
+
+// ReadAuditAnchor returns the anchor of a log starting the chain and ending at the state saved in AuditConfig.StatePath
+func ReadAuditAnchor(statePath string) (*AuditAnchor, error) {
+	content, err := os.ReadFile(statePath)
+	if err != nil {
+		return nil, err
+	}
+	state := auditState{}
+	if err := json.Unmarshal(content, &state); err != nil {
+		return nil, fmt.Errorf("failed to read the audit chain state: %w", err)
+	}
+	return &AuditAnchor{LastSeq: state.Seq, LastMac: state.Mac}, nil
+}
+
+// VerifyAuditLog checks the sealed entries read from reader against the key
+// Lines without audit fields (non Security entries) are skipped. The first sealed entry is trusted as the anchor
+// of the chain, so to verify across rotations pass the files in order, e.g. with io.MultiReader
+// The returned error wraps ErrAuditChainBroken when the report is not Ok, or is the read error
+func VerifyAuditLog(reader io.Reader, key []byte) (*AuditReport, error) {
+	return VerifyAuditLogAnchored(reader, key, nil)
+}
+
+// VerifyAuditLogAnchored checks the log as VerifyAuditLog does, also checking that it starts and ends at anchor
+// A nil anchor trusts the first sealed entry and doesn't check the end
+func VerifyAuditLogAnchored(reader io.Reader, key []byte, anchor *AuditAnchor) (*AuditReport, error) {
+	chain := &auditChain{key: key}
+	report := &AuditReport{}
+	r := bufio.NewReader(reader)
+	var prev *StructuredLog
+	for lineNo := 1; ; lineNo++ {
+		line, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return report, err
+		}
+		if len(line) == 0 {
+			break
+		}
+		if line[len(line)-1] != '\n' {
+			report.Truncated = true // the last write never completed, or the file was cut
+			break
+		}
+
+		line = bytes.TrimRight(line, "\r\n")
+		entry := &StructuredLog{}
+		if len(line) == 0 || json.Unmarshal(line, entry) != nil {
+			report.broken(lineNo, 0, "not a log entry")
+			continue
+		}
+		if entry.AuditMac == "" {
+			continue
+		}
+
+		report.Entries++
+		if report.FirstSeq == 0 {
+			report.FirstSeq = entry.AuditSeq
+		}
+		report.LastSeq = entry.AuditSeq
+
+		if !chain.verifyMac(line, entry.AuditMac) {
+			report.broken(lineNo, entry.AuditSeq, "mac mismatch")
+		} else if prev == nil {
+			if anchor != nil && (entry.AuditSeq != anchor.PrevSeq+1 || entry.AuditPrev != anchor.PrevMac) {
+				report.MissingHead = true
+			}
+		} else {
+			switch {
+			case entry.AuditSeq == 1 && entry.AuditPrev == "":
+				report.Restarts++
+			case entry.AuditSeq <= prev.AuditSeq:
+				report.broken(lineNo, entry.AuditSeq, fmt.Sprintf("sequence %d after %d", entry.AuditSeq, prev.AuditSeq))
+			case entry.AuditSeq > prev.AuditSeq+1:
+				report.Gaps = append(report.Gaps, AuditGap{After: prev.AuditSeq, Next: entry.AuditSeq})
+			case entry.AuditPrev != prev.AuditMac:
+				report.broken(lineNo, entry.AuditSeq, "not linked to the previous entry")
+			}
+		}
+		prev = entry
+	}
+
+	if anchor != nil && anchor.LastSeq != 0 && (prev == nil || prev.AuditSeq != anchor.LastSeq || prev.AuditMac != anchor.LastMac) {
+		report.MissingTail = true
+	}
+
+	if !report.Ok() {
+		return report, fmt.Errorf("%w: broken line %d, %d gaps, %d restarts, truncated %t, missing head %t, missing tail %t", ErrAuditChainBroken,
+			report.BrokenLine, len(report.Gaps), report.Restarts, report.Truncated, report.MissingHead, report.MissingTail)
+	}
+	return report, nil
+}
+
+// broken records the first failure only
+func (r *AuditReport) broken(line int, seq uint64, reason string) {
+	if r.BrokenLine == 0 {
+		r.BrokenLine = line
+		r.BrokenSeq = seq
+		r.BrokenReason = reason
+	}
+}
+
+// verifyMac strips the mac from the line, restoring the exact bytes that were sealed
+func (c *auditChain) verifyMac(line []byte, mac string) bool {
+	suffix := auditMacPrefix + mac + `"}`
+	if !bytes.HasSuffix(line, []byte(suffix)) {
+		return false
+	}
+	body := append(bytes.Clone(line[:len(line)-len(suffix)]), '}')
+	return hmac.Equal([]byte(c.mac(body)), []byte(mac))
+}
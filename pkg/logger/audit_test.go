@@ -0,0 +1,304 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testAuditKey = "audit-secret"
+
+func newAuditTestLogger(t *testing.T, dir string, statePath string) *MangoLogger {
+	return NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled: true,
+			File: &FileOutputConfig{
+				Enabled: true,
+				Path:    filepath.Join(dir, "audit.log"),
+			},
+			Cli:    &CliConfig{},
+			Syslog: &SyslogConfig{},
+		},
+		MangoConfig: &MangoConfig{
+			CorrelationId: &CorrelationIdConfig{AutoGenerate: true},
+			Audit:         &AuditConfig{Enabled: true, Key: testAuditKey, StatePath: statePath},
+		},
+	})
+}
+
+func writeAuditEntries(t *testing.T, logger *MangoLogger, count int) {
+	security := context.WithValue(context.Background(), TYPE, SecurityType)
+	business := context.WithValue(context.Background(), TYPE, BusinessType)
+	for i := 0; i < count; i++ {
+		record := slog.NewRecord(time.Now(), slog.LevelInfo, "login", 0)
+		record.AddAttrs(slog.Int("attempt", i))
+		assert.NoError(t, logger.Handle(security, record))
+		assert.NoError(t, logger.Handle(business, slog.NewRecord(time.Now(), slog.LevelInfo, "not sealed", 0)))
+	}
+}
+
+func auditLines(t *testing.T, dir string) []string {
+	content, err := os.ReadFile(filepath.Join(dir, "audit.log"))
+	assert.NoError(t, err)
+	return strings.SplitAfter(string(content), "\n")
+}
+
+func TestAudit_SealAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	logger := newAuditTestLogger(t, dir, "")
+	writeAuditEntries(t, logger, 3)
+
+	lines := auditLines(t, dir)
+	assert.Contains(t, lines[0], `"auditSeq":1,`)
+	assert.NotContains(t, lines[0], `"auditPrev"`)
+	assert.Contains(t, lines[2], `"auditSeq":2,"auditPrev":"`)
+	assert.NotContains(t, lines[1], `"auditSeq"`)
+
+	report, err := VerifyAuditLog(strings.NewReader(strings.Join(lines, "")), []byte(testAuditKey))
+	assert.NoError(t, err)
+	assert.True(t, report.Ok())
+	assert.Equal(t, 3, report.Entries)
+	assert.Equal(t, uint64(1), report.FirstSeq)
+	assert.Equal(t, uint64(3), report.LastSeq)
+
+	_, err = VerifyAuditLog(strings.NewReader(strings.Join(lines, "")), []byte("wrong key"))
+	assert.ErrorIs(t, err, ErrAuditChainBroken)
+}
+
+func TestAudit_DebugNotWritten(t *testing.T) {
+	dir := t.TempDir()
+	logger := newAuditTestLogger(t, dir, "")
+	security := context.WithValue(context.Background(), TYPE, SecurityType)
+	for _, level := range []slog.Level{slog.LevelInfo, slog.LevelDebug, slog.LevelWarn, slog.LevelDebug, slog.LevelError} {
+		assert.NoError(t, logger.Handle(security, slog.NewRecord(time.Now(), level, "login", 0)))
+	}
+
+	report, err := VerifyAuditLog(strings.NewReader(strings.Join(auditLines(t, dir), "")), []byte(testAuditKey))
+	assert.NoError(t, err)
+	assert.True(t, report.Ok(), "the DEBUG entries the file leaves out are not sealed")
+	assert.Empty(t, report.Gaps)
+	assert.Equal(t, 3, report.Entries)
+	assert.Equal(t, uint64(3), report.LastSeq)
+
+	logger.Config.Out.File.Routes = []FileRouteConfig{{Types: []string{SecurityType}, Debug: true, Path: filepath.Join(dir, "security.log")}}
+	logger.routes = newFileRoutes(logger.Config.Out.File, nil)
+	assert.NoError(t, logger.Handle(security, slog.NewRecord(time.Now(), slog.LevelDebug, "written to the route", 0)))
+	content, err := os.ReadFile(filepath.Join(dir, "security.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"auditSeq":4,`)
+}
+
+func TestAudit_DetectsAlteredEntry(t *testing.T) {
+	dir := t.TempDir()
+	writeAuditEntries(t, newAuditTestLogger(t, dir, ""), 3)
+	lines := auditLines(t, dir)
+	lines[2] = strings.Replace(lines[2], `"attempt":1`, `"attempt":9`, 1)
+
+	report, err := VerifyAuditLog(strings.NewReader(strings.Join(lines, "")), []byte(testAuditKey))
+	assert.ErrorIs(t, err, ErrAuditChainBroken)
+	assert.Equal(t, 3, report.BrokenLine)
+	assert.Equal(t, uint64(2), report.BrokenSeq)
+	assert.Equal(t, "mac mismatch", report.BrokenReason)
+}
+
+func TestAudit_DetectsGap(t *testing.T) {
+	dir := t.TempDir()
+	writeAuditEntries(t, newAuditTestLogger(t, dir, ""), 4)
+	lines := auditLines(t, dir)
+	lines = append(lines[:2], lines[4:]...) // drop seq 2
+
+	report, err := VerifyAuditLog(strings.NewReader(strings.Join(lines, "")), []byte(testAuditKey))
+	assert.ErrorIs(t, err, ErrAuditChainBroken)
+	assert.Zero(t, report.BrokenLine)
+	assert.Equal(t, []AuditGap{{After: 1, Next: 3}}, report.Gaps)
+}
+
+func TestAudit_DetectsReorder(t *testing.T) {
+	dir := t.TempDir()
+	writeAuditEntries(t, newAuditTestLogger(t, dir, ""), 3)
+	lines := auditLines(t, dir)
+	lines[2], lines[4] = lines[4], lines[2]
+
+	report, err := VerifyAuditLog(strings.NewReader(strings.Join(lines, "")), []byte(testAuditKey))
+	assert.ErrorIs(t, err, ErrAuditChainBroken)
+	assert.Equal(t, 5, report.BrokenLine)
+	assert.Equal(t, "sequence 2 after 3", report.BrokenReason)
+}
+
+func TestAudit_DetectsTruncation(t *testing.T) {
+	dir := t.TempDir()
+	writeAuditEntries(t, newAuditTestLogger(t, dir, ""), 2)
+	content := strings.Join(auditLines(t, dir)[:3], "")
+	content = content[:len(content)-20]
+
+	report, err := VerifyAuditLog(strings.NewReader(content), []byte(testAuditKey))
+	assert.ErrorIs(t, err, ErrAuditChainBroken)
+	assert.True(t, report.Truncated)
+	assert.Equal(t, 1, report.Entries)
+}
+
+func TestAudit_ContinuesAcrossRotation(t *testing.T) {
+	dir := t.TempDir()
+	logger := newAuditTestLogger(t, dir, "")
+	writeAuditEntries(t, logger, 2)
+	assert.NoError(t, logger.Rotate())
+	writeAuditEntries(t, logger, 2)
+	assert.NoError(t, logger.file.Close())
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "audit-*.log"))
+	assert.Len(t, rotated, 1)
+	first, _ := os.Open(rotated[0])
+	second, _ := os.Open(filepath.Join(dir, "audit.log"))
+	defer func() { _ = first.Close(); _ = second.Close() }()
+
+	report, err := VerifyAuditLog(io.MultiReader(first, second), []byte(testAuditKey))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.Entries)
+
+	// the current file alone is anchored on its first entry
+	current, _ := os.ReadFile(filepath.Join(dir, "audit.log"))
+	report, err = VerifyAuditLog(bytes.NewReader(current), []byte(testAuditKey))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), report.FirstSeq)
+}
+
+func TestAudit_ContinuesAcrossRestartWithState(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "audit.state")
+	writeAuditEntries(t, newAuditTestLogger(t, dir, statePath), 2)
+	writeAuditEntries(t, newAuditTestLogger(t, dir, statePath), 2)
+
+	content, _ := os.ReadFile(filepath.Join(dir, "audit.log"))
+	report, err := VerifyAuditLog(bytes.NewReader(content), []byte(testAuditKey))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), report.LastSeq)
+	assert.Zero(t, report.Restarts)
+}
+
+func TestAudit_RestartWithoutState(t *testing.T) {
+	dir := t.TempDir()
+	writeAuditEntries(t, newAuditTestLogger(t, dir, ""), 2)
+	writeAuditEntries(t, newAuditTestLogger(t, dir, ""), 1)
+
+	content, _ := os.ReadFile(filepath.Join(dir, "audit.log"))
+	report, err := VerifyAuditLog(bytes.NewReader(content), []byte(testAuditKey))
+	assert.ErrorIs(t, err, ErrAuditChainBroken, "the entries sealed before the restart may be gone")
+	assert.Equal(t, 1, report.Restarts)
+	assert.Zero(t, report.BrokenLine)
+}
+
+func TestAudit_EmptyKeyDisablesSealing(t *testing.T) {
+	var out bytes.Buffer
+	diagnostics := newDiagnostics(time.Minute)
+	diagnostics.out = &out
+	assert.Nil(t, newAuditChain(&AuditConfig{Enabled: true}, diagnostics))
+	assert.Contains(t, out.String(), "the key is required")
+
+	logger := NewMangoLogger(&LogConfig{
+		Out:         &OutConfig{File: &FileOutputConfig{}, Cli: &CliConfig{}},
+		MangoConfig: &MangoConfig{Audit: &AuditConfig{Enabled: true}},
+	})
+	assert.Nil(t, logger.audit)
+}
+
+func TestAudit_AnchoredDetectsMissingEnds(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "audit.state")
+	writeAuditEntries(t, newAuditTestLogger(t, dir, statePath), 4)
+	anchor, err := ReadAuditAnchor(statePath)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), anchor.LastSeq)
+
+	lines := auditLines(t, dir) // a sealed entry then an unsealed one, 4 times
+	verify := func(lines []string, anchor *AuditAnchor) *AuditReport {
+		report, _ := VerifyAuditLogAnchored(strings.NewReader(strings.Join(lines, "")), []byte(testAuditKey), anchor)
+		return report
+	}
+
+	report, err := VerifyAuditLogAnchored(strings.NewReader(strings.Join(lines, "")), []byte(testAuditKey), anchor)
+	assert.NoError(t, err)
+	assert.True(t, report.Ok())
+
+	report = verify(lines[2:], anchor)
+	assert.True(t, report.MissingHead, "first entry removed")
+	assert.False(t, report.MissingTail)
+	assert.True(t, verify(lines[:6], anchor).MissingTail, "last entry removed")
+	assert.True(t, verify(nil, anchor).MissingTail, "every entry removed")
+	assert.True(t, verify(lines[:6], nil).Ok(), "not checked without an anchor")
+
+	// a rotated away head is described by the entry before the file
+	var second StructuredLog
+	assert.NoError(t, json.Unmarshal([]byte(lines[2]), &second))
+	report = verify(lines[4:], &AuditAnchor{PrevSeq: 2, PrevMac: second.AuditMac, LastSeq: anchor.LastSeq, LastMac: anchor.LastMac})
+	assert.True(t, report.Ok())
+
+	_, err = ReadAuditAnchor(filepath.Join(dir, "missing.state"))
+	assert.Error(t, err)
+}
+
+// failingSink fails the entries with the given message
+type failingSink struct{ message string }
+
+func (s failingSink) WriteEntry(log StructuredLog) error {
+	if log.Message == s.message {
+		return errors.New("sink unavailable")
+	}
+	return nil
+}
+
+func TestAudit_ReentrantErrorHandler(t *testing.T) {
+	dir := t.TempDir()
+	var logger *MangoLogger
+	var nested error
+	logger = NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled: true,
+			File:    &FileOutputConfig{Enabled: true, Path: filepath.Join(dir, "audit.log")},
+			Cli:     &CliConfig{},
+			Syslog:  &SyslogConfig{},
+			Sink:    failingSink{message: "login"},
+		},
+		MangoConfig: &MangoConfig{
+			CorrelationId: &CorrelationIdConfig{AutoGenerate: true},
+			Audit:         &AuditConfig{Enabled: true, Key: testAuditKey},
+			Failure: &FailureConfig{
+				Output: FailurePolicyHandler,
+				ErrorHandler: func(err error, log *StructuredLog) {
+					// reported as a Security entry, sealed after the failed one
+					security := context.WithValue(context.Background(), TYPE, SecurityType)
+					nested = logger.Handle(security, slog.NewRecord(time.Now(), slog.LevelWarn, "sink failed", 0))
+				},
+			},
+		},
+	})
+
+	done := make(chan error)
+	go func() {
+		security := context.WithValue(context.Background(), TYPE, SecurityType)
+		done <- logger.Handle(security, slog.NewRecord(time.Now(), slog.LevelInfo, "login", 0))
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("the nested Security entry deadlocked")
+	}
+	assert.NoError(t, nested)
+
+	lines := auditLines(t, dir)
+	assert.Contains(t, lines[0], `"message":"login"`)
+	assert.Contains(t, lines[1], `"message":"sink failed"`)
+	report, err := VerifyAuditLog(strings.NewReader(strings.Join(lines, "")), []byte(testAuditKey))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Entries)
+}
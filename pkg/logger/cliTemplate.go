@@ -0,0 +1,201 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// levelWidth is the width of the longest level name, padLevel pads to it
+const levelWidth = len("ERROR")
+
+// ANSI colours of the levels used by the color template function
+var levelColors = map[string]string{
+	"DEBUG": "\033[90m",
+	"INFO":  "\033[36m",
+	"WARN":  "\033[33m",
+	"ERROR": "\033[31m",
+}
+
+const colorReset = "\033[0m"
+
+// templateFuncs are the helpers available to the CliFormatModeTemplate formats
+var templateFuncs = template.FuncMap{
+	"json":       templateJson,
+	"text":       templateText,
+	"quote":      templateQuote,
+	"pad":        templatePad,
+	"padLevel":   func(level any) string { return templatePad(levelWidth, level) },
+	"color":      templateColor,
+	"duration":   templateDuration,
+	"durationMs": templateDurationMs,
+	"kv":         templateKv,
+}
+
+// cliTemplates holds the text/template formats of the CLI output compiled once
+type cliTemplates struct {
+	mu       sync.RWMutex
+	compiled map[string]*template.Template
+	errs     map[string]error
+}
+
+// newCliTemplates compiles the friendly and verbose formats when the CLI output is in template mode
+func newCliTemplates(config *CliConfig) *cliTemplates {
+	if config == nil || config.FormatMode != CliFormatModeTemplate {
+		return nil
+	}
+	t := &cliTemplates{compiled: map[string]*template.Template{}, errs: map[string]error{}}
+	t.compile(config.FriendlyFormat)
+	t.compile(config.VerboseFormat)
+	return t
+}
+
+// compile returns the template of the format, compiling and keeping it on first use
+func (t *cliTemplates) compile(format string) (*template.Template, error) {
+	if t == nil {
+		return parseTemplate(format)
+	}
+	t.mu.RLock()
+	tmpl, ok := t.compiled[format]
+	err := t.errs[format]
+	t.mu.RUnlock()
+	if ok || err != nil {
+		return tmpl, err
+	}
+	tmpl, err = parseTemplate(format)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		t.errs[format] = err
+	} else {
+		t.compiled[format] = tmpl
+	}
+	return tmpl, err
+}
+
+func parseTemplate(format string) (*template.Template, error) {
+	return template.New("cli").Funcs(templateFuncs).Parse(format)
+}
+
+// format executes the template on the entry decoded as the jq formats see it
+func (t *cliTemplates) format(obj string, format string) (string, error) {
+	tmpl, err := t.compile(format)
+	if err != nil {
+		return "", err
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(obj), &entry); err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, entry); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// templateJson encodes the value as json, as the jq output does
+func templateJson(v any) (string, error) {
+	out, err := json.Marshal(v)
+	return string(out), err
+}
+
+// templateQuote encodes the text as a json string, as jq prints a string result
+func templateQuote(s string) (string, error) {
+	return templateJson(s)
+}
+
+// templateText prints strings as they are and anything else as json, as jq string interpolation does
+func templateText(v any) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
+
+// templatePad pads the text of the value with spaces to width
+func templatePad(width int, v any) string {
+	s, err := templateText(v)
+	if err != nil {
+		s = fmt.Sprint(v)
+	}
+	return fmt.Sprintf("%-*s", width, s)
+}
+
+// templateColor wraps the text in the ANSI colour of the level, other levels are left plain
+func templateColor(level any, text string) string {
+	s, _ := level.(string)
+	c, ok := levelColors[strings.TrimSpace(s)]
+	if !ok {
+		return text
+	}
+	return c + text + colorReset
+}
+
+// templateDuration prints a duration attribute in either DurationFormat as time.Duration does
+// A number is read as nanoseconds, use templateDurationMs for the durationMs attributes of the timers and the client
+func templateDuration(v any) (string, error) {
+	switch d := v.(type) {
+	case float64:
+		return time.Duration(d).String(), nil
+	case string:
+		parsed, err := time.ParseDuration(d)
+		if err != nil {
+			return "", err
+		}
+		return parsed.String(), nil
+	default:
+		return "", fmt.Errorf("duration: unsupported value %v", v)
+	}
+}
+
+// templateDurationMs prints a number of milliseconds, e.g. the durationMs attribute, as time.Duration does
+func templateDurationMs(v any) (string, error) {
+	ms, ok := v.(float64)
+	if !ok {
+		return "", fmt.Errorf("durationMs: unsupported value %v", v)
+	}
+	return time.Duration(ms * float64(time.Millisecond)).String(), nil
+}
+
+// templateKv joins the attributes as sorted key=value pairs, groups are flattened with dots
+func templateKv(v any) (string, error) {
+	attrs, ok := v.(map[string]interface{})
+	if !ok {
+		return templateText(v)
+	}
+	var pairs []string
+	if err := appendKv(&pairs, "", attrs); err != nil {
+		return "", err
+	}
+	return strings.Join(pairs, " "), nil
+}
+
+func appendKv(pairs *[]string, prefix string, attrs map[string]interface{}) error {
+	for _, key := range slices.Sorted(maps.Keys(attrs)) {
+		if group, ok := attrs[key].(map[string]interface{}); ok {
+			if err := appendKv(pairs, prefix+key+".", group); err != nil {
+				return err
+			}
+			continue
+		}
+		value, err := templateText(attrs[key])
+		if err != nil {
+			return err
+		}
+		*pairs = append(*pairs, prefix+key+"="+value)
+	}
+	return nil
+}
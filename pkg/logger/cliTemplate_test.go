@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCliTemplates_DefaultsMatchJq(t *testing.T) {
+	entries := []string{
+		`{"ts":"2025-01-15T09:53:34.717Z","level":"INFO","operation":"create","message":"hi \"there\"","attributes":{"b":1,"a":"s","g":{"x":[1,2.5,null]}}}`,
+		`{"ts":"2025-01-15T09:53:34.717Z","level":"WARN","message":"<a> & é  ","attributes":{"html":"<b>&"}}`,
+		`{"level":"DEBUG","message":"no operation nor attributes","n":1e21,"ok":true}`,
+	}
+	for _, entry := range entries {
+		for jq, tmpl := range map[string]string{
+			DefaultFriendlyFormat: DefaultFriendlyTemplate,
+			DefaultVerboseFormat:  DefaultVerboseTemplate,
+		} {
+			want, err := formatWithGoJQ(entry, jq)
+			assert.NoError(t, err)
+			got, err := (*cliTemplates)(nil).format(entry, tmpl)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got, entry)
+		}
+	}
+}
+
+func TestCliTemplates_Funcs(t *testing.T) {
+	entry := `{"level":"WARN","message":"m","attributes":{"durationMs":1500.25,"elapsed":1500000000,"took":"2m0s","user":{"id":7,"name":"ann"},"z":"last"}}`
+	tests := map[string]string{
+		`[{{padLevel .level}}] {{.message}}`:                 "[WARN ] m",
+		`{{pad 3 .attributes.user.id}}|`:                     "7  |",
+		`{{color .level .message}}`:                          "\033[33mm\033[0m",
+		`{{color "TRACE" .message}}`:                         "m",
+		`{{duration .attributes.elapsed}}`:                   "1.5s",
+		`{{duration .attributes.took}}`:                      "2m0s",
+		`{{durationMs .attributes.durationMs}}`:              "1.50025s",
+		`{{.message}} {{kv .attributes}}`:                    "m durationMs=1500.25 elapsed=1500000000 took=2m0s user.id=7 user.name=ann z=last",
+		`{{text .missing}} {{json .attributes.user}}`:        `null {"id":7,"name":"ann"}`,
+		`{{range $k, $v := .attributes.user}}{{$k}};{{end}}`: "id;name;",
+	}
+	for format, want := range tests {
+		got, err := (*cliTemplates)(nil).format(entry, format)
+		assert.NoError(t, err, format)
+		assert.Equal(t, want, got, format)
+	}
+
+	_, err := (*cliTemplates)(nil).format(entry, `{{duration .message}}`)
+	assert.Error(t, err)
+	_, err = (*cliTemplates)(nil).format(entry, `{{durationMs .attributes.took}}`)
+	assert.Error(t, err)
+	_, err = (*cliTemplates)(nil).format(entry, `{{.message`)
+	assert.Error(t, err)
+	_, err = (*cliTemplates)(nil).format(`not json`, `{{.message}}`)
+	assert.Error(t, err)
+}
+
+func TestCliTemplates_CompiledOnce(t *testing.T) {
+	templates := newCliTemplates(&CliConfig{FormatMode: CliFormatModeTemplate, FriendlyFormat: `{{.message}}`, VerboseFormat: `{{.bad`})
+	assert.Len(t, templates.compiled, 1)
+	assert.Len(t, templates.errs, 1)
+
+	first, err := templates.compile(`{{.message}}`)
+	assert.NoError(t, err)
+	second, _ := templates.compile(`{{.message}}`)
+	assert.Same(t, first, second)
+
+	_, err = templates.compile(`{{.level}}`)
+	assert.NoError(t, err)
+	assert.Len(t, templates.compiled, 2)
+
+	assert.Nil(t, newCliTemplates(&CliConfig{}))
+	assert.Nil(t, newCliTemplates(nil))
+}
+
+func TestMangoLogger_TemplateMode(t *testing.T) {
+	logger := NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled: true,
+			File:    &FileOutputConfig{},
+			Cli:     &CliConfig{Enabled: true, Friendly: true, FormatMode: CliFormatModeTemplate},
+			Syslog:  &SyslogConfig{},
+		},
+		MangoConfig: &MangoConfig{CorrelationId: &CorrelationIdConfig{AutoGenerate: true}},
+	})
+	assert.Equal(t, DefaultFriendlyTemplate, logger.Config.Out.Cli.FriendlyFormat)
+	assert.Equal(t, DefaultVerboseTemplate, logger.Config.Out.Cli.VerboseFormat)
+
+	log := &StructuredLog{Level: slog.LevelInfo, Message: "hello", Attributes: map[string]interface{}{"a": 1}}
+	jsonOut, err := json.Marshal(log)
+	assert.NoError(t, err)
+	want, _ := formatWithGoJQ(string(jsonOut), DefaultFriendlyFormat)
+	assert.Equal(t, want, logger.formatCli(string(jsonOut), logger.Config.Out.Cli.FriendlyFormat))
+
+	logger.Config.Out.Cli.FriendlyFormat = `{{.nope`
+	ctx := context.WithValue(context.Background(), TYPE, BusinessType)
+	_ = logger.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelInfo, "bad format", 0))
+	assert.Equal(t, uint64(1), logger.Metrics().Snapshot().FormatErrors[OutputCli])
+}
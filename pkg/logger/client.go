@@ -0,0 +1,238 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Headers set on the outbound requests
+const (
+	// CorrelationIdHeader carries CORRELATION_ID to the called service
+	CorrelationIdHeader = "X-Correlation-Id"
+
+	// TraceparentHeader carries TRACE_ID and SPAN_ID as W3C trace context
+	TraceparentHeader = "traceparent"
+)
+
+// Attributes of the Performance entries logged for the client calls, besides durationMs and outcome
+const (
+	HostKey            = "host"
+	MethodKey          = "method"
+	PathKey            = "path"
+	StatusKey          = "status"
+	RetriesKey         = "retries"
+	RequestHeadersKey  = "requestHeaders"
+	ResponseHeadersKey = "responseHeaders"
+)
+
+// Redacted replaces the values of the redacted headers
+const Redacted = "[REDACTED]"
+
+// DefaultRedactedHeaders are the headers whose value is never logged, unless WithRedactedHeaders says otherwise
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// TransportOption customises the transport returned by NewTransport
+type TransportOption func(*transport)
+
+// WithTransportLogger logs the client calls with logger instead of slog.Default()
+func WithTransportLogger(logger *slog.Logger) TransportOption {
+	return func(t *transport) {
+		t.logger = logger
+	}
+}
+
+// WithRedactedHeaders replaces DefaultRedactedHeaders, the names are case insensitive
+func WithRedactedHeaders(names ...string) TransportOption {
+	return func(t *transport) {
+		t.redacted = canonicalHeaders(names)
+	}
+}
+
+// WithCorrelationIdHeader sets the correlation id in header instead of CorrelationIdHeader
+func WithCorrelationIdHeader(header string) TransportOption {
+	return func(t *transport) {
+		t.correlationIdHeader = header
+	}
+}
+
+// WithRetries retries a call failing with a network error or a 429, 502, 503 or 504 status up to retries times,
+// waiting backoff, doubled at each retry. Only the idempotent requests whose body can be sent again are retried
+func WithRetries(retries int, backoff time.Duration) TransportOption {
+	return func(t *transport) {
+		t.retries = retries
+		t.backoff = backoff
+	}
+}
+
+// transport propagates the correlation id and trace context, and logs each call
+type transport struct {
+	next                http.RoundTripper
+	logger              *slog.Logger
+	redacted            []string
+	correlationIdHeader string
+	retries             int
+	backoff             time.Duration
+	now                 func() time.Time
+}
+
+// NewTransport wraps next, http.DefaultTransport when nil, into a RoundTripper that sets the CORRELATION_ID,
+// TRACE_ID and SPAN_ID of the request context as headers and logs a Performance entry per call:
+//
+//	client := &http.Client{Transport: mangolog.NewTransport(nil, mangolog.WithRetries(2, 100*time.Millisecond))}
+//	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://pricing.internal/quote", nil)
+//	resp, err := client.Do(req)
+//
+// Headers already set on the request are left untouched
+func NewTransport(next http.RoundTripper, options ...TransportOption) http.RoundTripper {
+	t := &transport{
+		next:                next,
+		redacted:            canonicalHeaders(DefaultRedactedHeaders),
+		correlationIdHeader: CorrelationIdHeader,
+		now:                 time.Now,
+	}
+	if t.next == nil {
+		t.next = http.DefaultTransport
+	}
+	for _, option := range options {
+		option(t)
+	}
+	return t
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	req = req.Clone(ctx) // a RoundTripper must not modify the request
+	t.propagate(ctx, req.Header)
+
+	start := t.now()
+	resp, err := t.next.RoundTrip(req)
+	retries := 0
+	for ; retries < t.retries && t.retryable(req, resp, err); retries++ {
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		backoff := time.NewTimer(t.backoff << retries)
+		select {
+		case <-ctx.Done():
+			backoff.Stop()
+			t.log(ctx, req, nil, ctx.Err(), t.now().Sub(start), retries)
+			return nil, ctx.Err()
+		case <-backoff.C:
+		}
+		if req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				t.log(ctx, req, nil, bodyErr, t.now().Sub(start), retries)
+				return nil, bodyErr
+			}
+			req.Body = body
+		}
+		resp, err = t.next.RoundTrip(req)
+	}
+
+	t.log(ctx, req, resp, err, t.now().Sub(start), retries)
+	return resp, err
+}
+
+func (t *transport) propagate(ctx context.Context, header http.Header) {
+	if value, ok := ctx.Value(CORRELATION_ID).(string); ok && value != "" && header.Get(t.correlationIdHeader) == "" {
+		header.Set(t.correlationIdHeader, value)
+	}
+	traceId, _ := ctx.Value(TRACE_ID).(string)
+	spanId, _ := ctx.Value(SPAN_ID).(string)
+	if traceId != "" && spanId != "" && header.Get(TraceparentHeader) == "" {
+		header.Set(TraceparentHeader, fmt.Sprintf("00-%s-%s-01", traceId, spanId))
+	}
+}
+
+// retryable is true for the failures worth another attempt, when the request can be sent again
+func (t *transport) retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+	default:
+		return false
+	}
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *transport) log(ctx context.Context, req *http.Request, resp *http.Response, err error, duration time.Duration, retries int) {
+	logger := t.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	call := req.Method + " " + req.URL.Host
+	if parent, ok := ctx.Value(timerCtxKey{}).(*timer); ok {
+		parent.addPhase(call, duration)
+	}
+
+	level := slog.LevelInfo
+	attrs := []slog.Attr{
+		slog.String(HostKey, req.URL.Host),
+		slog.String(MethodKey, req.Method),
+		slog.String(PathKey, req.URL.Path),
+	}
+	message := call + req.URL.Path
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, Outcome(OutcomeFailure), slog.String("error", err.Error()))
+		message = fmt.Sprintf("%s failed after %s", message, duration.Round(time.Microsecond))
+	} else {
+		outcome := OutcomeSuccess
+		if resp.StatusCode >= http.StatusInternalServerError {
+			level = slog.LevelWarn
+			outcome = OutcomeFailure
+		}
+		attrs = append(attrs, slog.Int(StatusKey, resp.StatusCode), Outcome(outcome))
+		message = fmt.Sprintf("%s returned %d in %s", message, resp.StatusCode, duration.Round(time.Microsecond))
+	}
+	attrs = append(attrs,
+		slog.Float64(DurationMsKey, milliseconds(duration)),
+		slog.Int(RetriesKey, retries),
+		slog.Any(RequestHeadersKey, t.headers(req.Header)),
+	)
+	if resp != nil {
+		attrs = append(attrs, slog.Any(ResponseHeadersKey, t.headers(resp.Header)))
+	}
+
+	logger.LogAttrs(context.WithValue(ctx, TYPE, PerformanceType), level, message, attrs...)
+}
+
+// headers to log, with the values of the redacted ones replaced
+func (t *transport) headers(header http.Header) map[string]interface{} {
+	logged := make(map[string]interface{}, len(header))
+	for name, values := range header {
+		if slices.Contains(t.redacted, http.CanonicalHeaderKey(name)) {
+			logged[name] = Redacted
+		} else {
+			logged[name] = strings.Join(values, ", ")
+		}
+	}
+	return logged
+}
+
+func canonicalHeaders(names []string) []string {
+	canonical := make([]string, 0, len(names))
+	for _, name := range names {
+		canonical = append(canonical, http.CanonicalHeaderKey(name))
+	}
+	return canonical
+}
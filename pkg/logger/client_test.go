@@ -0,0 +1,207 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransport_PropagatesAndLogs(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	h := &recordingHandler{}
+	client := &http.Client{Transport: NewTransport(nil, WithTransportLogger(slog.New(h)))}
+	ctx := context.WithValue(context.Background(), CORRELATION_ID, "a52b0129-9d49-4f29-acbb-3575aa4442f4")
+	ctx = context.WithValue(ctx, TRACE_ID, "4bf92f3577b34da6a3ce929d0e0e4736")
+	ctx = context.WithValue(ctx, SPAN_ID, "00f067aa0ba902b7")
+	ctx = context.WithValue(ctx, OPERATION, "checkout")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/orders", strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "a52b0129-9d49-4f29-acbb-3575aa4442f4", received.Get(CorrelationIdHeader))
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", received.Get(TraceparentHeader))
+	assert.Empty(t, req.Header.Get(CorrelationIdHeader), "the caller's request is left untouched")
+
+	assert.Len(t, h.records, 1)
+	assert.Equal(t, slog.LevelInfo, h.records[0].Level)
+	assert.True(t, strings.HasPrefix(h.records[0].Message, "POST "+req.URL.Host+"/orders returned 201 in "), h.records[0].Message)
+	assert.Equal(t, PerformanceType, h.contexts[0].Value(TYPE))
+	assert.Equal(t, "checkout", h.contexts[0].Value(OPERATION))
+	attrs := h.attrs(0)
+	assert.Equal(t, req.URL.Host, attrs[HostKey])
+	assert.Equal(t, http.MethodPost, attrs[MethodKey])
+	assert.Equal(t, "/orders", attrs[PathKey])
+	assert.Equal(t, int64(http.StatusCreated), attrs[StatusKey])
+	assert.Equal(t, OutcomeSuccess, attrs[OutcomeKey])
+	assert.Equal(t, int64(0), attrs[RetriesKey])
+	assert.Contains(t, attrs, DurationMsKey)
+	requestHeaders := attrs[RequestHeadersKey].(map[string]interface{})
+	assert.Equal(t, Redacted, requestHeaders["Authorization"])
+	assert.Equal(t, "a52b0129-9d49-4f29-acbb-3575aa4442f4", requestHeaders[CorrelationIdHeader])
+	assert.Equal(t, Redacted, attrs[ResponseHeadersKey].(map[string]interface{})["Set-Cookie"])
+}
+
+func TestTransport_KeepsHeadersSet(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	h := &recordingHandler{}
+	client := &http.Client{Transport: NewTransport(http.DefaultTransport,
+		WithTransportLogger(slog.New(h)), WithCorrelationIdHeader("X-Request-Id"), WithRedactedHeaders("x-tenant"))}
+	ctx := context.WithValue(context.Background(), CORRELATION_ID, "from-context")
+	ctx = context.WithValue(ctx, TRACE_ID, "4bf92f3577b34da6a3ce929d0e0e4736") // no SPAN_ID, no traceparent
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	req.Header.Set("X-Request-Id", "already-set")
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("Authorization", "Bearer token")
+
+	_, err := client.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "already-set", received.Get("X-Request-Id"))
+	assert.Empty(t, received.Get(TraceparentHeader))
+	requestHeaders := h.attrs(0)[RequestHeadersKey].(map[string]interface{})
+	assert.Equal(t, Redacted, requestHeaders["X-Tenant"])
+	assert.Equal(t, "Bearer token", requestHeaders["Authorization"])
+}
+
+func TestTransport_Retries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	h := &recordingHandler{}
+	client := &http.Client{Transport: NewTransport(nil, WithTransportLogger(slog.New(h)), WithRetries(3, time.Millisecond))}
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Len(t, h.records, 1)
+	assert.Equal(t, int64(2), h.attrs(0)[RetriesKey])
+
+	// a POST is not idempotent
+	calls.Store(0)
+	resp, err = client.Post(server.URL, "application/json", strings.NewReader("{}"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, slog.LevelWarn, h.records[1].Level)
+	assert.Equal(t, OutcomeFailure, h.attrs(1)[OutcomeKey])
+
+	// a PUT whose body can be sent again is retried
+	calls.Store(0)
+	req, _ := http.NewRequest(http.MethodPut, server.URL, strings.NewReader("{}"))
+	resp, err = client.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+type failingTransport struct{ calls int }
+
+func (f *failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	f.calls++
+	return nil, errors.New("connection refused")
+}
+
+func TestTransport_Error(t *testing.T) {
+	h := &recordingHandler{}
+	next := &failingTransport{}
+	transport := NewTransport(next, WithTransportLogger(slog.New(h)), WithRetries(1, 0))
+	req, _ := http.NewRequest(http.MethodGet, "http://pricing.internal/quote", nil)
+
+	_, err := transport.RoundTrip(req)
+	assert.EqualError(t, err, "connection refused")
+	assert.Equal(t, 2, next.calls)
+	assert.Equal(t, slog.LevelError, h.records[0].Level)
+	assert.True(t, strings.HasPrefix(h.records[0].Message, "GET pricing.internal/quote failed after "), h.records[0].Message)
+	attrs := h.attrs(0)
+	assert.Equal(t, OutcomeFailure, attrs[OutcomeKey])
+	assert.Equal(t, "connection refused", attrs["error"])
+	assert.Equal(t, int64(1), attrs[RetriesKey])
+	assert.NotContains(t, attrs, ResponseHeadersKey)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "http://pricing.internal/quote", nil)
+	next.calls = 0
+	_, err = transport.RoundTrip(req)
+	assert.Error(t, err)
+	assert.Equal(t, 1, next.calls, "a cancelled call is not retried")
+}
+
+func TestTransport_GetBodyFailure(t *testing.T) {
+	h := &recordingHandler{}
+	next := &failingTransport{}
+	transport := NewTransport(next, WithTransportLogger(slog.New(h)), WithRetries(1, 0))
+	req, _ := http.NewRequest(http.MethodPut, "http://pricing.internal/quote", strings.NewReader("{}"))
+	req.GetBody = func() (io.ReadCloser, error) { return nil, errors.New("body gone") }
+
+	_, err := transport.RoundTrip(req)
+	assert.EqualError(t, err, "body gone")
+	assert.Equal(t, 1, next.calls)
+	if assert.Len(t, h.records, 1, "the failed call is logged") {
+		assert.Equal(t, slog.LevelError, h.records[0].Level)
+		assert.Equal(t, "body gone", h.attrs(0)["error"])
+		assert.Equal(t, int64(0), h.attrs(0)[RetriesKey])
+	}
+}
+
+func TestTransport_CancelledDuringBackoff(t *testing.T) {
+	h := &recordingHandler{}
+	next := &failingTransport{}
+	transport := NewTransport(next, WithTransportLogger(slog.New(h)), WithRetries(3, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://pricing.internal/quote", nil)
+
+	_, err := transport.RoundTrip(req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, next.calls)
+	if assert.Len(t, h.records, 1, "the cancelled call is logged") {
+		assert.Equal(t, slog.LevelError, h.records[0].Level)
+		attrs := h.attrs(0)
+		assert.Equal(t, OutcomeFailure, attrs[OutcomeKey])
+		assert.Equal(t, context.DeadlineExceeded.Error(), attrs["error"])
+		assert.Equal(t, int64(0), attrs[RetriesKey])
+	}
+}
+
+func TestTransport_PhaseOfTimer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	h := &recordingHandler{}
+	ctx, done := StartTimerContext(context.Background(), "checkout", WithLogger(slog.New(h)))
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	_, err := (&http.Client{Transport: NewTransport(nil, WithTransportLogger(slog.New(h)))}).Do(req)
+	assert.NoError(t, err)
+	done()
+
+	phases := h.attrs(1)[PhasesKey].(map[string]interface{})
+	assert.Contains(t, phases, "GET "+req.URL.Host)
+}
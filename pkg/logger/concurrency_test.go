@@ -0,0 +1,222 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	stressGoroutines = 200
+	stressEntries    = 25
+)
+
+// discardSink drops the entries, for the benchmarks
+type discardSink struct{}
+
+func (discardSink) WriteEntry(StructuredLog) error { return nil }
+
+// silenceCli sends the CLI output to /dev/null for the duration of the test
+func silenceCli(t testing.TB) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	assert.NoError(t, err)
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = devNull, devNull
+	t.Cleanup(func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		_ = devNull.Close()
+	})
+}
+
+func newStressLogger(path string, sink EntrySink) *MangoLogger {
+	return NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled: true,
+			File:    &FileOutputConfig{Enabled: true, Debug: true, Path: path},
+			Cli:     &CliConfig{Enabled: true, Friendly: true, Verbose: true, FormatMode: CliFormatModeTemplate},
+			Syslog:  &SyslogConfig{},
+			Sink:    sink,
+		},
+		MangoConfig: &MangoConfig{
+			Strict:        true,
+			CorrelationId: &CorrelationIdConfig{Strict: true, AutoGenerate: true},
+			Failure:       &FailureConfig{Strict: FailurePolicyEmit},
+			Audit:         &AuditConfig{Enabled: true, Key: "stress"},
+			Limits:        &LimitsConfig{MaxStringLength: 64, MaxAttributes: 16},
+			Service:       &ServiceConfig{Application: "stress", Environment: "test", Placement: ServicePlacementTopLevel},
+			Encryption:    &EncryptionConfig{Enabled: true, Paths: []string{"req.user"}, KeyProvider: testKeys},
+			IdFormat:      IdFormatULID,
+		},
+	})
+}
+
+func TestMangoLogger_ConcurrentHandle(t *testing.T) {
+	silenceCli(t)
+	path := filepath.Join(t.TempDir(), "app.log")
+	sink := &sliceSink{}
+	handler := newStressLogger(path, sink)
+	base := slog.New(handler).With("service", "stress")
+	requiredBefore := slices.Clone(REQUIRED_FIELDS)
+
+	var wg sync.WaitGroup
+	for g := range stressGoroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger := base.With("worker", g).WithGroup("req")
+			ctx := context.WithValue(context.WithValue(context.WithValue(context.Background(),
+				TYPE, []string{BusinessType, SecurityType, PerformanceType}[g%3]), OPERATION, "stress"), CORRELATION_ID, fmt.Sprint("c-", g))
+			if g%10 == 0 {
+				ctx = context.Background() // violations, emitted
+			}
+			for i := range stressEntries {
+				switch i % 4 {
+				case 0:
+					logger.DebugContext(ctx, "debug", "i", i, "user", "ann@example.com")
+				case 1:
+					logger.With("step", i).InfoContext(ctx, "info", "elapsed", time.Duration(i), slog.Group("g", "k", "v"))
+				case 2:
+					logger.WarnContext(ctx, "warn", "long", string(bytes.Repeat([]byte("x"), 100)))
+				default:
+					logger.ErrorContext(ctx, "error", "err", fmt.Errorf("failure %d", i))
+				}
+			}
+		}()
+	}
+	// the read side runs alongside the writers
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 50 {
+			_ = handler.Metrics().Snapshot()
+			_ = handler.Flush(context.Background())
+		}
+	}()
+	wg.Wait()
+	assert.NoError(t, handler.Close(context.Background()))
+
+	const total = stressGoroutines * stressEntries
+	assert.Len(t, sink.entries, total)
+	assert.Equal(t, requiredBefore, REQUIRED_FIELDS)
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer func() { _ = file.Close() }()
+	lines, logIds := 0, map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+		assert.NoError(t, ValidateLogLine(scanner.Bytes()))
+		assert.NotContains(t, scanner.Text(), "ann@example.com")
+	}
+	assert.Equal(t, total, lines)
+	sealed := 0
+	for _, entry := range sink.entries {
+		logIds[entry.LogId] = true
+		if entry.Type == SecurityType {
+			sealed++
+		}
+	}
+	assert.Len(t, logIds, total)
+
+	_, err = file.Seek(0, 0)
+	assert.NoError(t, err)
+	report, err := VerifyAuditLog(file, []byte("stress"))
+	assert.NoError(t, err)
+	assert.Zero(t, report.BrokenLine, report.BrokenReason)
+	assert.Empty(t, report.Gaps)
+	assert.Equal(t, sealed, report.Entries)
+}
+
+func TestHandleRequiredFields_SharedGlobal(t *testing.T) {
+	requiredBefore := slices.Clone(REQUIRED_FIELDS)
+	strict := newSinkTestLogger(&sliceSink{})
+	strict.Config.MangoConfig.CorrelationId = &CorrelationIdConfig{Strict: true}
+	assert.Error(t, strict.handleRequiredFields(context.Background(), &StructuredLog{}))
+	assert.Equal(t, requiredBefore, REQUIRED_FIELDS)
+
+	lenient := newSinkTestLogger(&sliceSink{})
+	lenient.Config.MangoConfig.CorrelationId = &CorrelationIdConfig{}
+	assert.NoError(t, lenient.handleRequiredFields(context.Background(), &StructuredLog{}))
+}
+
+// benchAttrs are count attributes of the usual kinds
+func benchAttrs(count int) []any {
+	attrs := make([]any, 0, count)
+	for i := range count {
+		switch i % 4 {
+		case 0:
+			attrs = append(attrs, slog.String(fmt.Sprint("s", i), "value"))
+		case 1:
+			attrs = append(attrs, slog.Int(fmt.Sprint("n", i), i))
+		case 2:
+			attrs = append(attrs, slog.Duration(fmt.Sprint("d", i), time.Millisecond))
+		default:
+			attrs = append(attrs, slog.Group(fmt.Sprint("g", i), "k", "v", "n", i))
+		}
+	}
+	return attrs
+}
+
+func benchContext() context.Context {
+	return context.WithValue(context.WithValue(context.WithValue(context.WithValue(context.Background(),
+		TYPE, BusinessType), APPLICATION, "bench"), OPERATION, "checkout"), CORRELATION_ID, "a52b0129-9d49-4f29-acbb-3575aa4442f4")
+}
+
+func BenchmarkMangoLogger_Handle(b *testing.B) {
+	for _, count := range []int{0, 5, 10, 20} {
+		b.Run(fmt.Sprint("attrs=", count), func(b *testing.B) {
+			logger := slog.New(newSinkTestLogger(discardSink{})).With(benchAttrs(count / 2)...)
+			attrs, ctx := benchAttrs(count-count/2), benchContext()
+			b.ReportAllocs()
+			for b.Loop() {
+				logger.InfoContext(ctx, "cart created", attrs...)
+			}
+		})
+	}
+}
+
+func BenchmarkMangoLogger_HandleParallel(b *testing.B) {
+	handler := NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled: true,
+			File:    &FileOutputConfig{Enabled: true, Path: filepath.Join(b.TempDir(), "app.log")},
+			Cli:     &CliConfig{},
+			Syslog:  &SyslogConfig{},
+		},
+		MangoConfig: &MangoConfig{CorrelationId: &CorrelationIdConfig{AutoGenerate: true}},
+	})
+	b.Cleanup(func() { _ = handler.Close(context.Background()) })
+	base, attrs, ctx := slog.New(handler), benchAttrs(5), benchContext()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		logger := base.With(benchAttrs(5)...)
+		for pb.Next() {
+			logger.InfoContext(ctx, "cart created", attrs...)
+		}
+	})
+}
+
+func BenchmarkMangoLogger_HandleAllFeatures(b *testing.B) {
+	silenceCli(b)
+	handler := newStressLogger(filepath.Join(b.TempDir(), "app.log"), discardSink{})
+	b.Cleanup(func() { _ = handler.Close(context.Background()) })
+	logger, ctx := slog.New(handler).With(benchAttrs(5)...).WithGroup("req"), benchContext()
+	attrs := append(benchAttrs(4), "user", "ann@example.com")
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			logger.InfoContext(ctx, "cart created", attrs...)
+		}
+	})
+}
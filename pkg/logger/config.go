@@ -1,6 +1,12 @@
 // Package logger is a specific logging library on top of slog with additional goodness
 package logger
 
+import (
+	"io"
+	"log/slog"
+	"time"
+)
+
 // Default output formats
 const (
 	// DefaultVerboseFormat is the default format for verbose (DEBUG to stdout) output
@@ -8,6 +14,63 @@ const (
 
 	// DefaultFriendlyFormat is the default format for all CLI friendly output (INFO and above to stdout)
 	DefaultFriendlyFormat = `"[\(.level)] - \(.ts) - \(.operation) - \(.message) - \(.attributes)"`
+
+	// DefaultVerboseTemplate is the text/template equivalent of DefaultVerboseFormat
+	DefaultVerboseTemplate = `{{json .}}`
+
+	// DefaultFriendlyTemplate is the text/template equivalent of DefaultFriendlyFormat
+	DefaultFriendlyTemplate = `{{quote (print "[" (text .level) "] - " (text .ts) " - " (text .operation) " - " (text .message) " - " (text .attributes))}}`
+)
+
+// Cli format modes
+const (
+	// CliFormatModeJq reads FriendlyFormat and VerboseFormat as jq expressions, the default
+	CliFormatModeJq = "jq"
+
+	// CliFormatModeTemplate reads FriendlyFormat and VerboseFormat as Go text/templates
+	CliFormatModeTemplate = "template"
+)
+
+// Http output batch formats
+const (
+	// HttpFormatNDJSON sends each batch as newline delimited JSON (one StructuredLog per line)
+	HttpFormatNDJSON = "ndjson"
+
+	// HttpFormatJSONArray sends each batch as a JSON array of StructuredLog
+	HttpFormatJSONArray = "json-array"
+)
+
+// Otlp export protocols
+const (
+	// OtlpProtocolProtobuf exports ExportLogsServiceRequest messages in the protobuf binary encoding
+	OtlpProtocolProtobuf = "http/protobuf"
+
+	// OtlpProtocolJSON exports ExportLogsServiceRequest messages in the OTLP JSON encoding
+	OtlpProtocolJSON = "http/json"
+)
+
+// Locations of the service metadata in the entries
+const (
+	// ServicePlacementResource nests the service metadata under "resource", the default
+	ServicePlacementResource = "resource"
+
+	// ServicePlacementTopLevel adds the service metadata fields at the top level of the entries
+	ServicePlacementTopLevel = "top-level"
+)
+
+// Duration attribute encodings
+const (
+	// DurationFormatNanoseconds encodes durations as an integer number of nanoseconds, the default
+	DurationFormatNanoseconds = "nanoseconds"
+
+	// DurationFormatString encodes durations as strings, e.g. "1.5s"
+	DurationFormatString = "string"
+)
+
+// File time based rotation periods
+const (
+	FileRotationDaily  = "daily"
+	FileRotationHourly = "hourly"
 )
 
 type SyslogFacility string
@@ -49,6 +112,30 @@ type MangoConfig struct {
 
 	// CorrelationId configuration
 	CorrelationId *CorrelationIdConfig `yaml:"correlation-id" json:"correlationId"`
+
+	// Audit configuration of the tamper-evident chain of Security entries
+	Audit *AuditConfig `yaml:"audit" json:"audit"`
+
+	// Failure configures what happens to entries failing strict validation or an output
+	Failure *FailureConfig `yaml:"failure" json:"failure"`
+
+	// DurationFormat of the time.Duration attributes, DurationFormatNanoseconds (default) or DurationFormatString
+	DurationFormat string `yaml:"duration-format" json:"durationFormat"`
+
+	// Limits on the size of the entries, truncating what exceeds them
+	Limits *LimitsConfig `yaml:"limits" json:"limits"`
+
+	// Service describes the service once, rather than in every context
+	Service *ServiceConfig `yaml:"service" json:"service"`
+
+	// Encryption of the attributes holding personal data, in every output
+	Encryption *EncryptionConfig `yaml:"encryption" json:"encryption"`
+
+	// IdFormat of the LogId of the entries and the generated correlation ids, one of the IdFormat constants
+	IdFormat string `yaml:"id-format" json:"idFormat"`
+
+	// IdGenerator of the LogId of the entries and the generated correlation ids (Go config only), taking precedence over IdFormat
+	IdGenerator IDGenerator `yaml:"-" json:"-"`
 }
 
 // OutConfig provides a structure for defining the configuration of all the logging output
@@ -64,6 +151,24 @@ type OutConfig struct {
 
 	// Syslog configuration node for Syslog output options
 	Syslog *SyslogConfig `yaml:"syslog" json:"syslog"`
+
+	// Http configuration node for shipping batches of logs to an HTTP endpoint
+	Http *HttpOutputConfig `yaml:"http" json:"http"`
+
+	// Otlp configuration node for exporting logs to an OpenTelemetry collector
+	Otlp *OtlpOutputConfig `yaml:"otlp" json:"otlp"`
+
+	// Journald configuration node for writing to the systemd journal with its native protocol (linux only)
+	Journald *JournaldConfig `yaml:"journald" json:"journald"`
+
+	// Sink receives every entry, whatever its level (Go config only) - e.g. a logtest.Recorder
+	Sink EntrySink `yaml:"-" json:"-"`
+}
+
+// EntrySink is an output receiving the entries as StructuredLog values rather than json
+type EntrySink interface {
+	// WriteEntry receives a copy of the entry, it must be safe for concurrent use
+	WriteEntry(log StructuredLog) error
 }
 
 // CorrelationIdConfig defines the configuration of correlationId across mangologger
@@ -74,6 +179,144 @@ type CorrelationIdConfig struct {
 	// AutoGenerate will generate a correlationId if missing from context
 	// This will NOT be generated BEFORE REQUIRED_FIELDS restriction, therefore if correlationId is missing in a strict setup, it will fail regardless of auto-generate flag
 	AutoGenerate bool `yaml:"auto-generate" json:"autoGenerate"`
+
+	// Format of the generated correlation ids, one of the IdFormat constants - The id-format of the entries by default
+	Format string `yaml:"format" json:"format"`
+
+	// Generator of the correlation ids (Go config only), taking precedence over Format
+	Generator IDGenerator `yaml:"-" json:"-"`
+
+	// Normalise trims the spaces around the incoming correlation ids and lowercases them, before they are checked
+	// The built-in patterns accept the lowercased ids, a custom Pattern should too
+	Normalise bool `yaml:"normalise" json:"normalise"`
+
+	// MaxLength in characters of the incoming correlation ids, unlimited when 0
+	MaxLength int `yaml:"max-length" json:"maxLength"`
+
+	// Pattern the incoming correlation ids must match, e.g. CorrelationIdPatternUUID
+	Pattern string `yaml:"pattern" json:"pattern"`
+
+	// OnInvalid incoming correlation id, CorrelationIdInvalidReplace (default) or CorrelationIdInvalidReject
+	OnInvalid string `yaml:"on-invalid" json:"onInvalid"`
+}
+
+// LimitsConfig defines the maximum sizes of the entries, 0 leaving a size unlimited
+// Truncated entries are flagged with "truncated": true and their original sizes under "originalSizes"
+type LimitsConfig struct {
+	// MaxMessageLength in bytes, longer messages are cut
+	MaxMessageLength int `yaml:"max-message-length" json:"maxMessageLength"`
+
+	// MaxStringLength in bytes of each string attribute, longer strings are cut
+	MaxStringLength int `yaml:"max-string-length" json:"maxStringLength"`
+
+	// MaxAttributes is the number of top level attributes kept, the handler ones first
+	MaxAttributes int `yaml:"max-attributes" json:"maxAttributes"`
+
+	// MaxDepth of the nested groups, a group deeper than that is replaced by its json as a string
+	MaxDepth int `yaml:"max-depth" json:"maxDepth"`
+
+	// MaxEntryBytes of the json entry (before the audit fields), the last attributes then the message are cut to fit
+	MaxEntryBytes int `yaml:"max-entry-bytes" json:"maxEntryBytes"`
+}
+
+// EncryptionConfig defines the attributes encrypted with AES-GCM envelope encryption
+// Each value is encrypted with its own data key, itself encrypted with the current key of the KeyProvider
+type EncryptionConfig struct {
+	// Enabled switches on the encryption of the Paths
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Paths of the attributes to encrypt, nested groups joined with dots, e.g. customer.email
+	// A path naming a group encrypts the whole group
+	Paths []string `yaml:"paths" json:"paths"`
+
+	// KeyProvider supplies the keys (Go config only) - Without one the Paths are redacted
+	KeyProvider KeyProvider `yaml:"-" json:"-"`
+}
+
+// ServiceConfig defines the static metadata added to every entry
+type ServiceConfig struct {
+	// Application of the entries without APPLICATION in their context, which strict mode then doesn't require
+	Application string `yaml:"application" json:"application"`
+
+	// Environment the service runs in, e.g. prod
+	Environment string `yaml:"environment" json:"environment"`
+
+	// Region the service runs in, e.g. eu-west-1
+	Region string `yaml:"region" json:"region"`
+
+	// Version of the service
+	Version string `yaml:"version" json:"version"`
+
+	// Hostname of the service
+	Hostname string `yaml:"hostname" json:"hostname"`
+
+	// Commit the service was built from
+	Commit string `yaml:"commit" json:"commit"`
+
+	// AutoDetect fills the hostname, pid, version and commit left empty, the version and commit from the build info
+	AutoDetect bool `yaml:"auto-detect" json:"autoDetect"`
+
+	// Placement of the metadata in the entries, ServicePlacementResource (default) or ServicePlacementTopLevel
+	Placement string `yaml:"placement" json:"placement"`
+}
+
+// AuditConfig defines the hash chain sealing each Security entry
+// Every sealed entry carries auditSeq, auditPrev (the auditMac of the previous sealed entry) and auditMac,
+// an HMAC-SHA256 of the entry keyed with Key. Use VerifyAuditLog to check a log against the key
+type AuditConfig struct {
+	// Enabled switches on sealing of Security entries
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Key of the HMAC, required - Keep it out of the log readers' reach, e.g. supply it from a secret store or environment
+	Key string `yaml:"key" json:"key"`
+
+	// StatePath is a file keeping the last sequence number and mac, so the chain continues across restarts
+	// Without it the chain restarts at sequence 1 on each start
+	StatePath string `yaml:"state-path" json:"statePath"`
+}
+
+// Failure policies of FailureConfig
+const (
+	// FailurePolicyReturn returns the error to the caller, the default and original behaviour
+	FailurePolicyReturn = "return"
+
+	// FailurePolicyDrop drops the entry (strict) or ignores the failed output, after a rate limited diagnostic
+	FailurePolicyDrop = "drop"
+
+	// FailurePolicyEmit writes the entry to the outputs anyway with its violations (strict) or ignores the failed output
+	FailurePolicyEmit = "emit"
+
+	// FailurePolicyFallback writes the entry to FailureConfig.Fallback
+	FailurePolicyFallback = "fallback"
+
+	// FailurePolicyHandler passes the entry and the error to FailureConfig.ErrorHandler
+	FailurePolicyHandler = "handler"
+)
+
+// ErrorHandler receives the entries failing strict validation or an output, with the error
+type ErrorHandler func(err error, log *StructuredLog)
+
+// FailureConfig defines the failure policies, each one of the FailurePolicy constants
+type FailureConfig struct {
+	// Strict is the policy for entries failing strict validation
+	Strict string `yaml:"strict" json:"strict"`
+
+	// Output is the policy for entries failing to be written to an output
+	// With any policy but return, the remaining outputs are still written to
+	Output string `yaml:"output" json:"output"`
+
+	// Fallback receives the json entries with the fallback policy (Go config only) - Defaults to os.Stderr
+	Fallback io.Writer `yaml:"-" json:"-"`
+
+	// ErrorHandler is called with the handler policy (Go config only)
+	ErrorHandler ErrorHandler `yaml:"-" json:"-"`
+
+	// DiagnosticInterval is the minimum time between two identical diagnostics printed - Defaults to 1 minute
+	DiagnosticInterval time.Duration `yaml:"diagnostic-interval" json:"diagnosticInterval"`
+
+	// Diagnostics receives the diagnostics (Go config only) - Defaults to os.Stdout
+	// Set it to os.Stderr when stdout is captured as log output
+	Diagnostics io.Writer `yaml:"-" json:"-"`
 }
 
 type FileOutputConfig struct {
@@ -97,8 +340,69 @@ type FileOutputConfig struct {
 
 	// Compress old log files - The default is not to perform compression
 	Compress bool `yaml:"compress" json:"compress"`
+
+	// Rotation adds time based rotation on top of the size based one, one of FileRotationDaily or FileRotationHourly
+	// Rotated files are date stamped with the period they cover, e.g. service-2025-01-15.log or service-2025-01-15T09.log
+	// MaxAge and MaxBackups are applied to the date stamped files as well, MaxAge in calendar days
+	Rotation string `yaml:"rotation" json:"rotation"`
+
+	// LocalTime uses the local time zone for the rotation periods and file names - The default is to use UTC
+	LocalTime bool `yaml:"local-time" json:"localTime"`
+
+	// RotateOnSignal rotates the file when the process receives SIGUSR1 - Not available on Windows
+	RotateOnSignal bool `yaml:"rotate-on-signal" json:"rotateOnSignal"`
+
+	// PostRotate is called in the background with the path of each time based or on-demand rotated file
+	// after it has been compressed (if enabled), e.g. to move or upload it
+	PostRotate RotationHook `yaml:"-" json:"-"`
+
+	// Routes send matching entries to additional files, each with its own rotation settings
+	// An entry is written to every route it matches, and to Path unless one of the matching routes is Exclusive
+	Routes []FileRouteConfig `yaml:"routes" json:"routes"`
+}
+
+// FileRouteConfig defines a file receiving the entries matching its Types and Levels
+type FileRouteConfig struct {
+	// Types of the entries routed to this file, e.g. Security - All types when empty
+	Types []string `yaml:"types" json:"types"`
+
+	// Levels of the entries routed to this file, e.g. ERROR - All levels when empty
+	Levels []slog.Level `yaml:"levels" json:"levels"`
+
+	// Exclusive keeps the matching entries out of the main file, e.g. to isolate the Security stream
+	Exclusive bool `yaml:"exclusive" json:"exclusive"`
+
+	// Debug allows debug entries to be routed to this file
+	Debug bool `yaml:"debug" json:"debug"`
+
+	// Path is the log file name of this route - Required, routes without a path are ignored
+	Path string `yaml:"path" json:"path"`
+
+	// MaxSize in MB before rotating - It defaults to 100 megabytes
+	MaxSize int `yaml:"max-size" json:"maxSize"`
+
+	// MaxBackups is the number of old log files to keep - The default is to retain all old log files
+	MaxBackups int `yaml:"max-backups" json:"maxBackups"`
+
+	// MaxAge is the number of days to keep old log files - The default is not to remove old log files based on age
+	MaxAge int `yaml:"max-age" json:"maxAge"`
+
+	// Compress old log files - The default is not to perform compression
+	Compress bool `yaml:"compress" json:"compress"`
+
+	// Rotation adds time based rotation, one of FileRotationDaily or FileRotationHourly
+	Rotation string `yaml:"rotation" json:"rotation"`
+
+	// LocalTime uses the local time zone for the rotation periods and file names - The default is to use UTC
+	LocalTime bool `yaml:"local-time" json:"localTime"`
+
+	// PostRotate is called with the path of each time based or on-demand rotated file of this route
+	PostRotate RotationHook `yaml:"-" json:"-"`
 }
 
+// RotationHook receives the path of a rotated log file
+type RotationHook func(rotatedPath string)
+
 type CliConfig struct {
 	// Enabled allows stdout/stderr printouts
 	Enabled bool `yaml:"enabled" json:"enabled"`
@@ -117,4 +421,122 @@ type CliConfig struct {
 	// VerboseFormat of the DEBUG statements output in verbose mode
 	// Defaults to print the whole json object of logger.StructuredLog (using DefaultVerboseFormat)
 	VerboseFormat string `yaml:"verbose-format" json:"verboseFormat"`
+
+	// FormatMode is how FriendlyFormat and VerboseFormat are read, CliFormatModeJq (default) or CliFormatModeTemplate
+	FormatMode string `yaml:"format-mode" json:"formatMode"`
+}
+
+// HttpOutputConfig defines the configuration of the batched HTTP log shipping output
+type HttpOutputConfig struct {
+	// Enabled switches on shipping logs to the Endpoint
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Debug allows debug entries to be shipped
+	Debug bool `yaml:"debug" json:"debug"`
+
+	// Endpoint is the URL each batch is POSTed to
+	Endpoint string `yaml:"endpoint" json:"endpoint"`
+
+	// Format of the batch body, one of HttpFormatNDJSON or HttpFormatJSONArray - It defaults to HttpFormatNDJSON
+	Format string `yaml:"format" json:"format"`
+
+	// Gzip compresses the batch body and sets the Content-Encoding header
+	Gzip bool `yaml:"gzip" json:"gzip"`
+
+	// Headers added to every request, e.g. Authorization: Bearer <token> or Authorization: Splunk <token>
+	Headers map[string]string `yaml:"headers" json:"headers"`
+
+	// BatchSize is the number of entries that triggers a flush - It defaults to 100
+	BatchSize int `yaml:"batch-size" json:"batchSize"`
+
+	// MaxBatchBytes is the size of the pending entries in bytes that triggers a flush - It defaults to 1 megabyte
+	MaxBatchBytes int `yaml:"max-batch-bytes" json:"maxBatchBytes"`
+
+	// FlushInterval is the maximum time an entry waits before being shipped - It defaults to 5 seconds
+	FlushInterval time.Duration `yaml:"flush-interval" json:"flushInterval"`
+
+	// Timeout of each request - It defaults to 10 seconds
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+
+	// MaxRetries is the number of retries of a failed batch before spilling it to disk - It defaults to 3
+	MaxRetries int `yaml:"max-retries" json:"maxRetries"`
+
+	// RetryBackoff is the initial wait between retries, doubled (with jitter) on each attempt - It defaults to 500 milliseconds
+	RetryBackoff time.Duration `yaml:"retry-backoff" json:"retryBackoff"`
+
+	// MaxRetryBackoff caps the wait between retries - It defaults to 30 seconds
+	MaxRetryBackoff time.Duration `yaml:"max-retry-backoff" json:"maxRetryBackoff"`
+
+	// SpillDir is the directory batches are written to when the endpoint is down, and replayed from on start
+	// When empty, batches that cannot be delivered are dropped
+	SpillDir string `yaml:"spill-dir" json:"spillDir"`
+
+	// SpillMaxBytes caps the size of the spilled files, the oldest are dropped beyond it - It defaults to 100 megabytes
+	SpillMaxBytes int `yaml:"spill-max-bytes" json:"spillMaxBytes"`
+
+	// SpillMaxFiles caps the number of spilled files, the oldest are dropped beyond it - It defaults to 1000
+	SpillMaxFiles int `yaml:"spill-max-files" json:"spillMaxFiles"`
+}
+
+// JournaldConfig defines the configuration of the systemd journal output
+// Each contract field and attribute of the entries becomes a journal field, e.g. OPERATION or ITEMS
+type JournaldConfig struct {
+	// Enabled switches on writing to the journal
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Debug allows debug entries to be written
+	Debug bool `yaml:"debug" json:"debug"`
+
+	// SocketPath of the journal - It defaults to /run/systemd/journal/socket
+	SocketPath string `yaml:"socket-path" json:"socketPath"`
+
+	// Identifier is the SYSLOG_IDENTIFIER of the entries - It defaults to the Application of each entry
+	Identifier string `yaml:"identifier" json:"identifier"`
+}
+
+// OtlpOutputConfig defines the configuration of the OTLP logs exporter
+// Records are batched, retried and spilled in the same way as the Http output
+type OtlpOutputConfig struct {
+	// Enabled switches on exporting logs to the collector
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Debug allows debug entries to be exported
+	Debug bool `yaml:"debug" json:"debug"`
+
+	// Endpoint is the collector logs URL - It defaults to http://localhost:4318/v1/logs
+	Endpoint string `yaml:"endpoint" json:"endpoint"`
+
+	// Protocol is one of OtlpProtocolProtobuf or OtlpProtocolJSON - It defaults to OtlpProtocolProtobuf
+	Protocol string `yaml:"protocol" json:"protocol"`
+
+	// Gzip compresses the export requests
+	Gzip bool `yaml:"gzip" json:"gzip"`
+
+	// Headers added to every export request
+	Headers map[string]string `yaml:"headers" json:"headers"`
+
+	// ResourceAttributes describe the entity producing the logs, e.g. deployment.environment or service.version
+	// service.name is always taken from the Application of each entry
+	ResourceAttributes map[string]string `yaml:"resource-attributes" json:"resourceAttributes"`
+
+	// BatchSize is the number of records that triggers an export - It defaults to 100
+	BatchSize int `yaml:"batch-size" json:"batchSize"`
+
+	// FlushInterval is the maximum time a record waits before being exported - It defaults to 5 seconds
+	FlushInterval time.Duration `yaml:"flush-interval" json:"flushInterval"`
+
+	// Timeout of each export request - It defaults to 10 seconds
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+
+	// MaxRetries is the number of retries of a failed export - It defaults to 3
+	MaxRetries int `yaml:"max-retries" json:"maxRetries"`
+
+	// SpillDir is the directory exports are written to when the collector is down, and replayed from on start
+	SpillDir string `yaml:"spill-dir" json:"spillDir"`
+
+	// SpillMaxBytes caps the size of the spilled exports, the oldest are dropped beyond it - It defaults to 100 megabytes
+	SpillMaxBytes int `yaml:"spill-max-bytes" json:"spillMaxBytes"`
+
+	// SpillMaxFiles caps the number of spilled exports, the oldest are dropped beyond it - It defaults to 1000
+	SpillMaxFiles int `yaml:"spill-max-files" json:"spillMaxFiles"`
 }
@@ -0,0 +1,265 @@
+package logger
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// EncryptedPrefix starts the encrypted attribute values, written as enc:v1:<key id>:<encrypted data key>:<encrypted value>
+const EncryptedPrefix = "enc:v1:"
+
+// dataKeySize is the size of the AES-256 data key generated for each value
+const dataKeySize = 32
+
+// ErrKeyNotFound is returned by a KeyProvider without the key asked for
+var ErrKeyNotFound = errors.New("key not found")
+
+// KeyProvider supplies the AES keys (16, 24 or 32 bytes) encrypting the data keys, by id
+type KeyProvider interface {
+	// CurrentKey returns the key encrypting new values and its id, recorded with each value
+	CurrentKey() (id string, key []byte, err error)
+
+	// Key returns the key of id, to decrypt the values it encrypted
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeyProvider holds its keys in memory, e.g. loaded from a secret store at startup
+type StaticKeyProvider struct {
+	// CurrentId of the key encrypting new values, may be empty when only decrypting
+	CurrentId string
+
+	// Keys by id, keep the retired ones to decrypt the older entries
+	Keys map[string][]byte
+}
+
+func (p StaticKeyProvider) CurrentKey() (string, []byte, error) {
+	key, err := p.Key(p.CurrentId)
+	return p.CurrentId, key, err
+}
+
+func (p StaticKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := p.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrKeyNotFound, id)
+	}
+	return key, nil
+}
+
+func (c *EncryptionConfig) isEnabled() bool {
+	return c != nil && c.Enabled && len(c.Paths) > 0
+}
+
+// encryptor encrypts the configured attribute paths of each entry
+type encryptor struct {
+	paths       [][]string
+	keys        KeyProvider
+	diagnostics *diagnostics
+}
+
+func newEncryptor(config *EncryptionConfig, diagnostics *diagnostics) *encryptor {
+	if !config.isEnabled() {
+		return nil
+	}
+	e := &encryptor{keys: config.KeyProvider, diagnostics: diagnostics}
+	for _, path := range config.Paths {
+		e.paths = append(e.paths, strings.Split(path, "."))
+	}
+	return e
+}
+
+// apply encrypts the paths present in attributes, redacting them when they can't be encrypted so no plain value is written
+// The nested maps on the way are copied, as they may be a value logged with slog.Any
+// An attribute on the way that isn't a group, e.g. a map[string]string or a struct logged with slog.Any, is encrypted as a whole
+func (e *encryptor) apply(attributes map[string]interface{}) error {
+	if e == nil || len(attributes) == 0 {
+		return nil
+	}
+	var id string
+	var key []byte
+	err := errors.New("no KeyProvider configured")
+	if e.keys != nil {
+		id, key, err = e.keys.CurrentKey()
+	}
+	if err == nil && strings.Contains(id, ":") {
+		err = fmt.Errorf("key id %q contains a colon", id)
+	}
+
+	var errs []error
+	for _, path := range e.paths {
+		parent, last := attributes, len(path)-1
+		for i, name := range path[:len(path)-1] {
+			value, ok := parent[name]
+			if !ok {
+				parent = nil
+				break
+			}
+			nested, ok := value.(map[string]interface{})
+			if !ok {
+				last = i
+				break
+			}
+			nested = maps.Clone(nested)
+			parent[name] = nested
+			parent = nested
+		}
+		name := path[last]
+		value, ok := parent[name]
+		if parent == nil || !ok || (last < len(path)-1 && protected(value)) {
+			continue
+		}
+		attribute := strings.Join(path[:last+1], ".")
+		if last < len(path)-1 {
+			e.diagnostics.printf("Encrypting the whole attribute %s, it isn't a group the path %s can go into\n", attribute, strings.Join(path, "."))
+		}
+		if err != nil {
+			parent[name] = Redacted
+			errs = append(errs, fmt.Errorf("%s: %w", attribute, err))
+			continue
+		}
+		encrypted, encryptErr := encryptValue(id, key, attribute, value)
+		if encryptErr != nil {
+			parent[name] = Redacted
+			errs = append(errs, fmt.Errorf("%s: %w", attribute, encryptErr))
+			continue
+		}
+		parent[name] = encrypted
+	}
+	return errors.Join(errs...)
+}
+
+// protected is true for a value already encrypted or redacted, by an earlier path going into the same attribute
+func protected(value any) bool {
+	s, ok := value.(string)
+	return ok && (s == Redacted || strings.HasPrefix(s, EncryptedPrefix))
+}
+
+// encryptValue encrypts the json of value with a new data key, bound to its path
+func encryptValue(id string, key []byte, path string, value any) (string, error) {
+	plain, err := json.Marshal(value)
+	if err != nil {
+		plain, _ = json.Marshal(fmt.Sprint(value))
+	}
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(key, dataKey, []byte(id))
+	if err != nil {
+		return "", err
+	}
+	data, err := seal(dataKey, plain, []byte(path))
+	if err != nil {
+		return "", err
+	}
+	return EncryptedPrefix + id + ":" + base64.RawURLEncoding.EncodeToString(wrapped) + ":" + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecryptValue returns the json of the value encrypted at path, the attribute path as configured in EncryptionConfig.Paths
+func DecryptValue(keys KeyProvider, path string, value string) (json.RawMessage, error) {
+	fields := strings.Split(strings.TrimPrefix(value, EncryptedPrefix), ":")
+	if !strings.HasPrefix(value, EncryptedPrefix) || len(fields) != 3 {
+		return nil, errors.New("not an encrypted value")
+	}
+	id := fields[0]
+	wrapped, err := base64.RawURLEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(fields[2])
+	if err != nil {
+		return nil, err
+	}
+	key, err := keys.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := open(key, wrapped, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("data key of %q: %w", id, err)
+	}
+	return open(dataKey, data, []byte(path))
+}
+
+// DecryptLogLine returns the json line with its encrypted attributes replaced by their values, leaving the rest as it is
+// The values that can't be decrypted are left encrypted and their errors joined
+// A sealed entry no longer matches its auditMac once decrypted
+func DecryptLogLine(line []byte, keys KeyProvider) ([]byte, error) {
+	var entry struct {
+		Attributes map[string]interface{} `json:"attributes"`
+	}
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return line, err
+	}
+	encrypted := map[string]string{}
+	findEncrypted(encrypted, "", entry.Attributes)
+
+	var errs []error
+	decrypted := bytes.Clone(line)
+	for _, path := range slices.Sorted(maps.Keys(encrypted)) {
+		plain, err := DecryptValue(keys, path, encrypted[path])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		// the value was written by json.Marshal, as it is now
+		quoted, _ := json.Marshal(encrypted[path])
+		decrypted = bytes.Replace(decrypted, quoted, plain, 1)
+	}
+	return decrypted, errors.Join(errs...)
+}
+
+// findEncrypted collects the encrypted values of attributes by path
+func findEncrypted(found map[string]string, prefix string, attributes map[string]interface{}) {
+	for key, value := range attributes {
+		switch v := value.(type) {
+		case string:
+			if strings.HasPrefix(v, EncryptedPrefix) {
+				found[prefix+key] = v
+			}
+		case map[string]interface{}:
+			findEncrypted(found, prefix+key+".", v)
+		}
+	}
+}
+
+// seal encrypts with AES-GCM, prefixing the random nonce
+func seal(key []byte, plain []byte, additional []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plain)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, additional), nil
+}
+
+// open decrypts what seal encrypted
+func open(key []byte, sealed []byte, additional []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encrypted value too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], additional)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,169 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testKeys = StaticKeyProvider{
+	CurrentId: "2025-01",
+	Keys: map[string][]byte{
+		"2024-12": bytes.Repeat([]byte{1}, 16),
+		"2025-01": bytes.Repeat([]byte{2}, 32),
+	},
+}
+
+func newEncryptionTestLogger(sink *sliceSink, keys KeyProvider, paths ...string) *slog.Logger {
+	logger := newSinkTestLogger(sink)
+	logger.Config.MangoConfig.Encryption = &EncryptionConfig{Enabled: true, Paths: paths, KeyProvider: keys}
+	logger.encryptor = newEncryptor(logger.Config.MangoConfig.Encryption, logger.failures.diagnostics)
+	return slog.New(logger)
+}
+
+func TestEncryption_RoundTrip(t *testing.T) {
+	sink := &sliceSink{}
+	customer := map[string]interface{}{"email": "ann@example.com", "tier": "gold"}
+	newEncryptionTestLogger(sink, testKeys, "customerId", "customer.email", "card", "missing.path").Info("checkout",
+		"customerId", 42,
+		slog.Any("customer", customer),
+		slog.Group("card", "last4", "1234"),
+		"total", 9.5,
+	)
+
+	assert.Len(t, sink.entries, 1)
+	attributes := sink.entries[0].Attributes
+	for _, value := range []any{attributes["customerId"], attributes["customer"].(map[string]interface{})["email"], attributes["card"]} {
+		assert.True(t, strings.HasPrefix(value.(string), EncryptedPrefix+"2025-01:"), value)
+	}
+	assert.Equal(t, "gold", attributes["customer"].(map[string]interface{})["tier"])
+	assert.Equal(t, 9.5, attributes["total"])
+	assert.Equal(t, "ann@example.com", customer["email"]) // the logged map is left as it is
+
+	line, err := json.Marshal(sink.entries[0])
+	assert.NoError(t, err)
+	assert.NotContains(t, string(line), "ann@example.com")
+	decrypted, err := DecryptLogLine(line, testKeys)
+	assert.NoError(t, err)
+	assert.Contains(t, string(decrypted), `"attributes":{"customerId":42,"customer":{"email":"ann@example.com","tier":"gold"},"card":{"last4":"1234"},"total":9.5}`)
+
+	plain, err := DecryptValue(testKeys, "customerId", attributes["customerId"].(string))
+	assert.NoError(t, err)
+	assert.Equal(t, json.RawMessage(`42`), plain)
+}
+
+type customer struct {
+	Email string `json:"email"`
+	Tier  string `json:"tier"`
+}
+
+func TestEncryption_OpaqueParent(t *testing.T) {
+	tests := map[string]any{
+		"map[string]string": map[string]string{"email": "ann@example.com", "tier": "gold"},
+		"struct":            customer{Email: "ann@example.com", Tier: "gold"},
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			sink := &sliceSink{}
+			logger := newSinkTestLogger(sink)
+			diagnostics := newDiagnostics(time.Minute)
+			diagnostics.out = &out
+			logger.encryptor = newEncryptor(&EncryptionConfig{Enabled: true, Paths: []string{"customer.email", "customer.phone"}, KeyProvider: testKeys}, diagnostics)
+			slog.New(logger).Info("checkout", slog.Any("customer", value), "total", 9.5)
+
+			encrypted, _ := sink.entries[0].Attributes["customer"].(string)
+			assert.True(t, strings.HasPrefix(encrypted, EncryptedPrefix+"2025-01:"), "the whole attribute is encrypted")
+			assert.Equal(t, 9.5, sink.entries[0].Attributes["total"])
+			assert.Contains(t, out.String(), "Encrypting the whole attribute customer, it isn't a group the path customer.email can go into")
+
+			line, _ := json.Marshal(sink.entries[0])
+			assert.NotContains(t, string(line), "ann@example.com")
+			decrypted, err := DecryptLogLine(line, testKeys)
+			assert.NoError(t, err)
+			assert.Contains(t, string(decrypted), `"customer":{"email":"ann@example.com","tier":"gold"}`, "encrypted once, for both paths")
+		})
+	}
+}
+
+func TestEncryption_KeyRotation(t *testing.T) {
+	sink := &sliceSink{}
+	old := StaticKeyProvider{CurrentId: "2024-12", Keys: testKeys.Keys}
+	newEncryptionTestLogger(sink, old, "email").Info("old key", "email", "a@b.c")
+	newEncryptionTestLogger(sink, testKeys, "email").Info("new key", "email", "a@b.c")
+
+	for i, id := range []string{"2024-12", "2025-01"} {
+		value := sink.entries[i].Attributes["email"].(string)
+		assert.True(t, strings.HasPrefix(value, EncryptedPrefix+id+":"))
+		plain, err := DecryptValue(testKeys, "email", value)
+		assert.NoError(t, err)
+		assert.Equal(t, json.RawMessage(`"a@b.c"`), plain)
+	}
+
+	retired := StaticKeyProvider{Keys: map[string][]byte{"2025-01": testKeys.Keys["2025-01"]}}
+	line, _ := json.Marshal(sink.entries[0])
+	decrypted, err := DecryptLogLine(line, retired)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, line, decrypted)
+}
+
+func TestEncryption_RedactsWithoutKey(t *testing.T) {
+	failing := StaticKeyProvider{CurrentId: "gone", Keys: testKeys.Keys}
+	tests := map[string]KeyProvider{
+		"no provider":  nil,
+		"missing key":  failing,
+		"bad key size": StaticKeyProvider{CurrentId: "short", Keys: map[string][]byte{"short": {1, 2, 3}}},
+		"colon in id":  StaticKeyProvider{CurrentId: "a:b", Keys: map[string][]byte{"a:b": testKeys.Keys["2025-01"]}},
+	}
+	for name, keys := range tests {
+		t.Run(name, func(t *testing.T) {
+			sink := &sliceSink{}
+			newEncryptionTestLogger(sink, keys, "email").Info("redacted", "email", "a@b.c", "other", "kept")
+			assert.Equal(t, Redacted, sink.entries[0].Attributes["email"])
+			assert.Equal(t, "kept", sink.entries[0].Attributes["other"])
+		})
+	}
+}
+
+func TestDecryptValue_Tampered(t *testing.T) {
+	value, err := encryptValue("2025-01", testKeys.Keys["2025-01"], "email", "a@b.c")
+	assert.NoError(t, err)
+
+	_, err = DecryptValue(testKeys, "other.path", value) // bound to its path
+	assert.Error(t, err)
+	_, err = DecryptValue(testKeys, "email", strings.Replace(value, "2025-01", "2024-12", 1))
+	assert.ErrorContains(t, err, `data key of "2024-12"`)
+	tampered := []byte(value)
+	tampered[len(tampered)-3] ^= 1
+	_, err = DecryptValue(testKeys, "email", string(tampered))
+	assert.Error(t, err)
+	_, err = DecryptValue(testKeys, "email", "plain")
+	assert.ErrorContains(t, err, "not an encrypted value")
+	_, err = DecryptValue(testKeys, "email", EncryptedPrefix+"2025-01:!:!")
+	assert.Error(t, err)
+
+	_, err = DecryptLogLine([]byte("not json"), testKeys)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrKeyNotFound))
+}
+
+func TestEncryption_NotEnabled(t *testing.T) {
+	assert.Nil(t, newEncryptor(nil, nil))
+	assert.Nil(t, newEncryptor(&EncryptionConfig{Paths: []string{"a"}}, nil))
+	assert.Nil(t, newEncryptor(&EncryptionConfig{Enabled: true}, nil))
+	assert.NoError(t, (*encryptor)(nil).apply(map[string]interface{}{"a": 1}))
+
+	logger := NewMangoLogger(&LogConfig{
+		Out: &OutConfig{File: &FileOutputConfig{}, Cli: &CliConfig{}},
+		MangoConfig: &MangoConfig{
+			Encryption: &EncryptionConfig{Enabled: true, Paths: []string{"a.b"}, KeyProvider: testKeys},
+		},
+	})
+	assert.Equal(t, [][]string{{"a", "b"}}, logger.encryptor.paths)
+}
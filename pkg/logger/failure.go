@@ -0,0 +1,215 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultDiagnosticInterval is the minimum time between two identical diagnostics
+const defaultDiagnosticInterval = time.Minute
+
+// strictViolation is a required context field missing or invalid in strict mode
+type strictViolation struct {
+	label   ctxKey
+	reason  string
+	message string
+}
+
+func (v *strictViolation) Error() string {
+	return v.message
+}
+
+func (v *strictViolation) Unwrap() error {
+	return errStrictModeOn
+}
+
+// violations lists the strict violations of err, e.g. "operation: missing"
+func violations(err error) []string {
+	var list []string
+	var walk func(err error)
+	walk = func(err error) {
+		var v *strictViolation
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				walk(e)
+			}
+		} else if errors.As(err, &v) {
+			list = append(list, fmt.Sprintf("%s: %s", v.label, v.reason))
+		} else if err != nil {
+			list = append(list, err.Error())
+		}
+	}
+	walk(err)
+	return list
+}
+
+// failureHandler applies the FailureConfig policies, shared by the handlers derived from a MangoLogger
+type failureHandler struct {
+	config      FailureConfig
+	diagnostics *diagnostics
+
+	// mu serialises the writes to the fallback writer
+	mu sync.Mutex
+}
+
+func newFailureHandler(config *MangoConfig) *failureHandler {
+	f := &failureHandler{}
+	if config != nil && config.Failure != nil {
+		f.config = *config.Failure
+	}
+	if f.config.Fallback == nil {
+		f.config.Fallback = os.Stderr
+	}
+	if f.config.DiagnosticInterval <= 0 {
+		f.config.DiagnosticInterval = defaultDiagnosticInterval
+	}
+	f.diagnostics = newDiagnostics(f.config.DiagnosticInterval)
+	f.diagnostics.out = f.config.Diagnostics
+	return f
+}
+
+// policy resolves the handler policy without an ErrorHandler to return
+func (f *failureHandler) policy(policy string) string {
+	if policy == FailurePolicyHandler && f.config.ErrorHandler == nil {
+		return FailurePolicyReturn
+	}
+	return policy
+}
+
+// stopOnOutputError is the original behaviour, stopping at the first failing output
+func (f *failureHandler) stopOnOutputError() bool {
+	if f == nil {
+		return true
+	}
+	switch f.policy(f.config.Output) {
+	case FailurePolicyDrop, FailurePolicyEmit, FailurePolicyFallback, FailurePolicyHandler:
+		return false
+	default:
+		return true
+	}
+}
+
+// onStrictFailure applies the strict policy, returning whether the entry still goes to the outputs and the error for the caller
+func (f *failureHandler) onStrictFailure(log *StructuredLog, err error) (bool, error) {
+	if f == nil {
+		defaultDiagnostics.printf("Required fields are not present. %s\n", err.Error())
+		return false, err
+	}
+	switch f.policy(f.config.Strict) {
+	case FailurePolicyDrop:
+		f.diagnostics.printf("Dropping entries without the required fields. %s\n", err.Error())
+		return false, nil
+	case FailurePolicyEmit:
+		log.Violations = violations(err)
+		return true, nil
+	case FailurePolicyFallback:
+		log.Violations = violations(err)
+		jsonOut, marshalErr := json.Marshal(log)
+		if marshalErr != nil {
+			return false, marshalErr
+		}
+		return false, f.writeFallback(jsonOut)
+	case FailurePolicyHandler:
+		f.config.ErrorHandler(err, log)
+		return false, nil
+	default:
+		f.diagnostics.printf("Required fields are not present. %s\n", err.Error())
+		return false, err
+	}
+}
+
+// onOutputFailure applies the output policy to the entry that failed at least one output
+func (f *failureHandler) onOutputFailure(log *StructuredLog, jsonOut []byte, err error) error {
+	if f == nil {
+		return err
+	}
+	switch f.policy(f.config.Output) {
+	case FailurePolicyDrop, FailurePolicyEmit:
+		f.diagnostics.printf("Failed to write the entry to all outputs. %s\n", err.Error())
+		return nil
+	case FailurePolicyFallback:
+		f.diagnostics.printf("Failed to write the entry to all outputs, writing it to the fallback. %s\n", err.Error())
+		return f.writeFallback(jsonOut)
+	case FailurePolicyHandler:
+		f.config.ErrorHandler(err, log)
+		return nil
+	default:
+		return err
+	}
+}
+
+func (f *failureHandler) writeFallback(jsonOut []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.config.Fallback.Write(append(jsonOut[:len(jsonOut):len(jsonOut)], '\n')); err != nil {
+		return fmt.Errorf("failed to write to the fallback writer: %w", err)
+	}
+	return nil
+}
+
+// diagnostic prints a rate limited message to stdout, it is nil safe using defaultDiagnostics
+func (sl MangoLogger) diagnostic(format string, args ...any) {
+	if sl.failures == nil {
+		defaultDiagnostics.printf(format, args...)
+		return
+	}
+	sl.failures.diagnostics.printf(format, args...)
+}
+
+// defaultDiagnostics rate limits the messages of the handlers created without NewMangoLogger
+var defaultDiagnostics = newDiagnostics(defaultDiagnosticInterval)
+
+// diagnostics prints each message, identified by its format, at most once per interval
+// The number of messages suppressed in between is reported with the next one printed
+type diagnostics struct {
+	mu       sync.Mutex
+	interval time.Duration
+	out      io.Writer // os.Stdout when nil
+	now      func() time.Time
+	seen     map[string]*diagnosticState
+}
+
+type diagnosticState struct {
+	printed    time.Time
+	suppressed int
+}
+
+func newDiagnostics(interval time.Duration) *diagnostics {
+	return &diagnostics{interval: interval, now: time.Now, seen: map[string]*diagnosticState{}}
+}
+
+// printf is nil safe using defaultDiagnostics
+func (d *diagnostics) printf(format string, args ...any) {
+	if d == nil {
+		d = defaultDiagnostics
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	state := d.seen[format]
+	if state == nil {
+		state = &diagnosticState{}
+		d.seen[format] = state
+	} else if now.Sub(state.printed) < d.interval {
+		state.suppressed++
+		return
+	}
+
+	message := fmt.Sprintf(format, args...)
+	if state.suppressed > 0 {
+		message = fmt.Sprintf("%s (%d similar messages suppressed)\n", strings.TrimSuffix(message, "\n"), state.suppressed)
+	}
+	out := d.out
+	if out == nil {
+		out = os.Stdout
+	}
+	_, _ = fmt.Fprint(out, message)
+	state.printed = now
+	state.suppressed = 0
+}
@@ -0,0 +1,174 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newFailureTestLogger(t *testing.T, filePath string, failure *FailureConfig) *MangoLogger {
+	return NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled: true,
+			File:    &FileOutputConfig{Enabled: true, Path: filePath},
+			Cli:     &CliConfig{},
+			Syslog:  &SyslogConfig{},
+		},
+		MangoConfig: &MangoConfig{
+			Strict:        true,
+			CorrelationId: &CorrelationIdConfig{AutoGenerate: true},
+			Failure:       failure,
+		},
+	})
+}
+
+// unwritablePath is under a regular file, so the file output fails
+func unwritablePath(t *testing.T) string {
+	blocker := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(blocker, nil, 0o600))
+	return filepath.Join(blocker, "app.log")
+}
+
+func invalidContext() context.Context {
+	ctx := context.WithValue(context.Background(), TYPE, "Audit")
+	return context.WithValue(ctx, APPLICATION, "checkout-api")
+}
+
+func TestFailure_StrictReturnIsTheDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	logger := newFailureTestLogger(t, path, nil)
+
+	err := logger.Handle(invalidContext(), slog.NewRecord(time.Now(), slog.LevelInfo, "lost", 0))
+	assert.ErrorIs(t, err, errStrictModeOn)
+	assert.Empty(t, readLines(t, path))
+}
+
+func TestFailure_StrictDrop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	logger := newFailureTestLogger(t, path, &FailureConfig{Strict: FailurePolicyDrop})
+
+	assert.NoError(t, logger.Handle(invalidContext(), slog.NewRecord(time.Now(), slog.LevelInfo, "dropped", 0)))
+	assert.Empty(t, readLines(t, path))
+	assert.Equal(t, uint64(1), logger.Metrics().Snapshot().StrictRejections)
+}
+
+func TestFailure_StrictEmit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	logger := newFailureTestLogger(t, path, &FailureConfig{Strict: FailurePolicyEmit})
+
+	assert.NoError(t, logger.Handle(invalidContext(), slog.NewRecord(time.Now(), slog.LevelInfo, "kept", 0)))
+
+	entry := StructuredLog{}
+	assert.NoError(t, json.Unmarshal([]byte(readLines(t, path)), &entry))
+	assert.Equal(t, "kept", entry.Message)
+	assert.Equal(t, "Audit", entry.Type)
+	assert.Equal(t, "checkout-api", entry.Application)
+	assert.Equal(t, []string{
+		`type: "Audit" is not one of ["Business" "Security" "Performance"]`,
+		"operation: missing",
+	}, entry.Violations)
+}
+
+func TestFailure_StrictFallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	var fallback bytes.Buffer
+	logger := newFailureTestLogger(t, path, &FailureConfig{Strict: FailurePolicyFallback, Fallback: &fallback})
+
+	assert.NoError(t, logger.Handle(invalidContext(), slog.NewRecord(time.Now(), slog.LevelInfo, "aside", 0)))
+	assert.Empty(t, readLines(t, path))
+	assert.Contains(t, fallback.String(), `"message":"aside"`)
+	assert.Contains(t, fallback.String(), `"violations":["type: `)
+	assert.Equal(t, byte('\n'), fallback.Bytes()[fallback.Len()-1])
+}
+
+func TestFailure_StrictHandler(t *testing.T) {
+	var handled []*StructuredLog
+	var handledErr error
+	logger := newFailureTestLogger(t, filepath.Join(t.TempDir(), "app.log"), &FailureConfig{
+		Strict: FailurePolicyHandler,
+		ErrorHandler: func(err error, log *StructuredLog) {
+			handledErr = err
+			handled = append(handled, log)
+		},
+	})
+
+	assert.NoError(t, logger.Handle(invalidContext(), slog.NewRecord(time.Now(), slog.LevelInfo, "handled", 0)))
+	assert.Len(t, handled, 1)
+	assert.Equal(t, "handled", handled[0].Message)
+	assert.ErrorIs(t, handledErr, errStrictModeOn)
+}
+
+func TestFailure_HandlerWithoutErrorHandlerReturns(t *testing.T) {
+	logger := newFailureTestLogger(t, filepath.Join(t.TempDir(), "app.log"), &FailureConfig{Strict: FailurePolicyHandler})
+	assert.Error(t, logger.Handle(invalidContext(), slog.NewRecord(time.Now(), slog.LevelInfo, "lost", 0)))
+}
+
+func TestFailure_Output(t *testing.T) {
+	validCtx := context.WithValue(context.WithValue(context.WithValue(context.Background(),
+		TYPE, BusinessType), APPLICATION, "checkout-api"), OPERATION, "pay")
+
+	assert.Error(t, newFailureTestLogger(t, unwritablePath(t), nil).
+		Handle(validCtx, slog.NewRecord(time.Now(), slog.LevelInfo, "failed", 0)))
+
+	assert.NoError(t, newFailureTestLogger(t, unwritablePath(t), &FailureConfig{Output: FailurePolicyDrop}).
+		Handle(validCtx, slog.NewRecord(time.Now(), slog.LevelInfo, "ignored", 0)))
+
+	var fallback bytes.Buffer
+	assert.NoError(t, newFailureTestLogger(t, unwritablePath(t), &FailureConfig{Output: FailurePolicyFallback, Fallback: &fallback}).
+		Handle(validCtx, slog.NewRecord(time.Now(), slog.LevelInfo, "rescued", 0)))
+	assert.Contains(t, fallback.String(), `"message":"rescued"`)
+
+	var handledErr error
+	assert.NoError(t, newFailureTestLogger(t, unwritablePath(t), &FailureConfig{
+		Output:       FailurePolicyHandler,
+		ErrorHandler: func(err error, log *StructuredLog) { handledErr = err },
+	}).Handle(validCtx, slog.NewRecord(time.Now(), slog.LevelInfo, "handled", 0)))
+	assert.Error(t, handledErr)
+}
+
+func TestFailure_OutputKeepsWritingTheOtherOutputs(t *testing.T) {
+	var fallback bytes.Buffer
+	logger := newFailureTestLogger(t, unwritablePath(t), &FailureConfig{Output: FailurePolicyFallback, Fallback: &fallback})
+	logger.Config.Out.Cli.Enabled = true
+	logger.Config.Out.Cli.Friendly = false
+
+	ctx := context.WithValue(context.WithValue(context.WithValue(context.Background(),
+		TYPE, BusinessType), APPLICATION, "checkout-api"), OPERATION, "pay")
+	assert.NoError(t, logger.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelInfo, "partial", 0)))
+
+	s := logger.Metrics().Snapshot()
+	assert.Equal(t, uint64(1), s.Writes[OutputCli].Count)
+	assert.Equal(t, uint64(1), s.WriteErrors[OutputFile])
+}
+
+func TestDiagnostics_RateLimited(t *testing.T) {
+	var out bytes.Buffer
+	now := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+	d := newDiagnostics(time.Minute)
+	d.out = &out
+	d.now = func() time.Time { return now }
+
+	d.printf("No logging enabled!\n")
+	d.printf("No logging enabled!\n")
+	d.printf("Failed %s\n", "other")
+	now = now.Add(30 * time.Second)
+	d.printf("No logging enabled!\n")
+	now = now.Add(31 * time.Second)
+	d.printf("No logging enabled!\n")
+
+	assert.Equal(t, "No logging enabled!\nFailed other\nNo logging enabled! (2 similar messages suppressed)\n", out.String())
+}
+
+func TestViolations(t *testing.T) {
+	err := errors.Join(missingFieldError(OPERATION), errors.New("other"))
+	assert.Equal(t, []string{"operation: missing", "other"}, violations(err))
+	assert.Nil(t, violations(nil))
+}
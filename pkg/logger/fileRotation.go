@@ -0,0 +1,316 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/natefinch/lumberjack"
+)
+
+// Date stamps used in the names of the time based and on-demand rotated files
+const (
+	dailyStampFormat    = "2006-01-02"
+	hourlyStampFormat   = "2006-01-02T15"
+	onDemandStampFormat = "2006-01-02T15-04-05"
+)
+
+const compressSuffix = ".gz"
+
+// rotatingFile adds time based and on-demand rotation on top of the size based rotation done by lumberjack
+// lumberjack keeps naming and cleaning up its own size rotated backups, the date stamped files are handled here
+type rotatingFile struct {
+	mu         sync.Mutex
+	writer     *lumberjack.Logger
+	rotation   string
+	localTime  bool
+	maxAge     int
+	maxBackups int
+	compress   bool
+	hook       RotationHook
+
+	// period is the start of the period covered by the current file, zero without time based rotation
+	period time.Time
+
+	// closed fails the writes after Close, lumberjack would reopen the file
+	closed bool
+
+	background sync.WaitGroup
+	stopSignal func()
+
+	// diagnostics reports the failures in the background, compressing or rotating on a signal
+	diagnostics *diagnostics
+
+	// now is overridden in tests to cross period boundaries
+	now func() time.Time
+}
+
+func newRotatingFile(writer *lumberjack.Logger, config *FileOutputConfig, diagnostics *diagnostics) *rotatingFile {
+	f := &rotatingFile{
+		writer:      writer,
+		rotation:    config.Rotation,
+		localTime:   config.LocalTime,
+		maxAge:      config.MaxAge,
+		maxBackups:  config.MaxBackups,
+		compress:    config.Compress,
+		hook:        config.PostRotate,
+		now:         time.Now,
+		diagnostics: diagnostics,
+	}
+	if f.rotation != "" {
+		f.period = f.periodStart(f.now())
+		if info, err := os.Stat(f.filename()); err == nil {
+			// a file left over from an earlier period gets rotated on the first write
+			f.period = f.periodStart(info.ModTime())
+		}
+	}
+	if config.Enabled && config.RotateOnSignal {
+		f.stopSignal = notifyRotate(f)
+	}
+	return f
+}
+
+// Rotate closes the current log files (main and routes) and renames them with a date stamp, the next entries start new files
+// It is a no-op for the files nothing has been written to yet
+func (sl MangoLogger) Rotate() error {
+	var errs []error
+	if sl.file != nil {
+		errs = append(errs, sl.file.Rotate())
+	} else if sl.LogWriter != nil {
+		errs = append(errs, sl.LogWriter.Rotate())
+	}
+	for _, route := range sl.routes {
+		errs = append(errs, route.file.Rotate())
+	}
+	return errors.Join(errs...)
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, fmt.Errorf("failed to write to %s: %w", f.filename(), ErrLoggerClosed)
+	}
+
+	var rotateErr error
+	if f.rotation != "" {
+		if current := f.periodStart(f.now()); current.After(f.period) {
+			rotateErr = f.rotate(f.period.Format(f.stampFormat()))
+			f.period = current
+		}
+	}
+	n, err := f.writer.Write(p)
+	if err == nil && rotateErr != nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+// Rotate names the rotated file after the current period, or after the rotation time without time based rotation
+// It is a no-op once closed
+func (f *rotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	if f.rotation != "" {
+		return f.rotate(f.period.Format(f.stampFormat()))
+	}
+	return f.rotate(f.localize(f.now()).Format(onDemandStampFormat))
+}
+
+// Close stops listening for signals, closes the current file and waits for pending compressions and hooks
+// The writes afterwards fail instead of reopening the file
+func (f *rotatingFile) Close() error {
+	if f.stopSignal != nil {
+		f.stopSignal()
+	}
+	f.mu.Lock()
+	f.closed = true
+	err := f.writer.Close()
+	f.mu.Unlock()
+	f.background.Wait()
+	return err
+}
+
+// rotate must be called with mu held
+func (f *rotatingFile) rotate(stamp string) error {
+	if err := f.writer.Close(); err != nil {
+		return err
+	}
+	name := f.filename()
+	if _, err := os.Stat(name); os.IsNotExist(err) {
+		return nil
+	}
+	rotated := rotatedName(name, stamp)
+	if err := os.Rename(name, rotated); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	f.background.Add(1)
+	go f.afterRotate(rotated)
+	return nil
+}
+
+func (f *rotatingFile) afterRotate(rotated string) {
+	defer f.background.Done()
+	if f.compress {
+		compressed, err := compressFile(rotated)
+		if err != nil {
+			f.diagnostics.printf("Failed to compress rotated log file %s. %s\n", rotated, err.Error())
+		} else {
+			rotated = compressed
+		}
+	}
+	if f.hook != nil {
+		f.hook(rotated)
+	}
+	f.removeExpired()
+}
+
+// removeExpired applies MaxBackups and MaxAge (in calendar days) to the date stamped files
+func (f *rotatingFile) removeExpired() {
+	if f.maxAge <= 0 && f.maxBackups <= 0 {
+		return
+	}
+	type stamped struct {
+		path  string
+		stamp time.Time
+	}
+	var files []stamped
+	name := f.filename()
+	entries, err := os.ReadDir(filepath.Dir(name))
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if stamp, ok := f.parseRotatedName(name, entry.Name()); ok && !entry.IsDir() {
+			files = append(files, stamped{path: filepath.Join(filepath.Dir(name), entry.Name()), stamp: stamp})
+		}
+	}
+	slices.SortFunc(files, func(a, b stamped) int { return b.stamp.Compare(a.stamp) })
+
+	now := f.localize(f.now())
+	cutoff := time.Date(now.Year(), now.Month(), now.Day()-f.maxAge, 0, 0, 0, 0, now.Location())
+	for i, file := range files {
+		if (f.maxBackups > 0 && i >= f.maxBackups) || (f.maxAge > 0 && file.stamp.Before(cutoff)) {
+			_ = os.Remove(file.path)
+		}
+	}
+}
+
+// parseRotatedName returns the stamp of a file rotated from name, lumberjack backups are not matched
+func (f *rotatingFile) parseRotatedName(name string, candidate string) (time.Time, bool) {
+	prefix, ext := splitLogName(name)
+	candidate = strings.TrimSuffix(candidate, compressSuffix)
+	if !strings.HasPrefix(candidate, prefix+"-") || !strings.HasSuffix(candidate, ext) {
+		return time.Time{}, false
+	}
+	stamp := candidate[len(prefix)+1 : len(candidate)-len(ext)]
+	if i := strings.LastIndex(stamp, "_"); i > 0 {
+		if _, err := strconv.Atoi(stamp[i+1:]); err == nil {
+			stamp = stamp[:i]
+		}
+	}
+	location := time.UTC
+	if f.localTime {
+		location = time.Local
+	}
+	for _, layout := range []string{onDemandStampFormat, hourlyStampFormat, dailyStampFormat} {
+		if t, err := time.ParseInLocation(layout, stamp, location); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (f *rotatingFile) stampFormat() string {
+	if f.rotation == FileRotationHourly {
+		return hourlyStampFormat
+	}
+	return dailyStampFormat
+}
+
+func (f *rotatingFile) periodStart(t time.Time) time.Time {
+	t = f.localize(t)
+	hour := 0
+	if f.rotation == FileRotationHourly {
+		hour = t.Hour()
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), hour, 0, 0, 0, t.Location())
+}
+
+func (f *rotatingFile) localize(t time.Time) time.Time {
+	if f.localTime {
+		return t.Local()
+	}
+	return t.UTC()
+}
+
+// filename mirrors lumberjack's default of <processname>-lumberjack.log in os.TempDir()
+func (f *rotatingFile) filename() string {
+	if f.writer.Filename != "" {
+		return f.writer.Filename
+	}
+	return filepath.Join(os.TempDir(), filepath.Base(os.Args[0])+"-lumberjack.log")
+}
+
+func splitLogName(name string) (prefix string, ext string) {
+	base := filepath.Base(name)
+	ext = filepath.Ext(base)
+	return base[:len(base)-len(ext)], ext
+}
+
+// rotatedName inserts the stamp between the file name and its extension, adding _1, _2... if already taken
+func rotatedName(name string, stamp string) string {
+	prefix, ext := splitLogName(name)
+	dir := filepath.Dir(name)
+	rotated := filepath.Join(dir, fmt.Sprintf("%s-%s%s", prefix, stamp, ext))
+	for i := 1; fileExists(rotated) || fileExists(rotated+compressSuffix); i++ {
+		rotated = filepath.Join(dir, fmt.Sprintf("%s-%s_%d%s", prefix, stamp, i, ext))
+	}
+	return rotated
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// compressFile gzips src next to it and removes src, returning the compressed file name
+func compressFile(src string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	dst := src + compressSuffix
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+		return "", err
+	}
+	return dst, os.Remove(src)
+}
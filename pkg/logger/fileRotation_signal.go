@@ -0,0 +1,32 @@
+//go:build !windows
+
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyRotate rotates the file on each SIGUSR1 until the returned stop function is called
+func notifyRotate(f *rotatingFile) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signals:
+				if err := f.Rotate(); err != nil {
+					f.diagnostics.printf("Failed to rotate log file on SIGUSR1. %s\n", err.Error())
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+
+package logger
+
+import (
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/natefinch/lumberjack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotatingFile_RotateOnSIGUSR1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.log")
+	hooked := make(chan string, 1)
+	f := newRotatingFile(&lumberjack.Logger{Filename: path}, &FileOutputConfig{
+		Enabled:        true,
+		RotateOnSignal: true,
+		PostRotate:     func(rotated string) { hooked <- rotated },
+	}, nil)
+	defer func() { _ = f.Close() }()
+
+	_, err := f.Write([]byte("signal me\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+
+	select {
+	case rotated := <-hooked:
+		assert.Equal(t, "signal me\n", readFile(t, rotated))
+	case <-time.After(2 * time.Second):
+		t.Fatal("file not rotated on SIGUSR1")
+	}
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package logger
+
+// notifyRotate is a no-op, there is no SIGUSR1 on Windows
+func notifyRotate(f *rotatingFile) func() {
+	return func() {}
+}
@@ -0,0 +1,211 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/natefinch/lumberjack"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a settable time source for crossing rotation periods
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = t
+}
+
+func newTestRotatingFile(t *testing.T, config *FileOutputConfig, clock *fakeClock) *rotatingFile {
+	config.Path = filepath.Join(t.TempDir(), "service.log")
+	f := newRotatingFile(&lumberjack.Logger{Filename: config.Path}, config, nil)
+	f.now = clock.now
+	f.period = f.periodStart(clock.now())
+	t.Cleanup(func() { _ = f.Close() })
+	return f
+}
+
+func readFile(t *testing.T, name string) string {
+	content, err := os.ReadFile(name)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestRotatingFile_Daily(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 1, 15, 23, 59, 0, 0, time.UTC)}
+	var rotated []string
+	var mu sync.Mutex
+	f := newTestRotatingFile(t, &FileOutputConfig{
+		Rotation:   FileRotationDaily,
+		PostRotate: func(path string) { mu.Lock(); rotated = append(rotated, path); mu.Unlock() },
+	}, clock)
+
+	_, err := f.Write([]byte("day one\n"))
+	assert.NoError(t, err)
+	clock.set(time.Date(2025, 1, 15, 23, 59, 59, 0, time.UTC))
+	_, err = f.Write([]byte("still day one\n"))
+	assert.NoError(t, err)
+
+	clock.set(time.Date(2025, 1, 16, 0, 0, 1, 0, time.UTC))
+	_, err = f.Write([]byte("day two\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	dayOne := filepath.Join(filepath.Dir(f.filename()), "service-2025-01-15.log")
+	assert.Equal(t, "day one\nstill day one\n", readFile(t, dayOne))
+	assert.Equal(t, "day two\n", readFile(t, f.filename()))
+	assert.Equal(t, []string{dayOne}, rotated)
+}
+
+func TestRotatingFile_HourlyLocalTime(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 1, 15, 9, 30, 0, 0, time.Local)}
+	f := newTestRotatingFile(t, &FileOutputConfig{Rotation: FileRotationHourly, LocalTime: true}, clock)
+
+	_, _ = f.Write([]byte("nine\n"))
+	clock.set(time.Date(2025, 1, 15, 10, 0, 0, 0, time.Local))
+	_, _ = f.Write([]byte("ten\n"))
+	assert.NoError(t, f.Close())
+
+	assert.FileExists(t, filepath.Join(filepath.Dir(f.filename()), "service-2025-01-15T09.log"))
+}
+
+func TestRotatingFile_RotateOnDemand(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 1, 15, 9, 30, 5, 0, time.UTC)}
+	f := newTestRotatingFile(t, &FileOutputConfig{}, clock)
+
+	// nothing written yet, nothing to rotate
+	assert.NoError(t, f.Rotate())
+
+	_, _ = f.Write([]byte("first\n"))
+	assert.NoError(t, f.Rotate())
+	_, _ = f.Write([]byte("second\n"))
+	assert.NoError(t, f.Rotate())
+	assert.NoError(t, f.Close())
+
+	dir := filepath.Dir(f.filename())
+	assert.Equal(t, "first\n", readFile(t, filepath.Join(dir, "service-2025-01-15T09-30-05.log")))
+	assert.Equal(t, "second\n", readFile(t, filepath.Join(dir, "service-2025-01-15T09-30-05_1.log")))
+	assert.NoFileExists(t, f.filename())
+}
+
+func TestRotatingFile_CompressBeforeHook(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)}
+	hooked := make(chan string, 1)
+	f := newTestRotatingFile(t, &FileOutputConfig{
+		Rotation:   FileRotationDaily,
+		Compress:   true,
+		PostRotate: func(path string) { hooked <- path },
+	}, clock)
+
+	_, _ = f.Write([]byte("compress me\n"))
+	assert.NoError(t, f.Rotate())
+
+	path := <-hooked
+	assert.Equal(t, filepath.Join(filepath.Dir(f.filename()), "service-2025-01-15.log.gz"), path)
+	compressed, err := os.Open(path)
+	assert.NoError(t, err)
+	defer func() { _ = compressed.Close() }()
+	gz, err := gzip.NewReader(compressed)
+	assert.NoError(t, err)
+	content, err := io.ReadAll(gz)
+	assert.NoError(t, err)
+	assert.Equal(t, "compress me\n", string(content))
+}
+
+func TestRotatingFile_CompressFailureDiagnostic(t *testing.T) {
+	var out bytes.Buffer
+	f := newTestRotatingFile(t, &FileOutputConfig{Compress: true}, &fakeClock{t: time.Now()})
+	f.diagnostics = newDiagnostics(time.Minute)
+	f.diagnostics.out = &out
+	missing := filepath.Join(t.TempDir(), "gone.log")
+
+	f.background.Add(1)
+	f.afterRotate(missing)
+	assert.Contains(t, out.String(), "Failed to compress rotated log file "+missing+".")
+}
+
+func TestRotatingFile_RetentionInCalendarDays(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 1, 15, 0, 30, 0, 0, time.UTC)}
+	f := newTestRotatingFile(t, &FileOutputConfig{Rotation: FileRotationDaily, MaxAge: 2}, clock)
+	dir := filepath.Dir(f.filename())
+
+	for _, name := range []string{
+		"service-2025-01-11.log.gz",
+		"service-2025-01-12.log",
+		"service-2025-01-13.log",
+		"service-2025-01-13T10-00-00.000.log", // lumberjack backup, left to lumberjack
+		"other-2025-01-01.log",
+	} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("old\n"), 0o644))
+	}
+
+	_, _ = f.Write([]byte("today\n"))
+	assert.NoError(t, f.Rotate())
+	assert.NoError(t, f.Close())
+
+	assert.NoFileExists(t, filepath.Join(dir, "service-2025-01-11.log.gz"))
+	assert.NoFileExists(t, filepath.Join(dir, "service-2025-01-12.log"))
+	assert.FileExists(t, filepath.Join(dir, "service-2025-01-13.log"))
+	assert.FileExists(t, filepath.Join(dir, "service-2025-01-13T10-00-00.000.log"))
+	assert.FileExists(t, filepath.Join(dir, "other-2025-01-01.log"))
+	assert.FileExists(t, filepath.Join(dir, "service-2025-01-15.log"))
+}
+
+func TestRotatingFile_RetentionMaxBackups(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 1, 15, 0, 30, 0, 0, time.UTC)}
+	f := newTestRotatingFile(t, &FileOutputConfig{Rotation: FileRotationHourly, MaxBackups: 1}, clock)
+	dir := filepath.Dir(f.filename())
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "service-2025-01-14T23.log"), []byte("old\n"), 0o644))
+
+	_, _ = f.Write([]byte("now\n"))
+	assert.NoError(t, f.Rotate())
+	assert.NoError(t, f.Close())
+
+	assert.NoFileExists(t, filepath.Join(dir, "service-2025-01-14T23.log"))
+	assert.FileExists(t, filepath.Join(dir, "service-2025-01-15T00.log"))
+}
+
+func TestRotatingFile_LeftoverFromEarlierPeriod(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.log")
+	assert.NoError(t, os.WriteFile(path, []byte("yesterday\n"), 0o644))
+	yesterday := time.Now().UTC().AddDate(0, 0, -1)
+	assert.NoError(t, os.Chtimes(path, yesterday, yesterday))
+
+	f := newRotatingFile(&lumberjack.Logger{Filename: path}, &FileOutputConfig{Rotation: FileRotationDaily}, nil)
+	_, err := f.Write([]byte("today\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	assert.Equal(t, "yesterday\n", readFile(t, filepath.Join(filepath.Dir(path), "service-"+yesterday.Format(dailyStampFormat)+".log")))
+	assert.Equal(t, "today\n", readFile(t, path))
+}
+
+func TestMangoLogger_Rotate(t *testing.T) {
+	logger := newTestLogger(false, true, false, true)
+	assert.NoError(t, logger.writeStringToLogFile("before rotate"))
+	assert.NoError(t, logger.Rotate())
+	assert.NoError(t, logger.writeStringToLogFile("after rotate"))
+	assert.NoError(t, logger.file.Close())
+
+	assert.Equal(t, "after rotate\n", readFile(t, logger.LogWriter.Filename))
+	matches, _ := filepath.Glob(logger.LogWriter.Filename[:len(logger.LogWriter.Filename)-len(".log")] + "-*.log")
+	assert.Len(t, matches, 1)
+
+	assert.NoError(t, MangoLogger{}.Rotate())
+}
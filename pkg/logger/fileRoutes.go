@@ -0,0 +1,92 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"unsafe"
+
+	"github.com/natefinch/lumberjack"
+)
+
+// fileRoute is a rotating file receiving the entries matching its FileRouteConfig
+type fileRoute struct {
+	config *FileRouteConfig
+	file   *rotatingFile
+}
+
+func newFileRoutes(config *FileOutputConfig, diagnostics *diagnostics) []*fileRoute {
+	var routes []*fileRoute
+	for i := range config.Routes {
+		route := &config.Routes[i]
+		if route.Path == "" {
+			diagnostics.printf("Ignoring config.out.file.routes[%d], the path is required\n", i)
+			continue
+		}
+		writer := &lumberjack.Logger{
+			Filename:   route.Path,
+			MaxSize:    route.MaxSize,
+			MaxBackups: route.MaxBackups,
+			MaxAge:     route.MaxAge,
+			Compress:   route.Compress,
+			LocalTime:  route.LocalTime,
+		}
+		routes = append(routes, &fileRoute{
+			config: route,
+			file: newRotatingFile(writer, &FileOutputConfig{
+				Enabled:        config.Enabled,
+				Path:           route.Path,
+				MaxBackups:     route.MaxBackups,
+				MaxAge:         route.MaxAge,
+				Compress:       route.Compress,
+				Rotation:       route.Rotation,
+				LocalTime:      route.LocalTime,
+				RotateOnSignal: config.RotateOnSignal,
+				PostRotate:     route.PostRotate,
+			}, diagnostics),
+		})
+	}
+	return routes
+}
+
+func (r *fileRoute) matches(log *StructuredLog) bool {
+	return (len(r.config.Types) == 0 || slices.Contains(r.config.Types, log.Type)) &&
+		(len(r.config.Levels) == 0 || slices.Contains(r.config.Levels, log.Level))
+}
+
+// writesDebugToFile is true when the main file or a matching route writes the DEBUG entry, as handleFileOutput does
+func (sl MangoLogger) writesDebugToFile(log *StructuredLog) bool {
+	exclusive := false
+	for _, route := range sl.routes {
+		if !route.matches(log) {
+			continue
+		}
+		if route.config.Debug {
+			return true
+		}
+		exclusive = exclusive || route.config.Exclusive
+	}
+	return !exclusive && sl.Config.Out.File.Debug
+}
+
+// handleFileRoutes writes the entry to every matching route
+// It reports whether one of them is exclusive, in which case the entry stays out of the main file
+func (sl MangoLogger) handleFileRoutes(log *StructuredLog, jsonOut string) (bool, error) {
+	exclusive := false
+	var errs []error
+	for _, route := range sl.routes {
+		if !route.matches(log) {
+			continue
+		}
+		exclusive = exclusive || route.config.Exclusive
+		if log.Level == slog.LevelDebug && !route.config.Debug {
+			continue
+		}
+		s := jsonOut + "\n"
+		if _, err := route.file.Write(unsafe.Slice(unsafe.StringData(s), len(s))); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write to %s: %w", route.config.Path, err))
+		}
+	}
+	return exclusive, errors.Join(errs...)
+}
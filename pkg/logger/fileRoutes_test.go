@@ -0,0 +1,135 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRoutesTestLogger(t *testing.T, routes []FileRouteConfig) (*MangoLogger, string) {
+	dir := t.TempDir()
+	for i := range routes {
+		if routes[i].Path != "" {
+			routes[i].Path = filepath.Join(dir, routes[i].Path)
+		}
+	}
+	logger := NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled: true,
+			File: &FileOutputConfig{
+				Enabled: true,
+				Path:    filepath.Join(dir, "service.log"),
+				Routes:  routes,
+			},
+			Cli:    &CliConfig{},
+			Syslog: &SyslogConfig{},
+		},
+		MangoConfig: &MangoConfig{
+			CorrelationId: &CorrelationIdConfig{AutoGenerate: true},
+		},
+	})
+	return logger, dir
+}
+
+func handleTyped(t *testing.T, logger *MangoLogger, logType string, level slog.Level, msg string) {
+	ctx := context.WithValue(context.Background(), TYPE, logType)
+	assert.NoError(t, logger.Handle(ctx, slog.Record{Time: time.Now(), Level: level, Message: msg}))
+}
+
+func readLines(t *testing.T, name string) string {
+	content, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return ""
+	}
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestFileRoutes_ByTypeAndLevel(t *testing.T) {
+	logger, dir := newRoutesTestLogger(t, []FileRouteConfig{
+		{Types: []string{SecurityType}, Exclusive: true, Path: "audit.log", MaxAge: 365},
+		{Levels: []slog.Level{slog.LevelError}, Path: "errors.log"},
+	})
+
+	handleTyped(t, logger, BusinessType, slog.LevelInfo, "business info")
+	handleTyped(t, logger, BusinessType, slog.LevelError, "business error")
+	handleTyped(t, logger, SecurityType, slog.LevelInfo, "security info")
+	handleTyped(t, logger, SecurityType, slog.LevelError, "security error")
+
+	main := readLines(t, filepath.Join(dir, "service.log"))
+	assert.Contains(t, main, "business info")
+	assert.Contains(t, main, "business error")
+	assert.NotContains(t, main, "security")
+
+	audit := readLines(t, filepath.Join(dir, "audit.log"))
+	assert.Contains(t, audit, "security info")
+	assert.Contains(t, audit, "security error")
+	assert.NotContains(t, audit, "business")
+
+	errorsLog := readLines(t, filepath.Join(dir, "errors.log"))
+	assert.Contains(t, errorsLog, "business error")
+	assert.Contains(t, errorsLog, "security error")
+	assert.NotContains(t, errorsLog, "info")
+}
+
+func TestFileRoutes_TypeAndLevelCombined(t *testing.T) {
+	logger, dir := newRoutesTestLogger(t, []FileRouteConfig{
+		{Types: []string{PerformanceType}, Levels: []slog.Level{slog.LevelWarn}, Path: "slow.log"},
+	})
+
+	handleTyped(t, logger, PerformanceType, slog.LevelInfo, "fast")
+	handleTyped(t, logger, PerformanceType, slog.LevelWarn, "slow")
+	handleTyped(t, logger, BusinessType, slog.LevelWarn, "business warn")
+
+	slow := readLines(t, filepath.Join(dir, "slow.log"))
+	assert.Contains(t, slow, "slow")
+	assert.NotContains(t, slow, "fast")
+	assert.NotContains(t, slow, "business")
+	assert.Contains(t, readLines(t, filepath.Join(dir, "service.log")), "slow")
+}
+
+func TestFileRoutes_Debug(t *testing.T) {
+	logger, dir := newRoutesTestLogger(t, []FileRouteConfig{
+		{Path: "all.log"},
+		{Path: "debug.log", Debug: true, Exclusive: true, Levels: []slog.Level{slog.LevelDebug}},
+	})
+
+	handleTyped(t, logger, BusinessType, slog.LevelDebug, "debugging")
+
+	assert.Empty(t, readLines(t, filepath.Join(dir, "all.log")))
+	assert.Empty(t, readLines(t, filepath.Join(dir, "service.log")))
+	assert.Contains(t, readLines(t, filepath.Join(dir, "debug.log")), "debugging")
+}
+
+func TestFileRoutes_RotateAndMissingPath(t *testing.T) {
+	logger, dir := newRoutesTestLogger(t, []FileRouteConfig{
+		{Types: []string{SecurityType}, Path: "audit.log"},
+		{Types: []string{BusinessType}},
+	})
+	assert.Len(t, logger.routes, 1)
+
+	handleTyped(t, logger, SecurityType, slog.LevelInfo, "rotate me")
+	assert.NoError(t, logger.Rotate())
+	for _, route := range logger.routes {
+		assert.NoError(t, route.file.Close())
+	}
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "audit-*.log"))
+	assert.Len(t, rotated, 1)
+	assert.Contains(t, readLines(t, rotated[0]), "rotate me")
+}
+
+func TestFileRoutes_MissingPathDiagnostic(t *testing.T) {
+	var out bytes.Buffer
+	diagnostics := newDiagnostics(time.Minute)
+	diagnostics.out = &out
+	routes := newFileRoutes(&FileOutputConfig{Routes: []FileRouteConfig{{Types: []string{BusinessType}}}}, diagnostics)
+	assert.Empty(t, routes)
+	assert.Equal(t, "Ignoring config.out.file.routes[0], the path is required\n", out.String())
+}
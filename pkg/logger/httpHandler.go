@@ -0,0 +1,591 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults applied to HttpOutputConfig when left empty
+const (
+	defaultHttpBatchSize       = 100
+	defaultHttpMaxBatchBytes   = 1024 * 1024
+	defaultHttpFlushInterval   = 5 * time.Second
+	defaultHttpTimeout         = 10 * time.Second
+	defaultHttpMaxRetries      = 3
+	defaultHttpRetryBackoff    = 500 * time.Millisecond
+	defaultHttpMaxRetryBackoff = 30 * time.Second
+	defaultHttpSpillMaxBytes   = 100 * 1024 * 1024
+	defaultHttpSpillMaxFiles   = 1000
+)
+
+const spillFilePrefix = "mango-spill-"
+const spillFileExt = ".ndjson"
+
+// quarantineExt is appended to the spilled files that can't be read or encoded, so they are no longer replayed
+const quarantineExt = ".bad"
+
+var errHttpShipperClosed = errors.New("http output is closed")
+
+// errNonRetryable marks a response the endpoint will never accept (e.g. 400), so retrying or spilling is pointless
+var errNonRetryable = errors.New("non retryable response")
+
+// batchEncoder turns a batch of StructuredLog json entries into the request body expected by the endpoint
+type batchEncoder interface {
+	contentType() string
+	encode(batch [][]byte) ([]byte, error)
+}
+
+// httpShipper batches the json entries and POSTs them to the configured endpoint from a background goroutine
+type httpShipper struct {
+	config  *HttpOutputConfig
+	encoder batchEncoder
+	client  *http.Client
+
+	mu         sync.Mutex
+	pending    [][]byte
+	pendingLen int
+	closed     bool
+
+	batches  chan [][]byte
+	stop     chan struct{}
+	wg       sync.WaitGroup
+	spillSeq atomic.Uint64
+
+	// spillMu serialises the spilling and the trimming of SpillDir, replaying is the file trimSpilled leaves alone
+	spillMu   sync.Mutex
+	replaying string
+
+	// ctx bounds the shipping of the worker, cancelled by close when its deadline is hit
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// output and metrics count the failed and dropped batches in the background, diagnostics reports them
+	output      string
+	metrics     *Metrics
+	diagnostics *diagnostics
+
+	// sleep is overridden in tests to avoid waiting on the backoff
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// newHttpShipper creates the shipper with defaults applied and starts the background worker
+// Anything left in config.SpillDir from a previous run is replayed first
+func newHttpShipper(config *HttpOutputConfig, encoder batchEncoder, output string, metrics *Metrics, diagnostics *diagnostics) *httpShipper {
+	cfg := applyHttpDefaults(*config)
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &httpShipper{
+		config:      cfg,
+		encoder:     encoder,
+		output:      output,
+		metrics:     metrics,
+		diagnostics: diagnostics,
+		client:      &http.Client{Timeout: cfg.Timeout},
+		batches:     make(chan [][]byte, 8),
+		stop:        make(chan struct{}),
+		sleep:       sleepContext,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+func applyHttpDefaults(config HttpOutputConfig) *HttpOutputConfig {
+	if config.Format == "" {
+		config.Format = HttpFormatNDJSON
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultHttpBatchSize
+	}
+	if config.MaxBatchBytes <= 0 {
+		config.MaxBatchBytes = defaultHttpMaxBatchBytes
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultHttpFlushInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultHttpTimeout
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = defaultHttpMaxRetries
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultHttpRetryBackoff
+	}
+	if config.MaxRetryBackoff <= 0 {
+		config.MaxRetryBackoff = defaultHttpMaxRetryBackoff
+	}
+	if config.SpillMaxBytes <= 0 {
+		config.SpillMaxBytes = defaultHttpSpillMaxBytes
+	}
+	if config.SpillMaxFiles <= 0 {
+		config.SpillMaxFiles = defaultHttpSpillMaxFiles
+	}
+	return &config
+}
+
+// isEnabled is nil safe, as the http node is optional in the configuration
+func (c *HttpOutputConfig) isEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+func (sl MangoLogger) handleHttpOutput(log *StructuredLog, jsonOut []byte) error {
+	return enqueueByLevel(sl.httpShipper, sl.Config.Out.Http.Debug, log, jsonOut)
+}
+
+// enqueueByLevel hands the entry over to the shipper, debug entries only when debug is allowed for the output
+func enqueueByLevel(shipper *httpShipper, debug bool, log *StructuredLog, jsonOut []byte) error {
+	if shipper == nil {
+		return nil
+	}
+	switch log.Level {
+	case slog.LevelDebug:
+		if debug {
+			return shipper.enqueue(jsonOut)
+		}
+	case slog.LevelInfo:
+		fallthrough
+	case slog.LevelWarn:
+		fallthrough
+	case slog.LevelError:
+		return shipper.enqueue(jsonOut)
+	default:
+		shipper.diagnostics.printf("Record level not one of: debug, info, warn or error\n")
+		return fmt.Errorf("record level not one of: debug, info, warn or error")
+	}
+	return nil
+}
+
+// enqueue adds the entry to the pending batch, handing the batch over to the worker once a limit is hit
+// The batch is spilled after unlocking, so the other callers don't wait on the disk
+func (s *httpShipper) enqueue(jsonOut []byte) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errHttpShipperClosed
+	}
+	s.pending = append(s.pending, slices.Clone(jsonOut))
+	s.pendingLen += len(jsonOut) + 1
+	var overflow [][]byte
+	if len(s.pending) >= s.config.BatchSize || s.pendingLen >= s.config.MaxBatchBytes {
+		batch := s.takePending()
+		select {
+		case s.batches <- batch:
+		default:
+			// the worker is falling behind (endpoint slow or down) - don't block the caller
+			overflow = batch
+		}
+	}
+	s.mu.Unlock()
+
+	if overflow != nil {
+		_ = s.spill(overflow)
+	}
+	return nil
+}
+
+// takePending must be called with mu held
+func (s *httpShipper) takePending() [][]byte {
+	batch := s.pending
+	s.pending = nil
+	s.pendingLen = 0
+	return batch
+}
+
+func (s *httpShipper) run() {
+	defer s.wg.Done()
+	s.replaySpilled()
+
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case batch := <-s.batches:
+			_ = s.ship(s.ctx, batch)
+		case <-ticker.C:
+			s.mu.Lock()
+			batch := s.takePending()
+			s.mu.Unlock()
+			if len(batch) > 0 {
+				_ = s.ship(s.ctx, batch)
+			}
+			s.replaySpilled()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// flush ships whatever is pending right away, waiting for it to be delivered (or spilled)
+func (s *httpShipper) flush(ctx context.Context) error {
+	s.mu.Lock()
+	batch := s.takePending()
+	s.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	return s.ship(ctx, batch)
+}
+
+// close stops the worker, draining queued batches and shipping whatever is pending
+// Once ctx is done the worker's shipping is cancelled and what is left is spilled, or dropped without a SpillDir
+func (s *httpShipper) close(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+	defer s.cancel()
+
+	close(s.stop)
+	stopped := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(stopped)
+	}()
+	var errs []error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		// the batch being shipped is spilled as its send fails
+		s.cancel()
+		<-stopped
+		errs = append(errs, ctx.Err())
+	}
+
+	for {
+		select {
+		case batch := <-s.batches:
+			errs = append(errs, s.shipOrSpill(ctx, batch))
+		default:
+			s.mu.Lock()
+			batch := s.takePending()
+			s.mu.Unlock()
+			if len(batch) > 0 {
+				errs = append(errs, s.shipOrSpill(ctx, batch))
+			}
+			return errors.Join(errs...)
+		}
+	}
+}
+
+// shipOrSpill ships the batch, or spills it straight away once ctx is done
+func (s *httpShipper) shipOrSpill(ctx context.Context, batch [][]byte) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Join(err, s.spill(batch))
+	}
+	return s.ship(ctx, batch)
+}
+
+// ship sends the batch with retries, spilling it to disk if the endpoint stays unavailable
+func (s *httpShipper) ship(ctx context.Context, batch [][]byte) error {
+	err := s.sendWithRetry(ctx, batch)
+	if err == nil {
+		return nil
+	}
+	s.metrics.countWriteError(s.output)
+	if errors.Is(err, errNonRetryable) {
+		s.diagnostics.printf("Dropping %d log entries rejected by %s. %s\n", len(batch), s.config.Endpoint, err.Error())
+		s.metrics.countDropped(s.output, len(batch))
+		return err
+	}
+	if spillErr := s.spill(batch); spillErr != nil {
+		return errors.Join(err, spillErr)
+	}
+	return err
+}
+
+func (s *httpShipper) sendWithRetry(ctx context.Context, batch [][]byte) error {
+	body, err := s.encode(batch)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		err = s.send(ctx, body)
+		if err == nil || errors.Is(err, errNonRetryable) || attempt >= s.config.MaxRetries {
+			return err
+		}
+		if sleepErr := s.sleep(ctx, s.backoff(attempt)); sleepErr != nil {
+			return errors.Join(err, sleepErr)
+		}
+	}
+}
+
+// backoff is exponential on the attempt, capped at MaxRetryBackoff, with a random jitter of up to half of it
+func (s *httpShipper) backoff(attempt int) time.Duration {
+	wait := s.config.RetryBackoff << attempt
+	if wait <= 0 || wait > s.config.MaxRetryBackoff {
+		wait = s.config.MaxRetryBackoff
+	}
+	half := wait / 2
+	return half + rand.N(half+1)
+}
+
+// encode the batch with the output's encoder, compressing it if configured
+func (s *httpShipper) encode(batch [][]byte) ([]byte, error) {
+	body, err := s.encoder.encode(batch)
+	if err != nil || !s.config.Gzip {
+		return body, err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(body); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonBatchEncoder writes the StructuredLog entries as they are, either as ndjson or as a json array
+type jsonBatchEncoder struct {
+	format string
+}
+
+func (e jsonBatchEncoder) contentType() string {
+	if e.format == HttpFormatJSONArray {
+		return "application/json"
+	}
+	return "application/x-ndjson"
+}
+
+func (e jsonBatchEncoder) encode(batch [][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if e.format == HttpFormatJSONArray {
+		buf.WriteByte('[')
+		for i, entry := range batch {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(entry)
+		}
+		buf.WriteByte(']')
+	} else {
+		for _, entry := range batch {
+			buf.Write(entry)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *httpShipper) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errNonRetryable, err)
+	}
+	req.Header.Set("Content-Type", s.encoder.contentType())
+	if s.config.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for key, value := range s.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("endpoint responded with %s", resp.Status)
+	default:
+		return fmt.Errorf("%w: endpoint responded with %s", errNonRetryable, resp.Status)
+	}
+}
+
+// spill writes the batch as ndjson into SpillDir to be replayed later
+func (s *httpShipper) spill(batch [][]byte) error {
+	if s.config.SpillDir == "" {
+		s.diagnostics.printf("Dropping %d log entries, %s unavailable and no spill-dir configured\n", len(batch), s.config.Endpoint)
+		s.metrics.countDropped(s.output, len(batch))
+		return nil
+	}
+	s.spillMu.Lock()
+	defer s.spillMu.Unlock()
+	if err := os.MkdirAll(s.config.SpillDir, 0o750); err != nil {
+		s.metrics.countDropped(s.output, len(batch))
+		return fmt.Errorf("failed to create spill dir: %w", err)
+	}
+
+	// the name sorts in creation order so replay keeps the entries in order
+	name := fmt.Sprintf("%s%020d-%06d%s", spillFilePrefix, time.Now().UnixNano(), s.spillSeq.Add(1)%1000000, spillFileExt)
+	var buf bytes.Buffer
+	for _, entry := range batch {
+		buf.Write(entry)
+		buf.WriteByte('\n')
+	}
+	if err := os.WriteFile(filepath.Join(s.config.SpillDir, name), buf.Bytes(), 0o640); err != nil {
+		s.metrics.countDropped(s.output, len(batch))
+		return fmt.Errorf("failed to spill log entries: %w", err)
+	}
+	s.trimSpilled()
+	return nil
+}
+
+// trimSpilled drops the oldest spilled files while SpillDir holds more than SpillMaxFiles or SpillMaxBytes
+// The newest file and the one being replayed are kept, it must be called with spillMu held
+func (s *httpShipper) trimSpilled() {
+	files, err := s.spilledFiles()
+	if err != nil {
+		return
+	}
+	sizes := make([]int64, len(files))
+	var total int64
+	for i, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			sizes[i] = info.Size()
+			total += sizes[i]
+		}
+	}
+	count := len(files)
+	for i, file := range files[:max(len(files)-1, 0)] {
+		if count <= s.config.SpillMaxFiles && total <= int64(s.config.SpillMaxBytes) {
+			return
+		}
+		if file == s.replaying {
+			continue
+		}
+		content, _ := os.ReadFile(file)
+		if os.Remove(file) != nil {
+			continue
+		}
+		entries := bytes.Count(content, []byte("\n"))
+		s.diagnostics.printf("Dropping %d spilled log entries %s, the spill-dir is over its spill-max-files or spill-max-bytes\n", entries, file)
+		s.metrics.countDropped(s.output, entries)
+		count--
+		total -= sizes[i]
+	}
+}
+
+// replaySpilled sends the spilled batches oldest first, stopping at the first one that can't be delivered
+// The files that can't be read or encoded are quarantined, they would fail on every replay
+func (s *httpShipper) replaySpilled() {
+	if s.config.SpillDir == "" {
+		return
+	}
+	files, err := s.spilledFiles()
+	if err != nil {
+		return
+	}
+	defer s.setReplaying("")
+	for _, file := range files {
+		s.setReplaying(file)
+		batch, err := readSpillFile(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // trimmed meanwhile
+		}
+		if err != nil {
+			s.quarantine(file, len(batch), err)
+			continue
+		}
+		body, err := s.encode(batch)
+		if err != nil {
+			s.quarantine(file, len(batch), err)
+			continue
+		}
+		err = s.send(s.ctx, body)
+		if err != nil && !errors.Is(err, errNonRetryable) {
+			return // endpoint still down, keep it for next time
+		}
+		if err != nil {
+			s.metrics.countWriteError(s.output)
+			s.diagnostics.printf("Dropping %d spilled log entries %s rejected by %s. %s\n", len(batch), file, s.config.Endpoint, err.Error())
+			s.metrics.countDropped(s.output, len(batch))
+		}
+		_ = os.Remove(file)
+	}
+}
+
+func (s *httpShipper) setReplaying(file string) {
+	s.spillMu.Lock()
+	defer s.spillMu.Unlock()
+	s.replaying = file
+}
+
+// quarantine renames the spilled file out of the replay, keeping it for inspection
+func (s *httpShipper) quarantine(file string, entries int, cause error) {
+	s.metrics.countDropped(s.output, entries)
+	if err := os.Rename(file, file+quarantineExt); err != nil {
+		s.diagnostics.printf("Failed to quarantine spilled log entries %s, removing them. %s\n", file, err.Error())
+		_ = os.Remove(file)
+		return
+	}
+	s.diagnostics.printf("Quarantined spilled log entries %s that can't be replayed. %s\n", file+quarantineExt, cause.Error())
+}
+
+func (s *httpShipper) spilledFiles() ([]string, error) {
+	entries, err := os.ReadDir(s.config.SpillDir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), spillFilePrefix) && strings.HasSuffix(entry.Name(), spillFileExt) {
+			files = append(files, filepath.Join(s.config.SpillDir, entry.Name()))
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+func readSpillFile(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var batch [][]byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), defaultHttpMaxBatchBytes*16)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			batch = append(batch, slices.Clone(scanner.Bytes()))
+		}
+	}
+	return batch, scanner.Err()
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
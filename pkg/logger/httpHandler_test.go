@@ -0,0 +1,404 @@
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// collector is a stand-in for Loki, Splunk HEC or any in-house collector
+type collector struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	entries  []map[string]interface{}
+	status   atomic.Int32
+}
+
+func newCollector(t *testing.T) (*collector, *httptest.Server) {
+	c := &collector{}
+	c.status.Store(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := int(c.status.Load())
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			assert.NoError(t, err)
+			body = gz
+		}
+
+		var entries []map[string]interface{}
+		if r.Header.Get("Content-Type") == "application/json" {
+			assert.NoError(t, json.NewDecoder(body).Decode(&entries))
+		} else {
+			scanner := bufio.NewScanner(body)
+			for scanner.Scan() {
+				entry := map[string]interface{}{}
+				assert.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+				entries = append(entries, entry)
+			}
+		}
+
+		c.mu.Lock()
+		c.requests = append(c.requests, r)
+		c.entries = append(c.entries, entries...)
+		c.mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return c, server
+}
+
+func (c *collector) received() ([]*http.Request, []map[string]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*http.Request{}, c.requests...), append([]map[string]interface{}{}, c.entries...)
+}
+
+func newHttpTestLogger(httpConfig *HttpOutputConfig) *MangoLogger {
+	return NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled: true,
+			File:    &FileOutputConfig{},
+			Cli:     &CliConfig{},
+			Syslog:  &SyslogConfig{},
+			Http:    httpConfig,
+		},
+		MangoConfig: &MangoConfig{
+			CorrelationId: &CorrelationIdConfig{AutoGenerate: true},
+		},
+	})
+}
+
+func logRecord(t *testing.T, logger *MangoLogger, level slog.Level, msg string) {
+	err := logger.Handle(context.Background(), slog.Record{Time: time.Now(), Level: level, Message: msg})
+	assert.NoError(t, err)
+}
+
+func TestHttpOutput_FlushOnBatchSize(t *testing.T) {
+	c, server := newCollector(t)
+	logger := newHttpTestLogger(&HttpOutputConfig{
+		Enabled:       true,
+		Endpoint:      server.URL,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		Headers:       map[string]string{"Authorization": "Splunk secret"},
+	})
+	defer func() { _ = logger.httpShipper.close(context.Background()) }()
+
+	logRecord(t, logger, slog.LevelInfo, "first")
+	logRecord(t, logger, slog.LevelDebug, "not shipped without debug")
+	logRecord(t, logger, slog.LevelError, "second")
+
+	assert.Eventually(t, func() bool {
+		_, entries := c.received()
+		return len(entries) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	requests, entries := c.received()
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "Splunk secret", requests[0].Header.Get("Authorization"))
+	assert.Equal(t, "application/x-ndjson", requests[0].Header.Get("Content-Type"))
+	assert.Equal(t, "first", entries[0]["message"])
+	assert.Equal(t, "second", entries[1]["message"])
+}
+
+func TestHttpOutput_FlushOnInterval(t *testing.T) {
+	c, server := newCollector(t)
+	logger := newHttpTestLogger(&HttpOutputConfig{
+		Enabled:       true,
+		Endpoint:      server.URL,
+		FlushInterval: 20 * time.Millisecond,
+	})
+	defer func() { _ = logger.httpShipper.close(context.Background()) }()
+
+	logRecord(t, logger, slog.LevelInfo, "lonely")
+
+	assert.Eventually(t, func() bool {
+		_, entries := c.received()
+		return len(entries) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestHttpOutput_FlushOnMaxBatchBytes(t *testing.T) {
+	c, server := newCollector(t)
+	logger := newHttpTestLogger(&HttpOutputConfig{
+		Enabled:       true,
+		Endpoint:      server.URL,
+		MaxBatchBytes: 10,
+		FlushInterval: time.Hour,
+	})
+	defer func() { _ = logger.httpShipper.close(context.Background()) }()
+
+	logRecord(t, logger, slog.LevelInfo, "bigger than ten bytes")
+
+	assert.Eventually(t, func() bool {
+		_, entries := c.received()
+		return len(entries) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestHttpOutput_JSONArrayGzip(t *testing.T) {
+	c, server := newCollector(t)
+	logger := newHttpTestLogger(&HttpOutputConfig{
+		Enabled:       true,
+		Debug:         true,
+		Endpoint:      server.URL,
+		Format:        HttpFormatJSONArray,
+		Gzip:          true,
+		FlushInterval: time.Hour,
+	})
+
+	logRecord(t, logger, slog.LevelDebug, "one")
+	logRecord(t, logger, slog.LevelWarn, "two")
+	assert.NoError(t, logger.httpShipper.close(context.Background()))
+
+	requests, entries := c.received()
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "gzip", requests[0].Header.Get("Content-Encoding"))
+	assert.Equal(t, "application/json", requests[0].Header.Get("Content-Type"))
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "one", entries[0]["message"])
+
+	err := logger.Handle(context.Background(), slog.Record{Time: time.Now(), Level: slog.LevelInfo, Message: "late"})
+	assert.ErrorIs(t, err, errHttpShipperClosed)
+}
+
+func TestHttpOutput_RetryThenSuccess(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	s := &httpShipper{
+		config:  applyHttpDefaults(HttpOutputConfig{Endpoint: server.URL, MaxRetries: 3}),
+		encoder: jsonBatchEncoder{},
+		client:  server.Client(),
+	}
+	var waits []time.Duration
+	s.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	err := s.ship(context.Background(), [][]byte{[]byte(`{"message":"retry"}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Len(t, waits, 2)
+}
+
+func TestHttpOutput_NonRetryableIsDropped(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	s := &httpShipper{
+		config:  applyHttpDefaults(HttpOutputConfig{Endpoint: server.URL, SpillDir: dir}),
+		encoder: jsonBatchEncoder{},
+		client:  server.Client(),
+		sleep:   sleepContext,
+		output:  OutputHttp,
+		metrics: newMetrics(),
+	}
+
+	err := s.ship(context.Background(), [][]byte{[]byte(`{"message":"bad"}`)})
+	assert.ErrorIs(t, err, errNonRetryable)
+	assert.Equal(t, int32(1), calls.Load())
+	files, _ := os.ReadDir(dir)
+	assert.Empty(t, files)
+	assert.Equal(t, uint64(1), s.metrics.Snapshot().Dropped[OutputHttp])
+	assert.Equal(t, uint64(1), s.metrics.Snapshot().WriteErrors[OutputHttp])
+}
+
+func TestHttpOutput_Backoff(t *testing.T) {
+	s := &httpShipper{config: applyHttpDefaults(HttpOutputConfig{
+		RetryBackoff:    100 * time.Millisecond,
+		MaxRetryBackoff: time.Second,
+	})}
+
+	for attempt, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		wait := s.backoff(attempt)
+		assert.GreaterOrEqual(t, wait, max/2, "attempt %d", attempt)
+		assert.LessOrEqual(t, wait, max, "attempt %d", attempt)
+	}
+	assert.LessOrEqual(t, s.backoff(100), time.Second)
+}
+
+func TestHttpOutput_SpillAndReplay(t *testing.T) {
+	c, server := newCollector(t)
+	c.status.Store(http.StatusServiceUnavailable)
+	dir := t.TempDir()
+
+	config := &HttpOutputConfig{
+		Enabled:         true,
+		Endpoint:        server.URL,
+		MaxRetries:      1,
+		RetryBackoff:    time.Millisecond,
+		MaxRetryBackoff: time.Millisecond,
+		FlushInterval:   time.Hour,
+		SpillDir:        dir,
+	}
+	logger := newHttpTestLogger(config)
+	logRecord(t, logger, slog.LevelInfo, "spilled one")
+	logRecord(t, logger, slog.LevelInfo, "spilled two")
+	assert.Error(t, logger.httpShipper.close(context.Background()))
+
+	files, err := filepath.Glob(filepath.Join(dir, spillFilePrefix+"*"+spillFileExt))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	_, entries := c.received()
+	assert.Empty(t, entries)
+
+	// endpoint is back, a restart replays what was spilled
+	c.status.Store(http.StatusOK)
+	restarted := newHttpTestLogger(config)
+	defer func() { _ = restarted.httpShipper.close(context.Background()) }()
+
+	assert.Eventually(t, func() bool {
+		_, entries := c.received()
+		return len(entries) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+	_, entries = c.received()
+	assert.Equal(t, "spilled one", entries[0]["message"])
+	assert.Equal(t, "spilled two", entries[1]["message"])
+	assert.Eventually(t, func() bool {
+		files, _ := filepath.Glob(filepath.Join(dir, spillFilePrefix+"*"))
+		return len(files) == 0
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestHttpOutput_ReplayDropsRejected(t *testing.T) {
+	c, server := newCollector(t)
+	c.status.Store(http.StatusBadRequest)
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, spillFilePrefix+"1"+spillFileExt), []byte(`{"message":"one"}`+"\n"+`{"message":"two"}`+"\n"), 0o640))
+
+	logger := newHttpTestLogger(&HttpOutputConfig{Enabled: true, Endpoint: server.URL, FlushInterval: time.Hour, SpillDir: dir})
+	assert.NoError(t, logger.httpShipper.close(context.Background())) // the worker replays on start
+
+	files, _ := os.ReadDir(dir)
+	assert.Empty(t, files)
+	snapshot := logger.Metrics().Snapshot()
+	assert.Equal(t, uint64(2), snapshot.Dropped[OutputHttp], "the rejected entries are counted as dropped")
+	assert.Equal(t, uint64(1), snapshot.WriteErrors[OutputHttp])
+}
+
+func TestHttpOutput_SpillLimits(t *testing.T) {
+	tests := map[string]HttpOutputConfig{
+		"max files": {SpillMaxFiles: 2},
+		"max bytes": {SpillMaxBytes: 40},
+	}
+	for name, config := range tests {
+		t.Run(name, func(t *testing.T) {
+			config.Endpoint = "http://localhost"
+			config.BatchSize = 1
+			config.SpillDir = t.TempDir()
+			s := &httpShipper{
+				config:      applyHttpDefaults(config),
+				batches:     make(chan [][]byte), // no worker receiving
+				output:      OutputHttp,
+				metrics:     newMetrics(),
+				diagnostics: newDiagnostics(time.Minute),
+			}
+			s.diagnostics.out = io.Discard
+			for _, message := range []string{"one", "two", "three"} {
+				assert.NoError(t, s.enqueue([]byte(`{"message":"`+message+`"}`)))
+			}
+
+			files, err := s.spilledFiles()
+			assert.NoError(t, err)
+			assert.Len(t, files, 2, "the oldest file is dropped")
+			batch, err := readSpillFile(files[0])
+			assert.NoError(t, err)
+			assert.Equal(t, [][]byte{[]byte(`{"message":"two"}`)}, batch)
+			assert.Equal(t, uint64(1), s.metrics.Snapshot().Dropped[OutputHttp])
+		})
+	}
+}
+
+func TestHttpOutput_DisabledNodeIsIgnored(t *testing.T) {
+	logger := newTestLogger(false, false, false, true)
+	assert.Nil(t, logger.httpShipper)
+	assert.False(t, logger.Config.Out.Http.isEnabled())
+}
+
+func TestHttpOutput_CloseBoundedByContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release // hangs until the end of the test
+	}))
+	defer server.Close()
+	defer close(release)
+
+	dir := t.TempDir()
+	logger := newHttpTestLogger(&HttpOutputConfig{
+		Enabled:       true,
+		Endpoint:      server.URL,
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+		SpillDir:      dir,
+	})
+	logRecord(t, logger, slog.LevelInfo, "in flight")
+	logRecord(t, logger, slog.LevelInfo, "queued")
+	time.Sleep(50 * time.Millisecond) // the worker is stuck on the first batch
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Error(t, logger.httpShipper.close(ctx))
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	files, err := filepath.Glob(filepath.Join(dir, spillFilePrefix+"*"+spillFileExt))
+	assert.NoError(t, err)
+	spilled := 0
+	for _, file := range files {
+		batch, err := readSpillFile(file)
+		assert.NoError(t, err)
+		spilled += len(batch)
+	}
+	assert.Equal(t, 2, spilled, "both entries are spilled on the deadline")
+}
+
+func TestHttpOutput_SpillsWhenWorkerFallsBehind(t *testing.T) {
+	dir := t.TempDir()
+	s := &httpShipper{
+		config:  applyHttpDefaults(HttpOutputConfig{Endpoint: "http://localhost", BatchSize: 1, SpillDir: dir}),
+		batches: make(chan [][]byte), // no worker receiving
+		output:  OutputHttp,
+		metrics: newMetrics(),
+	}
+	assert.NoError(t, s.enqueue([]byte(`{"message":"one"}`)))
+	assert.NoError(t, s.enqueue([]byte(`{"message":"two"}`)))
+	assert.Empty(t, s.pending)
+
+	files, err := s.spilledFiles()
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	batch, err := readSpillFile(files[0])
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte(`{"message":"one"}`)}, batch)
+}
@@ -0,0 +1,274 @@
+package logger
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Id formats, selecting a built-in IDGenerator from the configuration
+const (
+	// IdFormatUUIDv4 random UUIDs, the default
+	IdFormatUUIDv4 = "uuidv4"
+
+	// IdFormatUUIDv7 time ordered UUIDs
+	IdFormatUUIDv7 = "uuidv7"
+
+	// IdFormatULID time ordered, lexicographically sortable, 26 character ids
+	IdFormatULID = "ulid"
+
+	// IdFormatTraceId 32 lowercase hex character ids, valid W3C trace ids
+	IdFormatTraceId = "trace-id"
+)
+
+// Patterns matching the ids of the built-in generators, for CorrelationIdConfig.Pattern
+const (
+	// CorrelationIdPatternUUID matches any version of UUID in its canonical, lowercase, form
+	CorrelationIdPatternUUID = `^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`
+
+	// CorrelationIdPatternULID matches a ULID, in any case as ULIDs are case insensitive and normalising lowercases them
+	CorrelationIdPatternULID = `(?i)^[0-7][0-9A-HJKMNP-TV-Z]{25}$`
+
+	// CorrelationIdPatternTraceId matches a W3C trace id
+	CorrelationIdPatternTraceId = `^[0-9a-f]{32}$`
+)
+
+// Actions on an invalid incoming correlation id
+const (
+	// CorrelationIdInvalidReplace logs a generated correlation id instead, the default
+	CorrelationIdInvalidReplace = "replace"
+
+	// CorrelationIdInvalidReject fails the entry as a strict violation, applying the strict failure policy
+	CorrelationIdInvalidReject = "reject"
+)
+
+// IDGenerator generates the ids of the entries and the missing correlation ids, it must be safe for concurrent use
+type IDGenerator interface {
+	NewId() string
+}
+
+// IDGeneratorFunc is a function used as an IDGenerator
+type IDGeneratorFunc func() string
+
+// NewId calls f
+func (f IDGeneratorFunc) NewId() string {
+	return f()
+}
+
+// NewUUIDv4Generator generates random UUIDs
+func NewUUIDv4Generator() IDGenerator {
+	return IDGeneratorFunc(func() string {
+		return uuid.New().String()
+	})
+}
+
+// NewUUIDv7Generator generates time ordered UUIDs
+func NewUUIDv7Generator() IDGenerator {
+	return IDGeneratorFunc(func() string {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return uuid.New().String()
+		}
+		return id.String()
+	})
+}
+
+// NewTraceIdGenerator generates ids valid as W3C trace ids, so a correlation id can be the trace id
+func NewTraceIdGenerator() IDGenerator {
+	return IDGeneratorFunc(func() string {
+		var id [16]byte
+		for id == [16]byte{} {
+			_, _ = rand.Read(id[:])
+		}
+		return hex.EncodeToString(id[:])
+	})
+}
+
+// NewULIDGenerator generates ULIDs, monotonic within the same millisecond
+func NewULIDGenerator() IDGenerator {
+	return &ulidGenerator{now: time.Now}
+}
+
+// crockford is the base32 alphabet of ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+type ulidGenerator struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	lastMs  uint64
+	entropy [10]byte
+}
+
+func (g *ulidGenerator) NewId() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ms := uint64(g.now().UnixMilli())
+	if ms <= g.lastMs && !increment(g.entropy[:]) {
+		// same millisecond, the random part is incremented so the ids stay ordered
+		ms = g.lastMs
+	} else {
+		_, _ = rand.Read(g.entropy[:])
+		g.lastMs = ms
+	}
+
+	var id [16]byte
+	binary.BigEndian.PutUint16(id[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(ms))
+	copy(id[6:], g.entropy[:])
+	return encodeULID(id)
+}
+
+// increment adds one to the big endian number b, returning true on overflow
+func increment(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeULID encodes the 128 bits in 26 characters of 5 bits, the first one holding the 3 top bits
+func encodeULID(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[0:8])
+	lo := binary.BigEndian.Uint64(id[8:16])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// idGenerator returns the built-in generator of format, nil when unknown
+func idGenerator(format string) IDGenerator {
+	switch format {
+	case "", IdFormatUUIDv4:
+		return NewUUIDv4Generator()
+	case IdFormatUUIDv7:
+		return NewUUIDv7Generator()
+	case IdFormatULID:
+		return NewULIDGenerator()
+	case IdFormatTraceId:
+		return NewTraceIdGenerator()
+	default:
+		return nil
+	}
+}
+
+// ids generates the log and correlation ids, and checks the incoming correlation ids
+type ids struct {
+	logIds         IDGenerator
+	correlationIds IDGenerator
+	pattern        *regexp.Regexp
+	patternErr     error
+	maxLength      int
+	normalise      bool
+	onInvalid      string
+}
+
+// newIds reports the configuration it ignores through diagnostics
+// A pattern that doesn't compile fails every incoming correlation id, rather than leaving them unchecked
+func newIds(config *MangoConfig, diagnostics *diagnostics) *ids {
+	i := &ids{logIds: NewUUIDv4Generator()}
+	if config == nil {
+		i.correlationIds = i.logIds
+		return i
+	}
+	if config.IdGenerator != nil {
+		i.logIds = config.IdGenerator
+	} else if generator := idGenerator(config.IdFormat); generator != nil {
+		i.logIds = generator
+	} else {
+		diagnostics.printf("Ignoring config.mango.id-format, %q is not one of %+q\n", config.IdFormat, []string{IdFormatUUIDv4, IdFormatUUIDv7, IdFormatULID, IdFormatTraceId})
+	}
+	i.correlationIds = i.logIds
+
+	correlation := config.CorrelationId
+	if correlation == nil {
+		return i
+	}
+	if correlation.Generator != nil {
+		i.correlationIds = correlation.Generator
+	} else if correlation.Format != "" {
+		if generator := idGenerator(correlation.Format); generator != nil {
+			i.correlationIds = generator
+		} else {
+			diagnostics.printf("Ignoring config.mango.correlation-id.format, %q is not one of %+q\n", correlation.Format, []string{IdFormatUUIDv4, IdFormatUUIDv7, IdFormatULID, IdFormatTraceId})
+		}
+	}
+	if correlation.Pattern != "" {
+		i.pattern, i.patternErr = regexp.Compile(correlation.Pattern)
+		if i.patternErr != nil {
+			diagnostics.printf("Invalid config.mango.correlation-id.pattern, every incoming correlation id fails the check. %s\n", i.patternErr.Error())
+		}
+	}
+	i.maxLength = correlation.MaxLength
+	i.normalise = correlation.Normalise
+	i.onInvalid = correlation.OnInvalid
+	return i
+}
+
+// The methods are nil safe, a MangoLogger not created with NewMangoLogger generates UUIDv4 and checks nothing
+
+func (i *ids) newLogId() string {
+	if i == nil {
+		return uuid.New().String()
+	}
+	return i.logIds.NewId()
+}
+
+func (i *ids) newCorrelationId() string {
+	if i == nil {
+		return uuid.New().String()
+	}
+	return i.correlationIds.NewId()
+}
+
+// checkCorrelationId returns the normalised correlation id, with the reason it is invalid if it is
+func (i *ids) checkCorrelationId(value string) (string, string) {
+	if i == nil {
+		return value, ""
+	}
+	if i.normalise {
+		value = strings.ToLower(strings.TrimSpace(value))
+	}
+	if i.patternErr != nil {
+		return value, fmt.Sprintf("unchecked, invalid pattern: %s", i.patternErr.Error())
+	}
+	if i.maxLength > 0 && utf8.RuneCountInString(value) > i.maxLength {
+		return value, fmt.Sprintf("longer than %d characters", i.maxLength)
+	}
+	if i.pattern != nil && !i.pattern.MatchString(value) {
+		return value, fmt.Sprintf("does not match %s", i.pattern)
+	}
+	return value, ""
+}
+
+// correlationId sets the incoming correlation id of the entry, replacing or rejecting it when invalid
+func (sl MangoLogger) correlationId(logOutput *StructuredLog, value string) error {
+	value, reason := sl.ids.checkCorrelationId(value)
+	if reason == "" {
+		logOutput.Correlationid = value
+		return nil
+	}
+	if sl.ids.onInvalid == CorrelationIdInvalidReject {
+		return &strictViolation{
+			label:   CORRELATION_ID,
+			reason:  "invalid, " + reason,
+			message: fmt.Sprintf("%s - [%s] is invalid, %s", errStrictModeOn, CORRELATION_ID, reason),
+		}
+	}
+	logOutput.Correlationid = sl.ids.newCorrelationId()
+	return nil
+}
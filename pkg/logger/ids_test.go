@@ -0,0 +1,167 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdGenerators(t *testing.T) {
+	tests := []struct {
+		format  string
+		pattern string
+	}{
+		{IdFormatUUIDv4, CorrelationIdPatternUUID},
+		{IdFormatUUIDv7, CorrelationIdPatternUUID},
+		{IdFormatULID, CorrelationIdPatternULID},
+		{IdFormatTraceId, CorrelationIdPatternTraceId},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			generator := idGenerator(tt.format)
+			first, second := generator.NewId(), generator.NewId()
+			assert.Regexp(t, tt.pattern, first)
+			assert.NotEqual(t, first, second)
+		})
+	}
+	assert.Nil(t, idGenerator("snowflake"))
+
+	id, err := uuid.Parse(NewUUIDv7Generator().NewId())
+	assert.NoError(t, err)
+	assert.Equal(t, uuid.Version(7), id.Version())
+}
+
+func TestULIDGenerator_Ordered(t *testing.T) {
+	now := time.UnixMilli(1736934814717)
+	generator := &ulidGenerator{now: func() time.Time { return now }}
+
+	var ids []string
+	for range 100 {
+		ids = append(ids, generator.NewId())
+	}
+	now = now.Add(time.Millisecond)
+	ids = append(ids, generator.NewId())
+
+	assert.True(t, slices.IsSorted(ids))
+	assert.Len(t, slices.Compact(slices.Clone(ids)), len(ids))
+	assert.Equal(t, "01JHMP3WZX", ids[0][:10]) // the 48 bits timestamp
+}
+
+func TestEncodeULID(t *testing.T) {
+	assert.Equal(t, "00000000000000000000000000", encodeULID([16]byte{}))
+	var max [16]byte
+	for i := range max {
+		max[i] = 0xff
+	}
+	assert.Equal(t, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", encodeULID(max))
+}
+
+func TestIncrement(t *testing.T) {
+	b := []byte{0x00, 0xff}
+	assert.False(t, increment(b))
+	assert.Equal(t, []byte{0x01, 0x00}, b)
+
+	b = []byte{0xff, 0xff}
+	assert.True(t, increment(b))
+}
+
+func TestNewIds(t *testing.T) {
+	fixed := IDGeneratorFunc(func() string { return "fixed" })
+
+	i := newIds(&MangoConfig{IdFormat: IdFormatTraceId}, nil)
+	assert.Regexp(t, CorrelationIdPatternTraceId, i.newLogId())
+	assert.Regexp(t, CorrelationIdPatternTraceId, i.newCorrelationId())
+
+	i = newIds(&MangoConfig{IdGenerator: fixed, CorrelationId: &CorrelationIdConfig{Format: IdFormatULID}}, nil)
+	assert.Equal(t, "fixed", i.newLogId())
+	assert.Regexp(t, CorrelationIdPatternULID, i.newCorrelationId())
+
+	var out bytes.Buffer
+	diagnostics := newDiagnostics(time.Minute)
+	diagnostics.out = &out
+	i = newIds(&MangoConfig{IdFormat: "snowflake", CorrelationId: &CorrelationIdConfig{Generator: fixed, Pattern: "("}}, diagnostics)
+	assert.Regexp(t, CorrelationIdPatternUUID, i.newLogId())
+	assert.Equal(t, "fixed", i.newCorrelationId())
+	assert.Contains(t, out.String(), `Ignoring config.mango.id-format, "snowflake"`)
+	assert.Contains(t, out.String(), "Invalid config.mango.correlation-id.pattern")
+	value, reason := i.checkCorrelationId("fixed")
+	assert.Contains(t, reason, "invalid pattern", "the ids are not left unchecked")
+	assert.Equal(t, "fixed", value)
+
+	var none *ids
+	assert.Regexp(t, CorrelationIdPatternUUID, none.newLogId())
+	assert.Regexp(t, CorrelationIdPatternUUID, none.newCorrelationId())
+	value, reason = none.checkCorrelationId(" anything ")
+	assert.Equal(t, " anything ", value)
+	assert.Empty(t, reason)
+}
+
+func TestCheckCorrelationId(t *testing.T) {
+	i := newIds(&MangoConfig{CorrelationId: &CorrelationIdConfig{Normalise: true, MaxLength: 36, Pattern: CorrelationIdPatternUUID}}, nil)
+
+	value, reason := i.checkCorrelationId(" A52B0129-9D49-4F29-ACBB-3575AA4442F4\n")
+	assert.Equal(t, "a52b0129-9d49-4f29-acbb-3575aa4442f4", value)
+	assert.Empty(t, reason)
+
+	_, reason = i.checkCorrelationId("a52b0129-9d49-4f29-acbb-3575aa4442f4-and-more")
+	assert.Equal(t, "longer than 36 characters", reason)
+
+	short := newIds(&MangoConfig{CorrelationId: &CorrelationIdConfig{MaxLength: 4}}, nil)
+	_, reason = short.checkCorrelationId("ééé€") // 4 characters, 9 bytes
+	assert.Empty(t, reason)
+	_, reason = short.checkCorrelationId("ééé€€")
+	assert.Equal(t, "longer than 4 characters", reason)
+
+	_, reason = i.checkCorrelationId("'; DROP TABLE logs; --")
+	assert.Equal(t, "does not match "+CorrelationIdPatternUUID, reason)
+
+	ulid := newIds(&MangoConfig{CorrelationId: &CorrelationIdConfig{Normalise: true, Pattern: CorrelationIdPatternULID}}, nil)
+	value, reason = ulid.checkCorrelationId(" 01JHMM5BQXAAAAAAAAAAAAAAAA")
+	assert.Equal(t, "01jhmm5bqxaaaaaaaaaaaaaaaa", value)
+	assert.Empty(t, reason, "a normalised ULID is still valid")
+	_, reason = ulid.checkCorrelationId("01JHMM5BQXAAAAAAAAAAAAAAAU")
+	assert.NotEmpty(t, reason, "U is not in the ULID alphabet")
+}
+
+func newIdsTestLogger(sink EntrySink, correlation *CorrelationIdConfig) *MangoLogger {
+	handler := newSinkTestLogger(sink)
+	handler.Config.MangoConfig.CorrelationId = correlation
+	handler.ids = newIds(handler.Config.MangoConfig, nil)
+	return handler
+}
+
+func TestMangoLogger_InvalidCorrelationId(t *testing.T) {
+	ctx := context.WithValue(context.Background(), CORRELATION_ID, "not\na-uuid")
+
+	sink := &sliceSink{}
+	logger := slog.New(newIdsTestLogger(sink, &CorrelationIdConfig{AutoGenerate: true, Pattern: CorrelationIdPatternULID, Format: IdFormatULID}))
+	logger.InfoContext(ctx, "replaced")
+	logger.InfoContext(context.WithValue(ctx, CORRELATION_ID, "01JHMM5BQXAAAAAAAAAAAAAAAA"), "kept")
+	assert.Regexp(t, CorrelationIdPatternULID, sink.entries[0].Correlationid)
+	assert.Equal(t, "01JHMM5BQXAAAAAAAAAAAAAAAA", sink.entries[1].Correlationid)
+
+	sink = &sliceSink{}
+	handler := newIdsTestLogger(sink, &CorrelationIdConfig{AutoGenerate: true, MaxLength: 4, OnInvalid: CorrelationIdInvalidReject})
+	err := handler.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelInfo, "rejected", 0))
+	assert.True(t, errors.Is(err, errStrictModeOn))
+	assert.Equal(t, []string{"correlationid: invalid, longer than 4 characters"}, violations(err))
+	assert.Empty(t, sink.entries)
+	assert.Equal(t, uint64(1), handler.Metrics().Snapshot().StrictRejections)
+}
+
+func TestMangoLogger_IdFormat(t *testing.T) {
+	sink := &sliceSink{}
+	handler := newSinkTestLogger(sink)
+	handler.ids = newIds(&MangoConfig{IdFormat: IdFormatUUIDv7}, nil)
+	slog.New(handler).Info("ordered")
+
+	assert.True(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7`).MatchString(sink.entries[0].LogId), sink.entries[0].LogId)
+}
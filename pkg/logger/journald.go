@@ -0,0 +1,193 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultJournalSocket is where systemd-journald listens for the native protocol
+const defaultJournalSocket = "/run/systemd/journal/socket"
+
+// journalFields are set from the entry, an attribute with the same name is prefixed with ATTR_
+// They are the native fields and the json fields of StructuredLog, with the service fields written at the top level
+var journalFields = slices.Concat(
+	[]string{"MESSAGE", "PRIORITY", "SYSLOG_IDENTIFIER", "CODE_FILE", "CODE_LINE", "CODE_FUNC"},
+	journalFieldsOf(reflect.TypeFor[StructuredLog]()),
+	journalFieldsOf(reflect.TypeFor[ServiceMetadata]()),
+)
+
+// journalFieldsOf returns the uppercased json names of the fields of t, as encode writes them
+func journalFieldsOf(t reflect.Type) []string {
+	var names []string
+	for _, field := range reflect.VisibleFields(t) {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, strings.ToUpper(name))
+	}
+	return names
+}
+
+// isEnabled is nil safe, as the journald node is optional in the configuration
+func (c *JournaldConfig) isEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// journal sends the entries over the journal socket, shared by the handlers derived from a MangoLogger
+type journal struct {
+	config *JournaldConfig
+	path   string
+
+	mu   sync.Mutex
+	conn *net.UnixConn // nil until the first entry, and after a failed write
+}
+
+func newJournal(config *JournaldConfig) *journal {
+	j := &journal{config: config, path: config.SocketPath}
+	if j.path == "" {
+		j.path = defaultJournalSocket
+	}
+	return j
+}
+
+func (sl MangoLogger) handleJournaldOutput(log *StructuredLog) error {
+	if sl.journal == nil {
+		return nil
+	}
+	switch log.Level {
+	case slog.LevelDebug:
+		if !sl.Config.Out.Journald.Debug {
+			return nil
+		}
+	case slog.LevelInfo, slog.LevelWarn, slog.LevelError:
+	default:
+		sl.diagnostic("Record level not one of: debug, info, warn or error\n")
+		return fmt.Errorf("record level not one of: debug, info, warn or error")
+	}
+	return sl.journal.send(sl.journal.encode(log))
+}
+
+// encode the entry in the native journal protocol, one KEY=value line per field
+// A value with a newline is written as KEY, a newline, its little endian 64 bit length, the value and a newline
+func (j *journal) encode(log *StructuredLog) []byte {
+	var b bytes.Buffer
+	identifier := j.config.Identifier
+	if identifier == "" {
+		identifier = log.Application
+	}
+	writeJournalField(&b, "MESSAGE", fmt.Sprint(log.Message))
+	writeJournalField(&b, "PRIORITY", strconv.Itoa(journalPriority(log.Level)))
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", identifier)
+	if log.pc != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{log.pc}).Next()
+		writeJournalField(&b, "CODE_FILE", frame.File)
+		writeJournalField(&b, "CODE_LINE", strconv.Itoa(frame.Line))
+		writeJournalField(&b, "CODE_FUNC", frame.Function)
+	}
+
+	var fields map[string]interface{}
+	content, _ := json.Marshal(log)
+	_ = json.Unmarshal(content, &fields)
+	delete(fields, "message")
+	delete(fields, "attributes")
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		writeJournalField(&b, strings.ToUpper(name), journalValue(fields[name]))
+	}
+
+	attributes := map[string]string{}
+	flattenJournalAttributes(attributes, "", log.Attributes)
+	for _, name := range slices.Sorted(maps.Keys(attributes)) {
+		writeJournalField(&b, name, attributes[name])
+	}
+	return b.Bytes()
+}
+
+// flattenJournalAttributes names the attributes as journal fields, a nested group joining its keys with _
+func flattenJournalAttributes(fields map[string]string, prefix string, attributes map[string]interface{}) {
+	for key, value := range attributes {
+		name := prefix + journalFieldName(key)
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenJournalAttributes(fields, name+"_", nested)
+			continue
+		}
+		if prefix == "" && (name == "" || slices.Contains(journalFields, name)) {
+			name = "ATTR_" + name
+		}
+		fields[name] = journalValue(value)
+	}
+}
+
+// journalFieldName uppercases key, replacing what the journal doesn't accept with _
+// Names can't start with _, reserved to the journal, nor a digit, and are at most 64 characters long
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+	trimmed := strings.TrimLeft(string(name), "_")
+	if trimmed != "" && trimmed[0] >= '0' && trimmed[0] <= '9' {
+		trimmed = "F" + trimmed
+	}
+	if len(trimmed) > 64 {
+		trimmed = trimmed[:64]
+	}
+	return trimmed
+}
+
+// journalValue is a string as is, anything else its json
+func journalValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	content, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(content)
+}
+
+func writeJournalField(b *bytes.Buffer, name string, value string) {
+	if !strings.Contains(value, "\n") {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteString(name)
+	b.WriteByte('\n')
+	_ = binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// journalPriority is the syslog severity of level
+func journalPriority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
@@ -0,0 +1,103 @@
+//go:build linux
+
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// memfd_create and sealing flags, missing from the syscall package
+const (
+	mfdCloexec       = 0x1
+	mfdAllowSealing  = 0x2
+	fAddSeals        = 1033
+	fSealSeal        = 0x1
+	fSealShrink      = 0x2
+	fSealGrow        = 0x4
+	fSealWrite       = 0x8
+	journalMemfdName = "mango-journal"
+)
+
+// memfdCreate is the memfd_create syscall number per architecture, the syscall package only has it for a few
+var memfdCreate = map[string]uintptr{
+	"386":      356,
+	"amd64":    319,
+	"arm":      385,
+	"arm64":    279,
+	"loong64":  279,
+	"mips":     4354,
+	"mipsle":   4354,
+	"mips64":   5314,
+	"mips64le": 5314,
+	"ppc64":    360,
+	"ppc64le":  360,
+	"riscv64":  279,
+	"s390x":    350,
+}
+
+// send writes the entry as one datagram, or through a sealed memfd when it is too large for a datagram
+func (j *journal) send(entry []byte) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.conn == nil {
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: j.path, Net: "unixgram"})
+		if err != nil {
+			return fmt.Errorf("failed to connect to the journal at %s: %w", j.path, err)
+		}
+		j.conn = conn
+	}
+
+	_, err := j.conn.Write(entry)
+	if errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS) {
+		err = j.sendMemfd(entry)
+	}
+	if err != nil {
+		// reconnected on the next entry, e.g. after journald restarted
+		_ = j.conn.Close()
+		j.conn = nil
+		return fmt.Errorf("failed to write to the journal at %s: %w", j.path, err)
+	}
+	return nil
+}
+
+// sendMemfd passes the entry in a sealed memory file, as journald requires for large entries
+func (j *journal) sendMemfd(entry []byte) error {
+	trap, ok := memfdCreate[runtime.GOARCH]
+	if !ok {
+		return fmt.Errorf("entry of %d bytes too large for a datagram, and no memfd on %s", len(entry), runtime.GOARCH)
+	}
+	name, err := syscall.BytePtrFromString(journalMemfdName)
+	if err != nil {
+		return err
+	}
+	fd, _, errno := syscall.Syscall(trap, uintptr(unsafe.Pointer(name)), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return fmt.Errorf("memfd_create: %w", errno)
+	}
+	file := os.NewFile(fd, journalMemfdName)
+	defer file.Close()
+
+	if _, err := file.Write(entry); err != nil {
+		return err
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, fAddSeals, fSealSeal|fSealShrink|fSealGrow|fSealWrite); errno != 0 {
+		return fmt.Errorf("sealing the memfd: %w", errno)
+	}
+	// sendmsg directly, as net refuses WriteMsgUnix on a connected datagram socket
+	raw, err := j.conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sendErr error
+	err = raw.Write(func(socket uintptr) bool {
+		sendErr = syscall.Sendmsg(int(socket), nil, syscall.UnixRights(int(fd)), nil, 0)
+		return !errors.Is(sendErr, syscall.EAGAIN)
+	})
+	return errors.Join(err, sendErr)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package logger
+
+import "errors"
+
+// send fails, systemd-journald only runs on linux
+func (j *journal) send(entry []byte) error {
+	return errors.New("the journald output is only available on linux")
+}
@@ -0,0 +1,181 @@
+//go:build linux
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// journalListener is a local journal socket returning the fields of the entries received
+type journalListener struct {
+	conn *net.UnixConn
+	path string
+}
+
+func newJournalListener(t *testing.T) *journalListener {
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return &journalListener{conn: conn, path: path}
+}
+
+// receive reads the next entry, from the datagram or the memfd passed with it
+func (l *journalListener) receive(t *testing.T) (map[string]string, bool) {
+	buf := make([]byte, 1<<20)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_ = l.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, oobn, _, _, err := l.conn.ReadMsgUnix(buf, oob)
+	assert.NoError(t, err)
+	entry, viaMemfd := buf[:n], false
+	if oobn > 0 {
+		messages, err := syscall.ParseSocketControlMessage(oob[:oobn])
+		assert.NoError(t, err)
+		fds, err := syscall.ParseUnixRights(&messages[0])
+		assert.NoError(t, err)
+		file := os.NewFile(uintptr(fds[0]), "memfd")
+		defer file.Close()
+		_, _ = file.Seek(0, io.SeekStart)
+		entry, err = io.ReadAll(file)
+		assert.NoError(t, err)
+		viaMemfd = true
+	}
+	return parseJournalEntry(t, entry), viaMemfd
+}
+
+func parseJournalEntry(t *testing.T, entry []byte) map[string]string {
+	fields := map[string]string{}
+	for len(entry) > 0 {
+		line, rest, _ := bytes.Cut(entry, []byte("\n"))
+		if name, value, ok := bytes.Cut(line, []byte("=")); ok {
+			fields[string(name)] = string(value)
+			entry = rest
+			continue
+		}
+		size := binary.LittleEndian.Uint64(rest[:8])
+		fields[string(line)] = string(rest[8 : 8+size])
+		assert.Equal(t, byte('\n'), rest[8+size])
+		entry = rest[9+size:]
+	}
+	return fields
+}
+
+func contextWithRequiredFields() context.Context {
+	ctx := context.WithValue(context.Background(), TYPE, BusinessType)
+	ctx = context.WithValue(ctx, APPLICATION, "testApp")
+	return context.WithValue(ctx, OPERATION, "testOperation")
+}
+
+func newJournaldTestLogger(path string, config *JournaldConfig) *slog.Logger {
+	config.Enabled = true
+	config.SocketPath = path
+	return slog.New(NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled:  true,
+			File:     &FileOutputConfig{},
+			Cli:      &CliConfig{},
+			Syslog:   &SyslogConfig{},
+			Journald: config,
+		},
+		MangoConfig: &MangoConfig{CorrelationId: &CorrelationIdConfig{AutoGenerate: true}},
+	}))
+}
+
+func TestJournald_Fields(t *testing.T) {
+	listener := newJournalListener(t)
+	logger := newJournaldTestLogger(listener.path, &JournaldConfig{})
+
+	ctx := contextWithRequiredFields()
+	logger.WarnContext(ctx, "stock low", "items", 3, "sku", "a\nb", "message", "clash", slog.Group("req", "method", "GET"),
+		"schemaVersion", "attr", "hostname", "attr", "truncated", "attr")
+
+	fields, viaMemfd := listener.receive(t)
+	assert.False(t, viaMemfd)
+	assert.Equal(t, "stock low", fields["MESSAGE"])
+	assert.Equal(t, "4", fields["PRIORITY"])
+	assert.Equal(t, "testApp", fields["SYSLOG_IDENTIFIER"])
+	assert.Equal(t, "testApp", fields["APPLICATION"])
+	assert.Equal(t, "testOperation", fields["OPERATION"])
+	assert.Equal(t, BusinessType, fields["TYPE"])
+	assert.Equal(t, "WARN", fields["LEVEL"])
+	assert.NotEmpty(t, fields["LOGID"])
+	assert.Equal(t, "3", fields["ITEMS"])
+	assert.Equal(t, "a\nb", fields["SKU"])
+	assert.Equal(t, "clash", fields["ATTR_MESSAGE"])
+	assert.Equal(t, SchemaVersion, fields["SCHEMAVERSION"])
+	for _, name := range []string{"ATTR_SCHEMAVERSION", "ATTR_HOSTNAME", "ATTR_TRUNCATED"} {
+		assert.Equal(t, "attr", fields[name], name)
+	}
+	assert.Equal(t, "GET", fields["REQ_METHOD"])
+	assert.True(t, strings.HasSuffix(fields["CODE_FILE"], "journald_test.go"), fields["CODE_FILE"])
+	assert.Equal(t, "github.com/bitstep-ie/mango-go/pkg/logger.TestJournald_Fields", fields["CODE_FUNC"])
+	assert.NotEmpty(t, fields["CODE_LINE"])
+}
+
+func TestJournald_DebugAndIdentifier(t *testing.T) {
+	listener := newJournalListener(t)
+	logger := newJournaldTestLogger(listener.path, &JournaldConfig{Identifier: "checkout"})
+
+	logger.DebugContext(contextWithRequiredFields(), "not sent")
+	logger.ErrorContext(contextWithRequiredFields(), "sent")
+
+	fields, _ := listener.receive(t)
+	assert.Equal(t, "sent", fields["MESSAGE"])
+	assert.Equal(t, "3", fields["PRIORITY"])
+	assert.Equal(t, "checkout", fields["SYSLOG_IDENTIFIER"])
+}
+
+func TestJournald_LargeEntryViaMemfd(t *testing.T) {
+	listener := newJournalListener(t)
+	logger := newJournaldTestLogger(listener.path, &JournaldConfig{})
+
+	large := strings.Repeat("x", 4<<20)
+	logger.InfoContext(contextWithRequiredFields(), "large", "payload", large)
+
+	fields, viaMemfd := listener.receive(t)
+	assert.True(t, viaMemfd)
+	assert.Equal(t, "large", fields["MESSAGE"])
+	assert.Equal(t, large, fields["PAYLOAD"])
+}
+
+func TestJournald_SocketMissing(t *testing.T) {
+	handler := NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled:  true,
+			File:     &FileOutputConfig{},
+			Cli:      &CliConfig{},
+			Syslog:   &SyslogConfig{},
+			Journald: &JournaldConfig{Enabled: true, SocketPath: filepath.Join(t.TempDir(), "missing.sock")},
+		},
+		MangoConfig: &MangoConfig{CorrelationId: &CorrelationIdConfig{AutoGenerate: true}},
+	})
+	err := handler.Handle(contextWithRequiredFields(), slog.NewRecord(time.Now(), slog.LevelInfo, "lost", 0))
+	assert.ErrorContains(t, err, "failed to connect to the journal")
+	assert.Equal(t, uint64(1), handler.Metrics().Snapshot().WriteErrors[OutputJournald])
+}
+
+func TestJournalFieldName(t *testing.T) {
+	assert.Equal(t, "HTTP_STATUS", journalFieldName("http.status"))
+	assert.Equal(t, "SECRET", journalFieldName("_secret"))
+	assert.Equal(t, "F2FA", journalFieldName("2fa"))
+	assert.Len(t, journalFieldName(strings.Repeat("a", 100)), 64)
+}
+
+func TestJournalFields(t *testing.T) {
+	for _, name := range []string{"MESSAGE", "CODE_FUNC", "TS", "SCHEMAVERSION", "RESOURCE", "TRUNCATED", "ORIGINALSIZES", "SERVICEVERSION", "HOSTNAME", "ENVIRONMENT", "AUDITMAC"} {
+		assert.Contains(t, journalFields, name)
+	}
+}
@@ -0,0 +1,130 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"unicode/utf8"
+)
+
+// limits is nil safe, as the limits node is optional in the configuration
+func (sl MangoLogger) limits() *LimitsConfig {
+	if sl.Config == nil || sl.Config.MangoConfig == nil {
+		return nil
+	}
+	return sl.Config.MangoConfig.Limits
+}
+
+// truncated flags the entry and returns its original sizes, to fill in
+func truncated(log *StructuredLog) *OriginalSizes {
+	log.Truncated = true
+	if log.OriginalSizes == nil {
+		log.OriginalSizes = &OriginalSizes{}
+	}
+	return log.OriginalSizes
+}
+
+// cut s to at most max bytes, without splitting a character
+func cut(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// limitMessage cuts the message of the entry
+func (c *LimitsConfig) limitMessage(log *StructuredLog, message string) string {
+	if c == nil || c.MaxMessageLength <= 0 || len(message) <= c.MaxMessageLength {
+		return message
+	}
+	truncated(log).Message = len(message)
+	return cut(message, c.MaxMessageLength)
+}
+
+// limitAttrs applies the count, depth and string limits to the merged attributes of the entry
+func (c *LimitsConfig) limitAttrs(log *StructuredLog, attrs []slog.Attr, durationFormat string) []slog.Attr {
+	if c == nil {
+		return attrs
+	}
+	if c.MaxAttributes > 0 && len(attrs) > c.MaxAttributes {
+		truncated(log).AttributeCount = len(attrs)
+		attrs = attrs[:c.MaxAttributes]
+	}
+	if c.MaxDepth <= 0 && c.MaxStringLength <= 0 {
+		return attrs
+	}
+	return c.limitGroup(log, attrs, "", 1, durationFormat)
+}
+
+func (c *LimitsConfig) limitGroup(log *StructuredLog, attrs []slog.Attr, prefix string, depth int, durationFormat string) []slog.Attr {
+	limited := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		path := prefix + attr.Key
+		value := attr.Value.Resolve()
+		if value.Kind() == slog.KindGroup {
+			if c.MaxDepth <= 0 || depth < c.MaxDepth {
+				limited = append(limited, slog.Attr{Key: attr.Key, Value: slog.GroupValue(c.limitGroup(log, value.Group(), path+".", depth+1, durationFormat)...)})
+				continue
+			}
+			// too deep, kept as its json
+			content, err := json.Marshal(toMap(value.Group(), durationFormat))
+			if err != nil {
+				content = []byte(fmt.Sprint(toMap(value.Group(), durationFormat)))
+			}
+			value = slog.StringValue(string(content))
+		}
+		if value.Kind() == slog.KindString && c.MaxStringLength > 0 && len(value.String()) > c.MaxStringLength {
+			sizes := truncated(log)
+			if sizes.Attributes == nil {
+				sizes.Attributes = map[string]int{}
+			}
+			sizes.Attributes[path] = len(value.String())
+			value = slog.StringValue(cut(value.String(), c.MaxStringLength))
+		}
+		limited = append(limited, slog.Attr{Key: attr.Key, Value: value})
+	}
+	return limited
+}
+
+// limitEntry drops the last attributes, then cuts the message, until the json entry fits in MaxEntryBytes
+func (c *LimitsConfig) limitEntry(log *StructuredLog) error {
+	if c == nil || c.MaxEntryBytes <= 0 {
+		return nil
+	}
+	content, err := json.Marshal(log)
+	if err != nil || len(content) <= c.MaxEntryBytes {
+		return err
+	}
+	// the marker is added first, so it counts in the size
+	truncated(log).Entry = len(content)
+	count := len(log.order)
+
+	for size := len(content); size > c.MaxEntryBytes; {
+		if len(log.order) > 0 {
+			last := log.order[len(log.order)-1]
+			log.order = log.order[:len(log.order)-1]
+			delete(log.Attributes, last.key)
+			if log.OriginalSizes.AttributeCount == 0 {
+				log.OriginalSizes.AttributeCount = count
+			}
+		} else {
+			message := fmt.Sprint(log.Message)
+			if message == "" {
+				break // the contract fields alone don't fit
+			}
+			if log.OriginalSizes.Message == 0 {
+				log.OriginalSizes.Message = len(message)
+			}
+			log.Message = cut(message, max(len(message)-(size-c.MaxEntryBytes), 0))
+		}
+		content, err = json.Marshal(log)
+		if err != nil {
+			return err
+		}
+		size = len(content)
+	}
+	return nil
+}
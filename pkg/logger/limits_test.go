@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newLimitsTestLogger(sink EntrySink, limits *LimitsConfig) *slog.Logger {
+	handler := newSinkTestLogger(sink)
+	handler.Config.MangoConfig.Limits = limits
+	return slog.New(handler)
+}
+
+func TestCut(t *testing.T) {
+	assert.Equal(t, "short", cut("short", 10))
+	assert.Equal(t, "abc", cut("abcdef", 3))
+	assert.Equal(t, "caf", cut("café", 4), "é is not split")
+	assert.Equal(t, "café", cut("café", 5))
+	assert.Equal(t, "", cut("€", 2))
+}
+
+func TestLimits_MessageAndStrings(t *testing.T) {
+	sink := &sliceSink{}
+	logger := newLimitsTestLogger(sink, &LimitsConfig{MaxMessageLength: 5, MaxStringLength: 4})
+	logger.With("token", "abcdefgh").Info("a long message", slog.Group("req", "body", "0123456789", "method", "GET"), "count", 12345678)
+	logger.Info("short")
+
+	entry := sink.entries[0]
+	assert.True(t, entry.Truncated)
+	assert.Equal(t, "a lon", entry.Message)
+	assert.Equal(t, "abcd", entry.Attributes["token"])
+	assert.Equal(t, map[string]interface{}{"body": "0123", "method": "GET"}, entry.Attributes["req"])
+	assert.Equal(t, int64(12345678), entry.Attributes["count"], "only strings are cut")
+	assert.Equal(t, &OriginalSizes{Message: 14, Attributes: map[string]int{"token": 8, "req.body": 10}}, entry.OriginalSizes)
+
+	assert.False(t, sink.entries[1].Truncated)
+	assert.Nil(t, sink.entries[1].OriginalSizes)
+}
+
+func TestLimits_CountAndDepth(t *testing.T) {
+	sink := &sliceSink{}
+	logger := newLimitsTestLogger(sink, &LimitsConfig{MaxAttributes: 2, MaxDepth: 2})
+	logger.With("app", "cart").Info("nested", slog.Group("a", slog.Group("b", slog.Group("c", "d", 1))), "dropped", true)
+
+	entry := sink.entries[0]
+	assert.True(t, entry.Truncated)
+	assert.Equal(t, 3, entry.OriginalSizes.AttributeCount)
+	assert.Equal(t, map[string]interface{}{"b": `{"c":{"d":1}}`}, entry.Attributes["a"])
+	assert.NotContains(t, entry.Attributes, "dropped")
+
+	content, err := json.Marshal(entry)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"attributes":{"app":"cart","a":{"b":"{\"c\":{\"d\":1}}"}},"truncated":true,"originalSizes":{"attributeCount":3}`)
+}
+
+func TestLimits_EntryBytes(t *testing.T) {
+	sink := &sliceSink{}
+	logger := newLimitsTestLogger(sink, &LimitsConfig{MaxEntryBytes: 400})
+	logger.Info("fits")
+	logger.Info("too big", "small", 1, "payload", strings.Repeat("x", 1000))
+	logger.Info(strings.Repeat("m", 1000), "a", 1)
+
+	assert.False(t, sink.entries[0].Truncated)
+
+	entry := sink.entries[1]
+	content, _ := json.Marshal(entry)
+	assert.LessOrEqual(t, len(content), 400)
+	assert.True(t, entry.Truncated)
+	assert.Equal(t, map[string]interface{}{"small": int64(1)}, entry.Attributes, "the last attributes are dropped first")
+	assert.Equal(t, 2, entry.OriginalSizes.AttributeCount)
+	assert.Greater(t, entry.OriginalSizes.Entry, 1000)
+
+	entry = sink.entries[2]
+	content, _ = json.Marshal(entry)
+	assert.LessOrEqual(t, len(content), 400)
+	assert.Empty(t, entry.Attributes)
+	assert.Equal(t, 1000, entry.OriginalSizes.Message)
+	assert.True(t, strings.HasPrefix(entry.Message.(string), "mmm"))
+}
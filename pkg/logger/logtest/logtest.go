@@ -0,0 +1,245 @@
+// Package logtest records the entries of a mango logger in memory, to assert on them in tests
+//
+//	rec := logtest.NewRecorder()
+//	logger := logtest.NewLogger(rec)
+//	service := NewService(logger)
+//	service.Login(ctx, "alice")
+//	logtest.AssertLogged(t, rec, slog.LevelInfo, mangolog.SecurityType, "login succeeded")
+package logtest
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	mangolog "github.com/bitstep-ie/mango-go/pkg/logger"
+)
+
+// TestingT is the part of *testing.T the assertions use, also met by testify's assert.TestingT
+type TestingT interface {
+	Errorf(format string, args ...any)
+}
+
+// Recorder is an EntrySink keeping the entries in memory, safe for concurrent use
+type Recorder struct {
+	mu      sync.Mutex
+	entries []mangolog.StructuredLog
+
+	// changed is closed and replaced on every new entry and Reset, waking up the waiting WaitFor calls
+	changed chan struct{}
+
+	// generation is bumped by Reset, for WaitFor to start over
+	generation uint64
+}
+
+// NewRecorder creates an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{changed: make(chan struct{})}
+}
+
+// WriteEntry records the entry, implementing mangolog.EntrySink
+func (r *Recorder) WriteEntry(log mangolog.StructuredLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, log)
+	r.notify()
+	return nil
+}
+
+// notify wakes up the waiting WaitFor calls, with r.mu held
+func (r *Recorder) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// Entries returns a copy of the entries recorded so far, oldest first
+func (r *Recorder) Entries() []mangolog.StructuredLog {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]mangolog.StructuredLog(nil), r.entries...)
+}
+
+// Reset forgets the entries recorded so far
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = nil
+	r.generation++
+	r.notify()
+}
+
+// Filter returns the entries matching match
+func (r *Recorder) Filter(match Matcher) []mangolog.StructuredLog {
+	var matching []mangolog.StructuredLog
+	for _, entry := range r.Entries() {
+		if match(entry) {
+			matching = append(matching, entry)
+		}
+	}
+	return matching
+}
+
+// ByCorrelationId returns the entries with the correlation id
+func (r *Recorder) ByCorrelationId(correlationId string) []mangolog.StructuredLog {
+	return r.Filter(WithCorrelationId(correlationId))
+}
+
+// WaitFor returns the first entry matching match, waiting up to timeout for it to be logged
+// It returns false when no entry matched in time
+func (r *Recorder) WaitFor(timeout time.Duration, match Matcher) (mangolog.StructuredLog, bool) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	seen, generation := 0, uint64(0)
+	for {
+		r.mu.Lock()
+		if r.generation != generation || seen > len(r.entries) {
+			seen, generation = 0, r.generation // reset meanwhile
+		}
+		entries := r.entries[seen:]
+		changed := r.changed
+		seen = len(r.entries)
+		r.mu.Unlock()
+
+		for _, entry := range entries {
+			if match(entry) {
+				return entry, true
+			}
+		}
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return mangolog.StructuredLog{}, false
+		}
+	}
+}
+
+// NewLogger returns a logger recording all the entries, of every level, in rec
+// Strict mode is off and the correlation id is generated when missing from the context
+func NewLogger(rec *Recorder) *slog.Logger {
+	return slog.New(NewHandler(rec, nil))
+}
+
+// NewHandler returns a MangoLogger recording all the entries in rec, besides any output of config
+// A nil config, or one without Out, records only, with strict mode off and the correlation id generated when missing
+func NewHandler(rec *Recorder, config *mangolog.LogConfig) *mangolog.MangoLogger {
+	if config == nil || config.Out == nil {
+		defaults := &mangolog.LogConfig{
+			MangoConfig: &mangolog.MangoConfig{
+				CorrelationId: &mangolog.CorrelationIdConfig{AutoGenerate: true},
+			},
+			Out: &mangolog.OutConfig{
+				File:   &mangolog.FileOutputConfig{},
+				Cli:    &mangolog.CliConfig{},
+				Syslog: &mangolog.SyslogConfig{},
+			},
+		}
+		if config != nil && config.MangoConfig != nil {
+			defaults.MangoConfig = config.MangoConfig
+		}
+		config = defaults
+	}
+	out := *config.Out
+	out.Enabled = true
+	out.Sink = rec
+	withSink := *config
+	withSink.Out = &out
+	return mangolog.NewMangoLogger(&withSink)
+}
+
+// Matcher selects entries
+type Matcher func(entry mangolog.StructuredLog) bool
+
+// Match selects the entries of level and Type whose message contains messageSubstring
+// An empty logType or messageSubstring matches any
+func Match(level slog.Level, logType string, messageSubstring string) Matcher {
+	return func(entry mangolog.StructuredLog) bool {
+		return entry.Level == level &&
+			(logType == "" || entry.Type == logType) &&
+			strings.Contains(fmt.Sprint(entry.Message), messageSubstring)
+	}
+}
+
+// WithCorrelationId selects the entries with the correlation id
+func WithCorrelationId(correlationId string) Matcher {
+	return func(entry mangolog.StructuredLog) bool {
+		return entry.Correlationid == correlationId
+	}
+}
+
+// All selects the entries matching all the matchers
+func All(matchers ...Matcher) Matcher {
+	return func(entry mangolog.StructuredLog) bool {
+		for _, match := range matchers {
+			if !match(entry) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AssertLogged asserts an entry of level and Type with messageSubstring in its message was recorded
+func AssertLogged(t TestingT, rec *Recorder, level slog.Level, logType string, messageSubstring string, msgAndArgs ...interface{}) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	if len(rec.Filter(Match(level, logType, messageSubstring))) > 0 {
+		return true
+	}
+	return fail(t, fmt.Sprintf("No %s %s entry containing %q was logged, got:\n%s", level, logType, messageSubstring, describe(rec.Entries())), msgAndArgs...)
+}
+
+// AssertNotLogged asserts no entry of level and Type with messageSubstring in its message was recorded
+func AssertNotLogged(t TestingT, rec *Recorder, level slog.Level, logType string, messageSubstring string, msgAndArgs ...interface{}) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	matching := rec.Filter(Match(level, logType, messageSubstring))
+	if len(matching) == 0 {
+		return true
+	}
+	return fail(t, fmt.Sprintf("Unexpected %s %s entry containing %q was logged:\n%s", level, logType, messageSubstring, describe(matching)), msgAndArgs...)
+}
+
+// WaitForEntry waits up to timeout for an entry of level and Type with messageSubstring in its message,
+// failing the test when none is logged in time
+func WaitForEntry(t TestingT, rec *Recorder, timeout time.Duration, level slog.Level, logType string, messageSubstring string, msgAndArgs ...interface{}) (mangolog.StructuredLog, bool) {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	entry, ok := rec.WaitFor(timeout, Match(level, logType, messageSubstring))
+	if !ok {
+		return entry, fail(t, fmt.Sprintf("No %s %s entry containing %q was logged within %s, got:\n%s", level, logType, messageSubstring, timeout, describe(rec.Entries())), msgAndArgs...)
+	}
+	return entry, true
+}
+
+// fail reports the failure with the optional message of the caller, returning false
+func fail(t TestingT, failure string, msgAndArgs ...interface{}) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	if len(msgAndArgs) > 0 {
+		if format, ok := msgAndArgs[0].(string); ok && len(msgAndArgs) > 1 {
+			failure += "\nMessages: " + fmt.Sprintf(format, msgAndArgs[1:]...)
+		} else {
+			failure += "\nMessages: " + fmt.Sprint(msgAndArgs...)
+		}
+	}
+	t.Errorf("%s", failure)
+	return false
+}
+
+// describe lists the entries for the failure messages
+func describe(entries []mangolog.StructuredLog) string {
+	if len(entries) == 0 {
+		return "\t(no entries)"
+	}
+	var b strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "\t[%s] %s %s %s - %v\n", entry.Level, entry.Type, entry.Operation, entry.Correlationid, entry.Message)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
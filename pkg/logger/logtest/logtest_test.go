@@ -0,0 +1,164 @@
+package logtest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	mangolog "github.com/bitstep-ie/mango-go/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeT records the failures instead of failing the test
+type fakeT struct {
+	failures []string
+}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.failures = append(f.failures, fmt.Sprintf(format, args...))
+}
+
+func typed(logType string, correlationId string) context.Context {
+	ctx := context.WithValue(context.Background(), mangolog.TYPE, logType)
+	return context.WithValue(ctx, mangolog.CORRELATION_ID, correlationId)
+}
+
+func TestRecorder_AssertLogged(t *testing.T) {
+	rec := NewRecorder()
+	logger := NewLogger(rec)
+
+	logger.InfoContext(typed(mangolog.SecurityType, "c1"), "login succeeded", slog.String("user", "alice"))
+	logger.DebugContext(typed(mangolog.BusinessType, "c2"), "cart loaded")
+
+	AssertLogged(t, rec, slog.LevelInfo, mangolog.SecurityType, "login")
+	AssertLogged(t, rec, slog.LevelDebug, "", "cart")
+	AssertNotLogged(t, rec, slog.LevelError, "", "")
+
+	entries := rec.Entries()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "alice", entries[0].Attributes["user"])
+	assert.Equal(t, "c1", entries[0].Correlationid)
+
+	fake := &fakeT{}
+	assert.False(t, AssertLogged(fake, rec, slog.LevelInfo, mangolog.BusinessType, "login"))
+	assert.False(t, AssertNotLogged(fake, rec, slog.LevelInfo, mangolog.SecurityType, "login"))
+	assert.Len(t, fake.failures, 2)
+	assert.Contains(t, fake.failures[0], "[INFO] Security unknownOperation c1 - login succeeded")
+
+	rec.Reset()
+	assert.Empty(t, rec.Entries())
+}
+
+func TestRecorder_ByCorrelationId(t *testing.T) {
+	rec := NewRecorder()
+	logger := NewLogger(rec)
+	logger.InfoContext(typed(mangolog.BusinessType, "c1"), "one")
+	logger.InfoContext(typed(mangolog.BusinessType, "c2"), "two")
+	logger.WarnContext(typed(mangolog.SecurityType, "c1"), "three")
+
+	entries := rec.ByCorrelationId("c1")
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "three", entries[1].Message)
+
+	assert.Len(t, rec.Filter(All(WithCorrelationId("c1"), Match(slog.LevelWarn, "", ""))), 1)
+}
+
+func TestRecorder_WaitForEntry(t *testing.T) {
+	rec := NewRecorder()
+	logger := NewLogger(rec)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		logger.InfoContext(typed(mangolog.BusinessType, "c1"), "not this one")
+		logger.ErrorContext(typed(mangolog.BusinessType, "c1"), "payment failed")
+	}()
+
+	entry, ok := WaitForEntry(t, rec, 2*time.Second, slog.LevelError, mangolog.BusinessType, "payment")
+	assert.True(t, ok)
+	assert.Equal(t, "payment failed", entry.Message)
+
+	fake := &fakeT{}
+	_, ok = WaitForEntry(fake, rec, 10*time.Millisecond, slog.LevelError, mangolog.SecurityType, "payment")
+	assert.False(t, ok)
+	assert.Contains(t, fake.failures[0], "within 10ms")
+}
+
+func TestRecorder_Concurrent(t *testing.T) {
+	rec := NewRecorder()
+	logger := NewLogger(rec)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				logger.InfoContext(typed(mangolog.BusinessType, fmt.Sprintf("c%d", i)), "entry")
+				_ = rec.Entries()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, rec.Entries(), 200)
+	assert.Len(t, rec.ByCorrelationId("c7"), 10)
+}
+
+func TestNewHandler_WithConfig(t *testing.T) {
+	rec := NewRecorder()
+	handler := NewHandler(rec, &mangolog.LogConfig{
+		MangoConfig: &mangolog.MangoConfig{
+			Strict:        true,
+			CorrelationId: &mangolog.CorrelationIdConfig{AutoGenerate: true},
+			Failure:       &mangolog.FailureConfig{Strict: mangolog.FailurePolicyEmit},
+		},
+		Out: &mangolog.OutConfig{
+			File:   &mangolog.FileOutputConfig{},
+			Cli:    &mangolog.CliConfig{},
+			Syslog: &mangolog.SyslogConfig{},
+		},
+	})
+	slog.New(handler).InfoContext(typed(mangolog.BusinessType, "c1"), "missing fields")
+
+	entries := rec.Entries()
+	assert.Len(t, entries, 1)
+	assert.Contains(t, entries[0].Violations, "operation: missing")
+}
+
+func TestRecorder_ResetDuringWaitFor(t *testing.T) {
+	rec := NewRecorder()
+	logger := NewLogger(rec)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			logger.InfoContext(typed(mangolog.BusinessType, "c1"), "noise")
+			if i%5 == 4 {
+				rec.Reset()
+			}
+		}
+		logger.ErrorContext(typed(mangolog.BusinessType, "c1"), "found")
+	}()
+
+	entry, ok := rec.WaitFor(2*time.Second, Match(slog.LevelError, "", "found"))
+	assert.True(t, ok)
+	assert.Equal(t, "found", entry.Message)
+	<-done
+}
+
+func TestNewHandler_WithoutOut(t *testing.T) {
+	rec := NewRecorder()
+	handler := NewHandler(rec, &mangolog.LogConfig{
+		MangoConfig: &mangolog.MangoConfig{CorrelationId: &mangolog.CorrelationIdConfig{AutoGenerate: true}},
+	})
+	slog.New(handler).InfoContext(typed(mangolog.BusinessType, "c1"), "recorded")
+	assert.Len(t, rec.Entries(), 1)
+
+	fake := &fakeT{}
+	AssertLogged(fake, rec, slog.LevelError, "", "", "looking for %s", "errors")
+	assert.Contains(t, fake.failures[0], "Messages: looking for errors")
+}
@@ -3,20 +3,37 @@ package logger
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/google/uuid"
 	"github.com/itchyny/gojq"
 	"github.com/natefinch/lumberjack"
 	"log/slog"
+	"maps"
 	"os"
 	"slices"
+	"time"
 	"unsafe"
 )
 
 type MangoLogger struct {
 	attrs     []slog.Attr
+	groups    []string
 	Config    *LogConfig
 	LogWriter *lumberjack.Logger
+
+	audit       *auditChain
+	file        *rotatingFile
+	routes      []*fileRoute
+	httpShipper *httpShipper
+	otlpShipper *httpShipper
+	journal     *journal
+	service     *service
+	lifecycle   *lifecycle
+	metrics     *Metrics
+	failures    *failureHandler
+	ids         *ids
+	templates   *cliTemplates
+	encryptor   *encryptor
 }
 
 var errStrictModeOn = fmt.Errorf("[STRICT_MODE ON] without required context fields %v", REQUIRED_FIELDS)
@@ -31,7 +48,31 @@ func NewMangoLogger(config *LogConfig) *MangoLogger {
 			MaxBackups: config.Out.File.MaxBackups,
 			MaxAge:     config.Out.File.MaxAge,
 			Compress:   config.Out.File.Compress,
+			LocalTime:  config.Out.File.LocalTime,
 		},
+		metrics:   newMetrics(),
+		failures:  newFailureHandler(config.MangoConfig),
+		service:   newService(config.MangoConfig),
+		lifecycle: &lifecycle{},
+	}
+	logger.ids = newIds(config.MangoConfig, logger.failures.diagnostics)
+	logger.templates = newCliTemplates(logger.Config.Out.Cli)
+	if config.MangoConfig != nil {
+		logger.encryptor = newEncryptor(config.MangoConfig.Encryption, logger.failures.diagnostics)
+	}
+	if config.MangoConfig != nil && config.MangoConfig.Audit.isEnabled() {
+		logger.audit = newAuditChain(config.MangoConfig.Audit, logger.failures.diagnostics)
+	}
+	logger.file = newRotatingFile(logger.LogWriter, config.Out.File, logger.failures.diagnostics)
+	logger.routes = newFileRoutes(config.Out.File, logger.failures.diagnostics)
+	if config.Out.Http.isEnabled() {
+		logger.httpShipper = newHttpShipper(config.Out.Http, jsonBatchEncoder{format: config.Out.Http.Format}, OutputHttp, logger.metrics, logger.failures.diagnostics)
+	}
+	if config.Out.Otlp.isEnabled() {
+		logger.otlpShipper = newOtlpShipper(config.Out.Otlp, logger.metrics, logger.failures.diagnostics)
+	}
+	if config.Out.Journald.isEnabled() {
+		logger.journal = newJournal(config.Out.Journald)
 	}
 	return logger
 }
@@ -39,11 +80,15 @@ func NewMangoLogger(config *LogConfig) *MangoLogger {
 // applyDefaultFormats to the configuration to ensure verbose and cli-friendly default formats are applied
 func applyDefaultFormats(config LogConfig) *LogConfig {
 	merged := config
+	verbose, friendly := DefaultVerboseFormat, DefaultFriendlyFormat
+	if config.Out.Cli.FormatMode == CliFormatModeTemplate {
+		verbose, friendly = DefaultVerboseTemplate, DefaultFriendlyTemplate
+	}
 	if config.Out.Cli.VerboseFormat == "" {
-		merged.Out.Cli.VerboseFormat = DefaultVerboseFormat
+		merged.Out.Cli.VerboseFormat = verbose
 	}
 	if config.Out.Cli.FriendlyFormat == "" {
-		merged.Out.Cli.FriendlyFormat = DefaultFriendlyFormat
+		merged.Out.Cli.FriendlyFormat = friendly
 	}
 	return &merged
 }
@@ -64,65 +109,142 @@ func (sl MangoLogger) Enabled(context context.Context, level slog.Level) bool {
 }
 
 func (sl MangoLogger) Handle(context context.Context, record slog.Record) error {
+	if !sl.lifecycle.begin() {
+		return ErrLoggerClosed
+	}
+	defer sl.lifecycle.end()
+
 	if !sl.Config.Out.Enabled { // no logging enabled
-		fmt.Println("No logging enabled! Check config.out.enabled.")
+		sl.diagnostic("No logging enabled! Check config.out.enabled.\n")
 		return nil
 	}
 
-	if !sl.Config.Out.File.Enabled && !sl.Config.Out.Cli.Enabled && sl.Config.Out.Syslog.Facility == "" {
-		fmt.Println("Effectively no logging enabled! The config.out.file.enabled, config.out.cli.enabled and config.out.syslog.facility flags are all false.")
+	if !sl.Config.Out.File.Enabled && !sl.Config.Out.Cli.Enabled && sl.Config.Out.Syslog.Facility == "" && !sl.Config.Out.Http.isEnabled() && !sl.Config.Out.Otlp.isEnabled() && !sl.Config.Out.Journald.isEnabled() && sl.Config.Out.Sink == nil {
+		sl.diagnostic("Effectively no logging enabled! The config.out.file.enabled, config.out.cli.enabled, config.out.http.enabled, config.out.otlp.enabled and config.out.syslog.facility flags are all false and there is no config.out.sink.\n")
 		return nil
 	}
 
 	log, err := sl.buildLog(context, record)
 	if err != nil {
-		return err
+		if errors.Is(err, errStrictModeOn) {
+			sl.metrics.countStrictRejection()
+		}
+		emit, err := sl.failures.onStrictFailure(log, err)
+		if !emit {
+			return err
+		}
 	}
-
-	jsonOut, err := json.Marshal(log)
-	if err != nil {
-		fmt.Println("Failed to marshal the StructuredLog. Internal error, should never happen")
+	sl.metrics.countRecord(log)
+	if err := sl.limits().limitEntry(log); err != nil {
+		sl.diagnostic("Failed to measure the entry against config.mango.limits.max-entry-bytes. %s\n", err.Error())
 		return err
 	}
 
-	if sl.Config.Out.Cli.Enabled {
-		err := sl.handlePromptOutput(log, string(jsonOut))
+	var jsonOut []byte
+	if sl.audited(log) {
+		jsonOut, err = sl.audit.seal(log)
 		if err != nil {
+			sl.diagnostic("Failed to seal the Security entry. %s\n", err.Error())
+			sl.metrics.countWriteError(OutputAudit)
 			return err
 		}
-	}
-
-	if sl.Config.Out.File.Enabled {
-		err := sl.handleFileOutput(log, string(jsonOut))
+		// written in turn, so the audit sequence follows the order of the entries in the files
+		err = sl.audit.inTurn(log.AuditSeq, func() error { return sl.writeOutputs(log, jsonOut) })
+	} else {
+		jsonOut, err = json.Marshal(log)
 		if err != nil {
+			sl.diagnostic("Failed to marshal the StructuredLog. Internal error, should never happen. %s\n", err.Error())
 			return err
 		}
+		err = sl.writeOutputs(log, jsonOut)
 	}
 
-	if sl.Config.Out.Syslog.Facility != "" {
-		err := sl.handleSyslogOutput(log, jsonOut)
-		if err != nil {
-			return err
-		}
+	// outside of the audit turn, so an ErrorHandler logging a Security entry doesn't wait on itself
+	if err != nil {
+		return sl.failures.onOutputFailure(log, jsonOut, err)
 	}
-
 	return nil
 }
 
+// audited is true for the Security entries sealed in the audit chain
+// A DEBUG entry is left out when the file output doesn't write it, it would show as a gap in the audited file
+func (sl MangoLogger) audited(log *StructuredLog) bool {
+	if sl.audit == nil || log.Type != SecurityType {
+		return false
+	}
+	return log.Level != slog.LevelDebug || !sl.Config.Out.File.Enabled || sl.writesDebugToFile(log)
+}
+
+// writeOutputs writes the entry to each enabled output
+// With the return failure policy it stops at the first failing output, otherwise it writes to all and joins the errors
+func (sl MangoLogger) writeOutputs(log *StructuredLog, jsonOut []byte) error {
+	outputs := []struct {
+		name    string
+		enabled bool
+		handle  func() error
+	}{
+		{OutputCli, sl.Config.Out.Cli.Enabled, func() error { return sl.handlePromptOutput(log, string(jsonOut)) }},
+		{OutputFile, sl.Config.Out.File.Enabled, func() error { return sl.handleFileOutput(log, string(jsonOut)) }},
+		{OutputSyslog, sl.Config.Out.Syslog.Facility != "", func() error { return sl.handleSyslogOutput(log, jsonOut) }},
+		{OutputHttp, sl.Config.Out.Http.isEnabled(), func() error { return sl.handleHttpOutput(log, jsonOut) }},
+		{OutputOtlp, sl.Config.Out.Otlp.isEnabled(), func() error { return sl.handleOtlpOutput(log, jsonOut) }},
+		{OutputJournald, sl.Config.Out.Journald.isEnabled(), func() error { return sl.handleJournaldOutput(log) }},
+		{OutputSink, sl.Config.Out.Sink != nil, func() error { return sl.handleSinkOutput(log) }},
+	}
+
+	stop := sl.failures.stopOnOutputError()
+	var errs []error
+	for _, output := range outputs {
+		if !output.enabled {
+			continue
+		}
+		if err := sl.metrics.observeWrite(output.name, time.Now(), output.handle()); err != nil {
+			if stop {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithAttrs returns a handler adding attrs to every entry, inside the groups opened so far
 func (sl MangoLogger) WithAttrs(attrs []slog.Attr) slog.Handler {
-	sl.attrs = append(sl.attrs, attrs...)
+	if len(attrs) == 0 {
+		return sl
+	}
+	// a new slice, so handlers derived from the same parent don't share their attributes
+	sl.attrs = slices.Concat(sl.attrs, inGroups(sl.groups, attrs))
 	return sl
 }
 
+// WithGroup returns a handler nesting the attributes added from now on, the record ones included, under name
 func (sl MangoLogger) WithGroup(name string) slog.Handler {
-	panic("unimplemented") // add a way of storing a generic map of maps of attributes
+	if name == "" {
+		return sl
+	}
+	sl.groups = slices.Concat(sl.groups, []string{name})
+	return sl
+}
+
+// inGroups nests attrs under the groups, outermost first
+func inGroups(groups []string, attrs []slog.Attr) []slog.Attr {
+	for i := len(groups) - 1; i >= 0; i-- {
+		attrs = []slog.Attr{{Key: groups[i], Value: slog.GroupValue(attrs...)}}
+	}
+	return attrs
 }
 
 func (sl MangoLogger) writeStringToLogFile(s string) error {
 	if sl.Config.Out.Enabled {
 		s += "\n"
 		b := unsafe.Slice(unsafe.StringData(s), len(s))
-		_, err := sl.LogWriter.Write(b)
+		var err error
+		if sl.file != nil {
+			_, err = sl.file.Write(b)
+		} else {
+			_, err = sl.LogWriter.Write(b)
+		}
 		if err != nil {
 			return err
 		}
@@ -170,33 +292,52 @@ func formatWithGoJQ(obj string, query string) (string, error) {
 
 func (sl MangoLogger) handleFileOutput(log *StructuredLog, jsonOut string) error {
 	switch log.Level {
-	case slog.LevelDebug:
-		if sl.Config.Out.File.Debug {
-			return sl.writeStringToLogFile(jsonOut)
-		}
-	case slog.LevelInfo:
-		return sl.writeStringToLogFile(jsonOut)
-	case slog.LevelWarn:
-		fallthrough
-	case slog.LevelError:
-		return sl.writeStringToLogFile(jsonOut)
+	case slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError:
 	default:
-		fmt.Println("Record level not one of: debug, info, warn or error")
+		sl.diagnostic("Record level not one of: debug, info, warn or error\n")
 		return fmt.Errorf("record level not one of: debug, info, warn or error")
 	}
-	return nil
+
+	exclusive, err := sl.handleFileRoutes(log, jsonOut)
+	if exclusive || (log.Level == slog.LevelDebug && !sl.Config.Out.File.Debug) {
+		return err
+	}
+	return errors.Join(err, sl.writeStringToLogFile(jsonOut))
+}
+
+// handleSinkOutput hands a copy of the entry to the sink, so later changes by the caller don't affect the sink
+func (sl MangoLogger) handleSinkOutput(log *StructuredLog) error {
+	entry := *log
+	entry.Attributes = maps.Clone(log.Attributes)
+	entry.Violations = slices.Clone(log.Violations)
+	return sl.Config.Out.Sink.WriteEntry(entry)
+}
+
+// formatCli counts the jq or template failures, the output is printed regardless as before
+func (sl MangoLogger) formatCli(jsonOut string, query string) string {
+	var result string
+	var err error
+	if sl.Config.Out.Cli.FormatMode == CliFormatModeTemplate {
+		result, err = sl.templates.format(jsonOut, query)
+	} else {
+		result, err = formatWithGoJQ(jsonOut, query)
+	}
+	if err != nil {
+		sl.metrics.countFormatError(OutputCli)
+	}
+	return result
 }
 
 func (sl MangoLogger) handlePromptOutput(log *StructuredLog, jsonOut string) error {
 	switch log.Level {
 	case slog.LevelDebug:
 		if sl.Config.Out.Cli.Verbose {
-			result, _ := formatWithGoJQ(jsonOut, sl.Config.Out.Cli.VerboseFormat)
+			result := sl.formatCli(jsonOut, sl.Config.Out.Cli.VerboseFormat)
 			_, _ = fmt.Fprintln(os.Stdout, result)
 		}
 	case slog.LevelInfo:
 		if sl.Config.Out.Cli.Friendly {
-			result, _ := formatWithGoJQ(jsonOut, sl.Config.Out.Cli.FriendlyFormat)
+			result := sl.formatCli(jsonOut, sl.Config.Out.Cli.FriendlyFormat)
 			_, _ = fmt.Fprintln(os.Stdout, result)
 		} else {
 			_, _ = fmt.Fprintln(os.Stdout, jsonOut)
@@ -205,33 +346,56 @@ func (sl MangoLogger) handlePromptOutput(log *StructuredLog, jsonOut string) err
 		fallthrough
 	case slog.LevelError:
 		if sl.Config.Out.Cli.Friendly {
-			result, _ := formatWithGoJQ(jsonOut, sl.Config.Out.Cli.FriendlyFormat)
+			result := sl.formatCli(jsonOut, sl.Config.Out.Cli.FriendlyFormat)
 			_, _ = fmt.Fprintln(os.Stderr, result)
 		} else {
 			_, _ = fmt.Fprintln(os.Stderr, jsonOut)
 		}
 	default:
-		fmt.Println("Record level not one of: debug, info, warn or error")
+		sl.diagnostic("Record level not one of: debug, info, warn or error\n")
 		return fmt.Errorf("record level not one of: debug, info, warn or error")
 	}
 	return nil
 }
 
 // mergeAttrs with list2 taking precedence
+// LogValuer values are resolved, empty attributes and groups dropped, groups without a key inlined and groups with the
+// same key merged
 func mergeAttrs(list1, list2 []slog.Attr) []slog.Attr {
-	attrMap := make(map[string]slog.Attr)
-	for _, attr := range list1 {
-		attrMap[attr.Key] = attr
-	}
-	for _, attr := range list2 {
-		attrMap[attr.Key] = attr
-	}
-	mergedAttrs := make([]slog.Attr, 0, len(attrMap))
-	for _, attr := range attrMap {
-		mergedAttrs = append(mergedAttrs, attr)
-	}
+	merged := make([]slog.Attr, 0, len(list1)+len(list2))
+	index := make(map[string]int)
+	merged = appendAttrs(merged, index, list1)
+	return appendAttrs(merged, index, list2)
+}
 
-	return mergedAttrs
+func appendAttrs(merged []slog.Attr, index map[string]int, attrs []slog.Attr) []slog.Attr {
+	for _, attr := range attrs {
+		attr.Value = attr.Value.Resolve()
+		if attr.Equal(slog.Attr{}) {
+			continue
+		}
+		if attr.Value.Kind() == slog.KindGroup {
+			if attr.Key == "" {
+				merged = appendAttrs(merged, index, attr.Value.Group())
+				continue
+			}
+			group := mergeAttrs(nil, attr.Value.Group())
+			if len(group) == 0 {
+				continue
+			}
+			if i, ok := index[attr.Key]; ok && merged[i].Value.Kind() == slog.KindGroup {
+				group = mergeAttrs(merged[i].Value.Group(), group)
+			}
+			attr.Value = slog.GroupValue(group...)
+		}
+		if i, ok := index[attr.Key]; ok {
+			merged[i] = attr
+		} else {
+			index[attr.Key] = len(merged)
+			merged = append(merged, attr)
+		}
+	}
+	return merged
 }
 
 func getAllAttrs(record slog.Record) []slog.Attr {
@@ -246,16 +410,24 @@ func getAllAttrs(record slog.Record) []slog.Attr {
 }
 
 func (sl MangoLogger) handleRequiredFields(context context.Context, logOutput *StructuredLog) error {
+	// REQUIRED_FIELDS is only read, as it is shared by every logger and call
+	required := REQUIRED_FIELDS
 	if sl.Config.MangoConfig.CorrelationId.Strict {
-		REQUIRED_FIELDS = append(REQUIRED_FIELDS, CORRELATION_ID)
+		required = append(slices.Clip(required), CORRELATION_ID)
 	}
-	for _, label := range REQUIRED_FIELDS {
-		err := handleEachField(context, logOutput, label, sl)
-		if err != nil {
-			return err
+	// every field is checked, so the failure policies see all the violations
+	var errs []error
+	checked := map[ctxKey]bool{}
+	for _, label := range required {
+		if checked[label] {
+			continue
+		}
+		checked[label] = true
+		if err := handleEachField(context, logOutput, label, sl); err != nil {
+			errs = append(errs, err)
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 func handleEachField(context context.Context, logOutput *StructuredLog, label ctxKey, sl MangoLogger) error {
@@ -276,18 +448,28 @@ func handleEachField(context context.Context, logOutput *StructuredLog, label ct
 func handleValueMissing(label ctxKey, sl MangoLogger, logOutput *StructuredLog) error {
 	if CORRELATION_ID == label {
 		if sl.Config.MangoConfig.CorrelationId.AutoGenerate {
-			logOutput.Correlationid = uuid.New().String() // generate new UUID for correlation if missing from context
+			logOutput.Correlationid = sl.ids.newCorrelationId() // generate a correlation id if missing from context
 		} else {
-			return fmt.Errorf("%w - required in context and not present (or wrong type - expected string). This can be added by doing: context.WithValue(newCtx, mangologger.%s, \"desiredValue\")", errStrictModeOn, label)
+			return missingFieldError(label)
 		}
+	} else if label == APPLICATION && sl.service.names() {
+		return nil // the default application of the service configuration
 	} else {
 		if sl.Config.MangoConfig.Strict {
-			return fmt.Errorf("%w - required in context and not present (or wrong type - expected string). This can be added by doing: context.WithValue(newCtx, mangologger.%s, \"desiredValue\")", errStrictModeOn, label)
+			return missingFieldError(label)
 		}
 	}
 	return nil
 }
 
+func missingFieldError(label ctxKey) error {
+	return &strictViolation{
+		label:   label,
+		reason:  "missing",
+		message: fmt.Sprintf("%s - required in context and not present (or wrong type - expected string). This can be added by doing: context.WithValue(newCtx, mangologger.%s, \"desiredValue\")", errStrictModeOn, label),
+	}
+}
+
 func handleExistentValues(label ctxKey, logOutput *StructuredLog, value string, sl MangoLogger) error {
 	switch label { // set actual
 	case OPERATION:
@@ -297,7 +479,12 @@ func handleExistentValues(label ctxKey, logOutput *StructuredLog, value string,
 	case TYPE:
 		if sl.Config.MangoConfig.Strict {
 			if !slices.Contains(ALLOWED_TYPES, value) {
-				return fmt.Errorf("%w - [%s] required in context and not present (or wrong type - expected string). Current value [%s] is not in the allowed list: %+q", errStrictModeOn, label, value, ALLOWED_TYPES)
+				logOutput.Type = value // kept for the emit failure policy
+				return &strictViolation{
+					label:   label,
+					reason:  fmt.Sprintf("%q is not one of %+q", value, ALLOWED_TYPES),
+					message: fmt.Sprintf("%s - [%s] required in context and not present (or wrong type - expected string). Current value [%s] is not in the allowed list: %+q", errStrictModeOn, label, value, ALLOWED_TYPES),
+				}
 			}
 		}
 		logOutput.Type = value
@@ -308,29 +495,50 @@ func handleExistentValues(label ctxKey, logOutput *StructuredLog, value string,
 func (sl MangoLogger) buildLog(context context.Context, record slog.Record) (*StructuredLog, error) {
 	logOutput := sl.makeBaseLog(record)
 
+	// on failure the entry is still completed, for the failure policies
 	err := sl.handleRequiredFields(context, logOutput)
-	if err != nil {
-		fmt.Printf("Required fields are not present. %s\n", err.Error())
-		return logOutput, err
-	}
 
 	if value, ok := context.Value(CORRELATION_ID).(string); ok {
-		logOutput.Correlationid = value
+		err = errors.Join(err, sl.correlationId(logOutput, value))
+	}
+	if value, ok := context.Value(TRACE_ID).(string); ok {
+		logOutput.TraceId = value
+	}
+	if value, ok := context.Value(SPAN_ID).(string); ok {
+		logOutput.SpanId = value
 	}
 
-	return logOutput, nil
+	return logOutput, err
 }
 
 func (sl MangoLogger) makeBaseLog(record slog.Record) *StructuredLog {
-	logOutput := &StructuredLog{}
-	logOutput.Timestamp = record.Time.Format(RFC3339NanoMC)
-	logOutput.LogId = uuid.New().String() // generate a new UUID for each log entry
+	logOutput := &StructuredLog{SchemaVersion: SchemaVersion}
+	if !record.Time.IsZero() {
+		logOutput.Timestamp = record.Time.Format(RFC3339NanoMC)
+	}
+	logOutput.LogId = sl.ids.newLogId() // generate a new id for each log entry
 	logOutput.Level = record.Level
 	logOutput.Operation = "unknownOperation"
 	logOutput.Application = "unknownApplication"
 	logOutput.Type = "unknownType"
 	logOutput.Correlationid = ""
-	logOutput.Message = record.Message
-	logOutput.Attributes = ToMap(mergeAttrs(sl.attrs, getAllAttrs(record)))
+	logOutput.Message = sl.limits().limitMessage(logOutput, record.Message)
+	attrs := mergeAttrs(sl.attrs, inGroups(sl.groups, getAllAttrs(record)))
+	attrs = sl.limits().limitAttrs(logOutput, attrs, sl.durationFormat())
+	logOutput.Attributes = toMap(attrs, sl.durationFormat())
+	if err := sl.encryptor.apply(logOutput.Attributes); err != nil {
+		sl.diagnostic("Redacted the attributes failing encryption. %s\n", err.Error())
+	}
+	logOutput.order = orderOf(attrs)
+	logOutput.pc = record.PC
+	sl.service.apply(logOutput)
 	return logOutput
 }
+
+// durationFormat is nil safe, as the mango node is optional in the configuration
+func (sl MangoLogger) durationFormat() string {
+	if sl.Config == nil || sl.Config.MangoConfig == nil || sl.Config.MangoConfig.DurationFormat == "" {
+		return DurationFormatNanoseconds
+	}
+	return sl.Config.MangoConfig.DurationFormat
+}
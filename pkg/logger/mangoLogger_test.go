@@ -153,6 +153,41 @@ func TestMangoLogger_MergeAttrs(t *testing.T) {
 
 	assert.Equal(t, "3", m["a"]) // list2 takes precedence
 	assert.Equal(t, "2", m["b"])
+	assert.Equal(t, "a", merged[0].Key) // overridden in place
+	assert.Equal(t, "b", merged[1].Key)
+}
+
+type secret string
+
+func (secret) LogValue() slog.Value { return slog.StringValue("***") }
+
+func TestMangoLogger_MergeAttrsGroupsAndResolve(t *testing.T) {
+	handler := []slog.Attr{slog.Group("req", slog.String("method", "GET")), slog.Any("token", secret("abc"))}
+	record := []slog.Attr{
+		slog.Group("req", slog.Int("status", 200)),
+		slog.Group("", slog.String("inlined", "yes")),
+		slog.Group("empty"),
+		{},
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"req":     map[string]interface{}{"method": "GET", "status": int64(200)},
+		"token":   "***",
+		"inlined": "yes",
+	}, ToMap(mergeAttrs(handler, record)))
+}
+
+func TestMangoLogger_WithGroup(t *testing.T) {
+	sink := &sliceSink{}
+	logger := slog.New(newSinkTestLogger(sink)).With("app", "cart").WithGroup("req").With("id", 7)
+	logger.Info("grouped", "status", 200)
+	logger.WithGroup("").Info("no group added")
+
+	assert.Equal(t, map[string]interface{}{
+		"app": "cart",
+		"req": map[string]interface{}{"id": int64(7), "status": int64(200)},
+	}, sink.entries[0].Attributes)
+	assert.Equal(t, map[string]interface{}{"app": "cart", "req": map[string]interface{}{"id": int64(7)}}, sink.entries[1].Attributes)
 }
 
 func TestFormatWithGoJQ_ErrorCases(t *testing.T) {
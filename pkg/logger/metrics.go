@@ -0,0 +1,250 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Output names used as the output label of the metrics
+const (
+	OutputCli      = "cli"
+	OutputFile     = "file"
+	OutputSyslog   = "syslog"
+	OutputHttp     = "http"
+	OutputOtlp     = "otlp"
+	OutputJournald = "journald"
+	OutputAudit    = "audit"
+	OutputSink     = "sink"
+)
+
+// Metrics counts what a MangoLogger does, shared by the handlers derived from it with WithAttrs
+//
+// It is an expvar.Var, publish it with expvar.Publish("mango", logger.Metrics()), and an http.Handler serving the
+// Prometheus text format, e.g. http.Handle("/metrics", logger.Metrics())
+type Metrics struct {
+	mu               sync.Mutex
+	records          map[recordKey]uint64
+	strictRejections uint64
+	formatErrors     map[string]uint64
+	writeErrors      map[string]uint64
+	dropped          map[string]uint64
+	writes           map[string]WriteStats
+}
+
+type recordKey struct {
+	level   string
+	logType string
+}
+
+// WriteStats of the writes to an output
+type WriteStats struct {
+	// Count of writes, failed or not
+	Count uint64 `json:"count"`
+
+	// Seconds is the total time spent writing
+	Seconds float64 `json:"seconds"`
+}
+
+// MetricsSnapshot is a copy of the counters at a point in time
+type MetricsSnapshot struct {
+	// Records handled per level then Type
+	Records map[string]map[string]uint64 `json:"records"`
+
+	// StrictRejections counts the records rejected by strict mode for missing or invalid context fields
+	StrictRejections uint64 `json:"strictRejections"`
+
+	// FormatErrors counts the jq format failures per output
+	FormatErrors map[string]uint64 `json:"formatErrors"`
+
+	// WriteErrors counts the failed writes per output
+	WriteErrors map[string]uint64 `json:"writeErrors"`
+
+	// Dropped counts the entries given up on per output, e.g. rejected by the http endpoint
+	Dropped map[string]uint64 `json:"dropped"`
+
+	// Writes per output, to spot a slow output
+	Writes map[string]WriteStats `json:"writes"`
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{
+		records:      map[recordKey]uint64{},
+		formatErrors: map[string]uint64{},
+		writeErrors:  map[string]uint64{},
+		dropped:      map[string]uint64{},
+		writes:       map[string]WriteStats{},
+	}
+}
+
+// Metrics of the logger, nil when the logger wasn't created with NewMangoLogger
+func (sl MangoLogger) Metrics() *Metrics {
+	return sl.metrics
+}
+
+// The counting methods are nil safe, so a MangoLogger without metrics counts nothing
+
+// OtherType labels the records of a Type not in ALLOWED_TYPES, so the types logged can't grow the series unbounded
+const OtherType = "other"
+
+func (m *Metrics) countRecord(log *StructuredLog) {
+	if m == nil {
+		return
+	}
+	logType := log.Type
+	if !slices.Contains(ALLOWED_TYPES, logType) {
+		logType = OtherType
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[recordKey{level: log.Level.String(), logType: logType}]++
+}
+
+func (m *Metrics) countStrictRejection() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.strictRejections++
+}
+
+func (m *Metrics) countFormatError(output string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.formatErrors[output]++
+}
+
+func (m *Metrics) countWriteError(output string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.writeErrors[output]++
+}
+
+func (m *Metrics) countDropped(output string, entries int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dropped[output] += uint64(entries)
+}
+
+// observeWrite records a write to output started at start, returning err unchanged
+func (m *Metrics) observeWrite(output string, start time.Time, err error) error {
+	if m == nil {
+		return err
+	}
+	elapsed := time.Since(start)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.writes[output]
+	stats.Count++
+	stats.Seconds += elapsed.Seconds()
+	m.writes[output] = stats
+	if err != nil {
+		m.writeErrors[output]++
+	}
+	return err
+}
+
+// Snapshot copies the counters
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	snapshot := MetricsSnapshot{
+		Records:      map[string]map[string]uint64{},
+		FormatErrors: map[string]uint64{},
+		WriteErrors:  map[string]uint64{},
+		Dropped:      map[string]uint64{},
+		Writes:       map[string]WriteStats{},
+	}
+	if m == nil {
+		return snapshot
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, count := range m.records {
+		if snapshot.Records[key.level] == nil {
+			snapshot.Records[key.level] = map[string]uint64{}
+		}
+		snapshot.Records[key.level][key.logType] = count
+	}
+	snapshot.StrictRejections = m.strictRejections
+	maps.Copy(snapshot.FormatErrors, m.formatErrors)
+	maps.Copy(snapshot.WriteErrors, m.writeErrors)
+	maps.Copy(snapshot.Dropped, m.dropped)
+	maps.Copy(snapshot.Writes, m.writes)
+	return snapshot
+}
+
+// String returns the snapshot as json, making Metrics an expvar.Var
+func (m *Metrics) String() string {
+	content, err := json.Marshal(m.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(content)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = m.WritePrometheus(w)
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	s := m.Snapshot()
+	var b strings.Builder
+
+	writeHeader(&b, "mango_log_records_total", "counter", "Log records handled, per level and type.")
+	for _, level := range slices.Sorted(maps.Keys(s.Records)) {
+		for _, logType := range slices.Sorted(maps.Keys(s.Records[level])) {
+			fmt.Fprintf(&b, "mango_log_records_total{level=\"%s\",type=\"%s\"} %d\n", escapeLabel(level), escapeLabel(logType), s.Records[level][logType])
+		}
+	}
+
+	writeHeader(&b, "mango_log_strict_rejections_total", "counter", "Log records rejected by strict mode.")
+	fmt.Fprintf(&b, "mango_log_strict_rejections_total %d\n", s.StrictRejections)
+
+	writeOutputCounters(&b, "mango_log_format_errors_total", "Failures formatting log entries with jq, per output.", s.FormatErrors)
+	writeOutputCounters(&b, "mango_log_write_errors_total", "Failed writes, per output.", s.WriteErrors)
+	writeOutputCounters(&b, "mango_log_dropped_total", "Log entries dropped, per output.", s.Dropped)
+
+	writeHeader(&b, "mango_log_write_duration_seconds", "summary", "Time spent writing log entries, per output.")
+	for _, output := range slices.Sorted(maps.Keys(s.Writes)) {
+		fmt.Fprintf(&b, "mango_log_write_duration_seconds_sum{output=\"%s\"} %g\n", escapeLabel(output), s.Writes[output].Seconds)
+		fmt.Fprintf(&b, "mango_log_write_duration_seconds_count{output=\"%s\"} %d\n", escapeLabel(output), s.Writes[output].Count)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeHeader(b *strings.Builder, name string, metricType string, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func writeOutputCounters(b *strings.Builder, name string, help string, counts map[string]uint64) {
+	writeHeader(b, name, "counter", help)
+	for _, output := range slices.Sorted(maps.Keys(counts)) {
+		fmt.Fprintf(b, "%s{output=\"%s\"} %d\n", name, escapeLabel(output), counts[output])
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
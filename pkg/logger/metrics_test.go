@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMetricsTestLogger(t *testing.T, filePath string, strict bool) *MangoLogger {
+	return NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled: true,
+			File:    &FileOutputConfig{Enabled: filePath != "", Path: filePath},
+			Cli:     &CliConfig{Enabled: true, Friendly: true, FriendlyFormat: ".["},
+			Syslog:  &SyslogConfig{},
+		},
+		MangoConfig: &MangoConfig{
+			Strict:        strict,
+			CorrelationId: &CorrelationIdConfig{AutoGenerate: true},
+		},
+	})
+}
+
+func TestMetrics_CountsRecordsAndFailures(t *testing.T) {
+	blocker := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(blocker, nil, 0o600))
+	logger := newMetricsTestLogger(t, filepath.Join(blocker, "app.log"), false) // under a file, so writing fails
+	business := context.WithValue(context.Background(), TYPE, BusinessType)
+
+	assert.Error(t, logger.Handle(business, slog.NewRecord(time.Now(), slog.LevelInfo, "one", 0)))
+	assert.Error(t, logger.Handle(business, slog.NewRecord(time.Now(), slog.LevelWarn, "two", 0)))
+	assert.Error(t, logger.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "three", 0)))
+	custom := context.WithValue(context.Background(), TYPE, "user-supplied-42")
+	assert.Error(t, logger.Handle(custom, slog.NewRecord(time.Now(), slog.LevelInfo, "four", 0)))
+
+	s := logger.Metrics().Snapshot()
+	assert.Equal(t, map[string]map[string]uint64{
+		"INFO": {BusinessType: 1, OtherType: 2},
+		"WARN": {BusinessType: 1},
+	}, s.Records)
+	assert.Equal(t, uint64(4), s.FormatErrors[OutputCli])
+	assert.Equal(t, uint64(4), s.WriteErrors[OutputFile])
+	assert.Equal(t, uint64(4), s.Writes[OutputFile].Count)
+	assert.Equal(t, uint64(4), s.Writes[OutputCli].Count)
+	assert.Zero(t, s.WriteErrors[OutputCli])
+}
+
+func TestMetrics_StrictRejections(t *testing.T) {
+	logger := newMetricsTestLogger(t, "", true)
+	assert.Error(t, logger.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "rejected", 0)))
+
+	s := logger.Metrics().Snapshot()
+	assert.Equal(t, uint64(1), s.StrictRejections)
+	assert.Empty(t, s.Records)
+}
+
+func TestMetrics_SharedWithDerivedHandlers(t *testing.T) {
+	logger := newMetricsTestLogger(t, "", false)
+	derived := slog.New(logger).With("component", "cart")
+	derived.Info("counted")
+
+	assert.Equal(t, uint64(1), logger.Metrics().Snapshot().Records["INFO"][OtherType])
+}
+
+func TestMetrics_Expvar(t *testing.T) {
+	m := newMetrics()
+	m.countStrictRejection()
+	m.countDropped(OutputHttp, 5)
+
+	snapshot := MetricsSnapshot{}
+	assert.NoError(t, json.Unmarshal([]byte(m.String()), &snapshot))
+	assert.Equal(t, uint64(1), snapshot.StrictRejections)
+	assert.Equal(t, uint64(5), snapshot.Dropped[OutputHttp])
+}
+
+func TestMetrics_Prometheus(t *testing.T) {
+	m := newMetrics()
+	m.countRecord(&StructuredLog{Level: slog.LevelError, Type: `we"ird`})
+	m.countFormatError(OutputCli)
+	m.countDropped(OutputOtlp, 2)
+	m.writes[OutputSyslog] = WriteStats{Count: 4, Seconds: 1.5}
+	m.writeErrors[OutputSyslog] = 4
+
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get("Content-Type"))
+	body := recorder.Body.String()
+	assert.Contains(t, body, "# TYPE mango_log_records_total counter\n")
+	assert.Contains(t, body, `mango_log_records_total{level="ERROR",type="other"} 1`+"\n", "a type outside ALLOWED_TYPES")
+	assert.Equal(t, `we\"ird\\ \n`, escapeLabel("we\"ird\\ \n"))
+	assert.Contains(t, body, "mango_log_strict_rejections_total 0\n")
+	assert.Contains(t, body, `mango_log_format_errors_total{output="cli"} 1`+"\n")
+	assert.Contains(t, body, `mango_log_dropped_total{output="otlp"} 2`+"\n")
+	assert.Contains(t, body, `mango_log_write_errors_total{output="syslog"} 4`+"\n")
+	assert.Contains(t, body, "# TYPE mango_log_write_duration_seconds summary\n")
+	assert.Contains(t, body, `mango_log_write_duration_seconds_sum{output="syslog"} 1.5`+"\n")
+	assert.Contains(t, body, `mango_log_write_duration_seconds_count{output="syslog"} 4`+"\n")
+}
+
+func TestMetrics_NilSafe(t *testing.T) {
+	var m *Metrics
+	m.countRecord(&StructuredLog{})
+	m.countWriteError(OutputFile)
+	assert.Equal(t, MetricsSnapshot{
+		Records:      map[string]map[string]uint64{},
+		FormatErrors: map[string]uint64{},
+		WriteErrors:  map[string]uint64{},
+		Dropped:      map[string]uint64{},
+		Writes:       map[string]WriteStats{},
+	}, m.Snapshot())
+	assert.Nil(t, MangoLogger{}.Metrics())
+}
@@ -0,0 +1,383 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+const defaultOtlpEndpoint = "http://localhost:4318/v1/logs"
+
+// otlpScopeName is the instrumentation scope reported for every record
+const otlpScopeName = "github.com/bitstep-ie/mango-go/pkg/logger"
+
+// isEnabled is nil safe, as the otlp node is optional in the configuration
+func (c *OtlpOutputConfig) isEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// newOtlpShipper reuses the http shipper for batching, retries and spilling, only the body encoding differs
+func newOtlpShipper(config *OtlpOutputConfig, metrics *Metrics, diagnostics *diagnostics) *httpShipper {
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = defaultOtlpEndpoint
+	}
+	httpConfig := &HttpOutputConfig{
+		Enabled:       true,
+		Endpoint:      endpoint,
+		Gzip:          config.Gzip,
+		Headers:       config.Headers,
+		BatchSize:     config.BatchSize,
+		FlushInterval: config.FlushInterval,
+		Timeout:       config.Timeout,
+		MaxRetries:    config.MaxRetries,
+		SpillDir:      config.SpillDir,
+		SpillMaxBytes: config.SpillMaxBytes,
+		SpillMaxFiles: config.SpillMaxFiles,
+	}
+	return newHttpShipper(httpConfig, otlpEncoder{protocol: config.Protocol, resource: config.ResourceAttributes}, OutputOtlp, metrics, diagnostics)
+}
+
+func (sl MangoLogger) handleOtlpOutput(log *StructuredLog, jsonOut []byte) error {
+	return enqueueByLevel(sl.otlpShipper, sl.Config.Out.Otlp.Debug, log, jsonOut)
+}
+
+// otlpEncoder maps the StructuredLog entries onto an ExportLogsServiceRequest
+type otlpEncoder struct {
+	protocol string
+	resource map[string]string
+}
+
+func (e otlpEncoder) contentType() string {
+	if e.protocol == OtlpProtocolJSON {
+		return "application/json"
+	}
+	return "application/x-protobuf"
+}
+
+func (e otlpEncoder) encode(batch [][]byte) ([]byte, error) {
+	request, err := e.buildRequest(batch, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if e.protocol == OtlpProtocolJSON {
+		return json.Marshal(request)
+	}
+	return request.marshalProto(), nil
+}
+
+// buildRequest groups the records in one ResourceLogs per Application, as service.name is a resource attribute
+func (e otlpEncoder) buildRequest(batch [][]byte, observed time.Time) (*otlpExportLogsRequest, error) {
+	request := &otlpExportLogsRequest{}
+	byApplication := map[string]int{}
+	for _, entry := range batch {
+		log, err := decodeStructuredLog(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode log entry for otlp export: %w", err)
+		}
+
+		index, ok := byApplication[log.Application]
+		if !ok {
+			index = len(request.ResourceLogs)
+			byApplication[log.Application] = index
+			request.ResourceLogs = append(request.ResourceLogs, otlpResourceLogs{
+				Resource:  otlpResource{Attributes: e.resourceAttributes(log.Application)},
+				ScopeLogs: []otlpScopeLogs{{Scope: otlpScope{Name: otlpScopeName}}},
+			})
+		}
+		scope := &request.ResourceLogs[index].ScopeLogs[0]
+		scope.LogRecords = append(scope.LogRecords, toOtlpLogRecord(log, observed))
+	}
+	return request, nil
+}
+
+func (e otlpEncoder) resourceAttributes(application string) []otlpKeyValue {
+	attributes := make([]otlpKeyValue, 0, len(e.resource)+1)
+	for _, key := range slices.Sorted(maps.Keys(e.resource)) {
+		if key != "service.name" {
+			attributes = append(attributes, otlpKeyValue{Key: key, Value: otlpString(e.resource[key])})
+		}
+	}
+	return append(attributes, otlpKeyValue{Key: "service.name", Value: otlpString(application)})
+}
+
+// decodeStructuredLog keeps numbers as json.Number so integers are exported as int values
+func decodeStructuredLog(entry []byte) (*StructuredLog, error) {
+	decoder := json.NewDecoder(bytes.NewReader(entry))
+	decoder.UseNumber()
+	log := &StructuredLog{}
+	if err := decoder.Decode(log); err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+func toOtlpLogRecord(log *StructuredLog, observed time.Time) otlpLogRecord {
+	ts, err := time.Parse(RFC3339NanoMC, log.Timestamp)
+	if err != nil {
+		ts = observed
+	}
+
+	record := otlpLogRecord{
+		TimeUnixNano:         uint64(ts.UnixNano()),
+		ObservedTimeUnixNano: uint64(observed.UnixNano()),
+		SeverityNumber:       otlpSeverity(int(log.Level)),
+		SeverityText:         log.Level.String(),
+		Body:                 toOtlpAnyValue(log.Message),
+	}
+
+	// contract fields first, using the same names as the json output
+	contract := [][2]string{
+		{"type", log.Type},
+		{"operation", log.Operation},
+		{"correlationid", log.Correlationid},
+		{"logId", log.LogId},
+	}
+	for _, field := range contract {
+		if field[1] != "" {
+			record.Attributes = append(record.Attributes, otlpKeyValue{Key: field[0], Value: otlpString(field[1])})
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(log.Attributes)) {
+		record.Attributes = append(record.Attributes, otlpKeyValue{Key: key, Value: toOtlpAnyValue(log.Attributes[key])})
+	}
+
+	if isHexID(log.TraceId, 16) {
+		record.TraceId = log.TraceId
+	}
+	if isHexID(log.SpanId, 8) {
+		record.SpanId = log.SpanId
+	}
+	return record
+}
+
+// otlpSeverity maps slog levels onto SeverityNumber, as in the OpenTelemetry slog bridge (DEBUG=5, INFO=9, WARN=13, ERROR=17)
+func otlpSeverity(level int) int32 {
+	return int32(min(max(level+9, 1), 24))
+}
+
+// isHexID reports whether id is the hex encoding of size bytes and not all zeros (an invalid id for W3C trace context)
+func isHexID(id string, size int) bool {
+	decoded, err := hex.DecodeString(id)
+	if err != nil || len(decoded) != size {
+		return false
+	}
+	return slices.ContainsFunc(decoded, func(b byte) bool { return b != 0 })
+}
+
+func toOtlpAnyValue(value any) otlpAnyValue {
+	switch v := value.(type) {
+	case nil:
+		return otlpAnyValue{}
+	case string:
+		return otlpString(v)
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return otlpAnyValue{IntValue: &i}
+		}
+		if f, err := v.Float64(); err == nil {
+			return otlpAnyValue{DoubleValue: &f}
+		}
+		return otlpString(v.String())
+	case []interface{}:
+		array := &otlpArrayValue{Values: make([]otlpAnyValue, 0, len(v))}
+		for _, item := range v {
+			array.Values = append(array.Values, toOtlpAnyValue(item))
+		}
+		return otlpAnyValue{ArrayValue: array}
+	case map[string]interface{}:
+		list := &otlpKeyValueList{Values: make([]otlpKeyValue, 0, len(v))}
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			list.Values = append(list.Values, otlpKeyValue{Key: key, Value: toOtlpAnyValue(v[key])})
+		}
+		return otlpAnyValue{KvlistValue: list}
+	default:
+		return otlpString(fmt.Sprint(v))
+	}
+}
+
+func otlpString(s string) otlpAnyValue {
+	return otlpAnyValue{StringValue: &s}
+}
+
+// The OTLP logs data model (opentelemetry/proto/collector/logs/v1 and opentelemetry/proto/logs/v1)
+// The json tags follow the OTLP JSON encoding, marshalProto the protobuf binary encoding
+
+type otlpExportLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         uint64         `json:"timeUnixNano,string"`
+	ObservedTimeUnixNano uint64         `json:"observedTimeUnixNano,string"`
+	SeverityNumber       int32          `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes"`
+	TraceId              string         `json:"traceId,omitempty"`
+	SpanId               string         `json:"spanId,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// otlpAnyValue is a oneof, only one of the fields is set
+type otlpAnyValue struct {
+	StringValue *string           `json:"stringValue,omitempty"`
+	BoolValue   *bool             `json:"boolValue,omitempty"`
+	IntValue    *int64            `json:"intValue,omitempty,string"`
+	DoubleValue *float64          `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue   `json:"arrayValue,omitempty"`
+	KvlistValue *otlpKeyValueList `json:"kvlistValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+type otlpKeyValueList struct {
+	Values []otlpKeyValue `json:"values"`
+}
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+func (r *otlpExportLogsRequest) marshalProto() []byte {
+	var b []byte
+	for _, resourceLogs := range r.ResourceLogs {
+		b = appendProtoBytes(b, 1, resourceLogs.marshalProto())
+	}
+	return b
+}
+
+func (r otlpResourceLogs) marshalProto() []byte {
+	var resource []byte
+	for _, attribute := range r.Resource.Attributes {
+		resource = appendProtoBytes(resource, 1, attribute.marshalProto())
+	}
+	b := appendProtoBytes(nil, 1, resource)
+	for _, scopeLogs := range r.ScopeLogs {
+		b = appendProtoBytes(b, 2, scopeLogs.marshalProto())
+	}
+	return b
+}
+
+func (s otlpScopeLogs) marshalProto() []byte {
+	b := appendProtoBytes(nil, 1, appendProtoString(nil, 1, s.Scope.Name))
+	for _, record := range s.LogRecords {
+		b = appendProtoBytes(b, 2, record.marshalProto())
+	}
+	return b
+}
+
+func (r otlpLogRecord) marshalProto() []byte {
+	b := appendProtoFixed64(nil, 1, r.TimeUnixNano)
+	b = appendProtoVarint(b, 2, uint64(r.SeverityNumber))
+	b = appendProtoString(b, 3, r.SeverityText)
+	b = appendProtoBytes(b, 5, r.Body.marshalProto())
+	for _, attribute := range r.Attributes {
+		b = appendProtoBytes(b, 6, attribute.marshalProto())
+	}
+	if traceId, err := hex.DecodeString(r.TraceId); err == nil && len(traceId) > 0 {
+		b = appendProtoBytes(b, 9, traceId)
+	}
+	if spanId, err := hex.DecodeString(r.SpanId); err == nil && len(spanId) > 0 {
+		b = appendProtoBytes(b, 10, spanId)
+	}
+	return appendProtoFixed64(b, 11, r.ObservedTimeUnixNano)
+}
+
+func (kv otlpKeyValue) marshalProto() []byte {
+	b := appendProtoString(nil, 1, kv.Key)
+	return appendProtoBytes(b, 2, kv.Value.marshalProto())
+}
+
+func (v otlpAnyValue) marshalProto() []byte {
+	switch {
+	case v.StringValue != nil:
+		return appendProtoBytes(nil, 1, []byte(*v.StringValue))
+	case v.BoolValue != nil:
+		var value uint64
+		if *v.BoolValue {
+			value = 1
+		}
+		return appendProtoVarint(nil, 2, value)
+	case v.IntValue != nil:
+		return appendProtoVarint(nil, 3, uint64(*v.IntValue))
+	case v.DoubleValue != nil:
+		return appendProtoFixed64(nil, 4, math.Float64bits(*v.DoubleValue))
+	case v.ArrayValue != nil:
+		var array []byte
+		for _, value := range v.ArrayValue.Values {
+			array = appendProtoBytes(array, 1, value.marshalProto())
+		}
+		return appendProtoBytes(nil, 5, array)
+	case v.KvlistValue != nil:
+		var list []byte
+		for _, kv := range v.KvlistValue.Values {
+			list = appendProtoBytes(list, 1, kv.marshalProto())
+		}
+		return appendProtoBytes(nil, 6, list)
+	}
+	return nil
+}
+
+func appendProtoTag(b []byte, field int, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wireType))
+}
+
+func appendProtoVarint(b []byte, field int, value uint64) []byte {
+	b = appendProtoTag(b, field, wireVarint)
+	return binary.AppendUvarint(b, value)
+}
+
+func appendProtoFixed64(b []byte, field int, value uint64) []byte {
+	b = appendProtoTag(b, field, wireFixed64)
+	return binary.LittleEndian.AppendUint64(b, value)
+}
+
+func appendProtoBytes(b []byte, field int, value []byte) []byte {
+	b = appendProtoTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// appendProtoString skips empty strings, the proto3 default value
+func appendProtoString(b []byte, field int, value string) []byte {
+	if value == "" {
+		return b
+	}
+	return appendProtoBytes(b, field, []byte(value))
+}
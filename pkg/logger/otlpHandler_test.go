@@ -0,0 +1,225 @@
+package logger
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubCollector records the raw export requests received on /v1/logs
+type stubCollector struct {
+	mu           sync.Mutex
+	contentTypes []string
+	bodies       [][]byte
+}
+
+func newStubCollector(t *testing.T) (*stubCollector, *httptest.Server) {
+	c := &stubCollector{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/logs", r.URL.Path)
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		c.mu.Lock()
+		c.contentTypes = append(c.contentTypes, r.Header.Get("Content-Type"))
+		c.bodies = append(c.bodies, body)
+		c.mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return c, server
+}
+
+func newOtlpTestLogger(otlpConfig *OtlpOutputConfig) *MangoLogger {
+	return NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled: true,
+			File:    &FileOutputConfig{},
+			Cli:     &CliConfig{},
+			Syslog:  &SyslogConfig{},
+			Otlp:    otlpConfig,
+		},
+		MangoConfig: &MangoConfig{
+			CorrelationId: &CorrelationIdConfig{AutoGenerate: true},
+		},
+	})
+}
+
+func otlpTestContext() context.Context {
+	ctx := context.WithValue(context.Background(), APPLICATION, "checkout-api")
+	ctx = context.WithValue(ctx, OPERATION, "cart-create")
+	ctx = context.WithValue(ctx, TYPE, BusinessType)
+	ctx = context.WithValue(ctx, CORRELATION_ID, "corr-1")
+	ctx = context.WithValue(ctx, TRACE_ID, "4bf92f3577b34da6a3ce929d0e0e4736")
+	return context.WithValue(ctx, SPAN_ID, "00f067aa0ba902b7")
+}
+
+func TestOtlpOutput_JSON(t *testing.T) {
+	c, server := newStubCollector(t)
+	logger := newOtlpTestLogger(&OtlpOutputConfig{
+		Enabled:            true,
+		Endpoint:           server.URL + "/v1/logs",
+		Protocol:           OtlpProtocolJSON,
+		ResourceAttributes: map[string]string{"deployment.environment": "prod", "service.name": "ignored"},
+		FlushInterval:      time.Hour,
+	})
+
+	record := slog.NewRecord(time.Now(), slog.LevelWarn, "cart created", 0)
+	record.AddAttrs(slog.Int("items", 3), slog.String("country", "IE"))
+	assert.NoError(t, logger.Handle(otlpTestContext(), record))
+	assert.NoError(t, logger.otlpShipper.close(context.Background()))
+
+	assert.Equal(t, []string{"application/json"}, c.contentTypes)
+	var request map[string]interface{}
+	assert.NoError(t, json.Unmarshal(c.bodies[0], &request))
+
+	resourceLogs := request["resourceLogs"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"key": "deployment.environment", "value": map[string]interface{}{"stringValue": "prod"}},
+		map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "checkout-api"}},
+	}, resourceLogs["resource"].(map[string]interface{})["attributes"])
+
+	scopeLogs := resourceLogs["scopeLogs"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, otlpScopeName, scopeLogs["scope"].(map[string]interface{})["name"])
+
+	logRecord := scopeLogs["logRecords"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, float64(13), logRecord["severityNumber"])
+	assert.Equal(t, "WARN", logRecord["severityText"])
+	assert.Equal(t, map[string]interface{}{"stringValue": "cart created"}, logRecord["body"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", logRecord["traceId"])
+	assert.Equal(t, "00f067aa0ba902b7", logRecord["spanId"])
+	assert.IsType(t, "", logRecord["timeUnixNano"])
+
+	attributes := map[string]interface{}{}
+	for _, attribute := range logRecord["attributes"].([]interface{}) {
+		kv := attribute.(map[string]interface{})
+		attributes[kv["key"].(string)] = kv["value"]
+	}
+	assert.Equal(t, map[string]interface{}{"stringValue": "Business"}, attributes["type"])
+	assert.Equal(t, map[string]interface{}{"stringValue": "cart-create"}, attributes["operation"])
+	assert.Equal(t, map[string]interface{}{"stringValue": "corr-1"}, attributes["correlationid"])
+	assert.Equal(t, map[string]interface{}{"intValue": "3"}, attributes["items"])
+	assert.Equal(t, map[string]interface{}{"stringValue": "IE"}, attributes["country"])
+	assert.Contains(t, attributes, "logId")
+}
+
+func TestOtlpOutput_Protobuf(t *testing.T) {
+	c, server := newStubCollector(t)
+	logger := newOtlpTestLogger(&OtlpOutputConfig{
+		Enabled:       true,
+		Endpoint:      server.URL + "/v1/logs",
+		FlushInterval: time.Hour,
+	})
+
+	record := slog.NewRecord(time.Unix(1700000000, 5), slog.LevelError, "boom", 0)
+	record.AddAttrs(slog.Bool("retry", true), slog.Float64("ratio", 0.5))
+	assert.NoError(t, logger.Handle(otlpTestContext(), record))
+	assert.NoError(t, logger.otlpShipper.close(context.Background()))
+
+	assert.Equal(t, []string{"application/x-protobuf"}, c.contentTypes)
+
+	request := decodeProto(t, c.bodies[0])
+	resourceLogs := decodeProto(t, request[1][0].([]byte))
+	resource := decodeProto(t, resourceLogs[1][0].([]byte))
+	serviceName := decodeProto(t, resource[1][0].([]byte))
+	assert.Equal(t, "service.name", string(serviceName[1][0].([]byte)))
+	assert.Equal(t, "checkout-api", string(decodeProto(t, serviceName[2][0].([]byte))[1][0].([]byte)))
+
+	scopeLogs := decodeProto(t, resourceLogs[2][0].([]byte))
+	logRecord := decodeProto(t, scopeLogs[2][0].([]byte))
+	assert.Equal(t, uint64(time.Unix(1700000000, 0).UnixNano()), logRecord[1][0])
+	assert.Equal(t, uint64(17), logRecord[2][0])
+	assert.Equal(t, "ERROR", string(logRecord[3][0].([]byte)))
+	assert.Equal(t, "boom", string(decodeProto(t, logRecord[5][0].([]byte))[1][0].([]byte)))
+	assert.Len(t, logRecord[9][0], 16)
+	assert.Len(t, logRecord[10][0], 8)
+	assert.Len(t, logRecord[6], 6) // type, operation, correlationid, logId, ratio, retry
+
+	retry := decodeProto(t, logRecord[6][5].([]byte))
+	assert.Equal(t, "retry", string(retry[1][0].([]byte)))
+	assert.Equal(t, uint64(1), decodeProto(t, retry[2][0].([]byte))[2][0])
+}
+
+func TestOtlpSeverity(t *testing.T) {
+	assert.Equal(t, int32(5), otlpSeverity(int(slog.LevelDebug)))
+	assert.Equal(t, int32(9), otlpSeverity(int(slog.LevelInfo)))
+	assert.Equal(t, int32(13), otlpSeverity(int(slog.LevelWarn)))
+	assert.Equal(t, int32(17), otlpSeverity(int(slog.LevelError)))
+	assert.Equal(t, int32(1), otlpSeverity(-100))
+	assert.Equal(t, int32(24), otlpSeverity(100))
+}
+
+func TestOtlp_InvalidTraceContextIsDropped(t *testing.T) {
+	record := toOtlpLogRecord(&StructuredLog{
+		TraceId: "00000000000000000000000000000000",
+		SpanId:  "not-hex",
+	}, time.Now())
+	assert.Empty(t, record.TraceId)
+	assert.Empty(t, record.SpanId)
+}
+
+// decodeProto is a minimal protobuf decoder returning the values of each field number in order
+// varint and fixed64 fields are returned as uint64, length delimited fields as []byte
+func decodeProto(t *testing.T, b []byte) map[int][]interface{} {
+	fields := map[int][]interface{}{}
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		assert.Positive(t, n)
+		b = b[n:]
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case wireVarint:
+			value, n := binary.Uvarint(b)
+			assert.Positive(t, n)
+			fields[field] = append(fields[field], value)
+			b = b[n:]
+		case wireFixed64:
+			fields[field] = append(fields[field], binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			assert.Positive(t, n)
+			b = b[n:]
+			fields[field] = append(fields[field], b[:size])
+			b = b[size:]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+	}
+	return fields
+}
+
+func TestOtlpOutput_ReplayQuarantinesUndecodable(t *testing.T) {
+	c, server := newStubCollector(t)
+	dir := t.TempDir()
+	bad := filepath.Join(dir, spillFilePrefix+"1"+spillFileExt)
+	good := filepath.Join(dir, spillFilePrefix+"2"+spillFileExt)
+	assert.NoError(t, os.WriteFile(bad, []byte("not json\n"), 0o640))
+	assert.NoError(t, os.WriteFile(good, []byte(`{"level":"INFO","message":"replayed"}`+"\n"), 0o640))
+
+	logger := newOtlpTestLogger(&OtlpOutputConfig{
+		Enabled:       true,
+		Endpoint:      server.URL + "/v1/logs",
+		Protocol:      OtlpProtocolJSON,
+		FlushInterval: time.Hour,
+		SpillDir:      dir,
+	})
+	assert.NoError(t, logger.otlpShipper.close(context.Background())) // the worker replays on start
+
+	c.mu.Lock()
+	assert.Len(t, c.bodies, 1, "the next file is replayed")
+	c.mu.Unlock()
+	files, _ := os.ReadDir(dir)
+	assert.Len(t, files, 1)
+	assert.Equal(t, filepath.Base(bad)+quarantineExt, files[0].Name())
+	assert.Equal(t, uint64(1), logger.Metrics().Snapshot().Dropped[OutputOtlp])
+}
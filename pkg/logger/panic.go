@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+// Attributes of the entries logged by Recover, Go and Fatal
+const (
+	PanicKey = "panic"
+	StackKey = "stack"
+	FatalKey = "fatal"
+)
+
+// shutdownTimeout bounds the flushing of the outputs before exiting or panicking again
+const shutdownTimeout = 5 * time.Second
+
+// exit is os.Exit, replaced in tests
+var exit = os.Exit
+
+// RecoverOption customises Recover and Go
+type RecoverOption func(*recoverer)
+
+// WithRecoverLogger logs the panics with logger instead of slog.Default()
+func WithRecoverLogger(logger *slog.Logger) RecoverOption {
+	return func(r *recoverer) {
+		r.logger = logger
+	}
+}
+
+// WithRepanic panics again with the same value once the panic is logged and the outputs flushed
+func WithRepanic() RecoverOption {
+	return func(r *recoverer) {
+		r.repanic = true
+	}
+}
+
+type recoverer struct {
+	logger  *slog.Logger
+	repanic bool
+}
+
+// Recover logs a panic as an ERROR entry with the panic value and the stack, it must be deferred:
+//
+//	defer mangolog.Recover(ctx)
+//
+// The panic is stopped unless WithRepanic is given, in which case the entry is flagged fatal
+func Recover(ctx context.Context, options ...RecoverOption) {
+	value := recover()
+	if value == nil {
+		return
+	}
+	r := &recoverer{logger: slog.Default()}
+	for _, option := range options {
+		option(r)
+	}
+
+	attrs := []slog.Attr{slog.String(PanicKey, fmt.Sprint(value)), slog.String(StackKey, string(debug.Stack()))}
+	if err, ok := value.(error); ok {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	if r.repanic {
+		attrs = append(attrs, slog.Bool(FatalKey, true))
+	}
+	r.logger.LogAttrs(ctx, slog.LevelError, fmt.Sprintf("panic: %v", value), attrs...)
+
+	if r.repanic {
+		flush(r.logger.Handler())
+		panic(value)
+	}
+}
+
+// Go runs fn in a goroutine, logging its panic as Recover does
+func Go(ctx context.Context, fn func(ctx context.Context), options ...RecoverOption) {
+	go func() {
+		defer Recover(ctx, options...)
+		fn(ctx)
+	}()
+}
+
+// Fatal logs an ERROR entry flagged fatal with slog.Default(), closes its handler to flush every output, then exits with 1
+// The args are slog style key/value pairs or slog.Attr
+func Fatal(ctx context.Context, msg string, args ...any) {
+	logger := slog.Default()
+	logger.Log(ctx, slog.LevelError, msg, append(args, slog.Bool(FatalKey, true))...)
+
+	shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if closer, ok := logger.Handler().(interface{ Close(context.Context) error }); ok {
+		if err := closer.Close(shutdown); err != nil {
+			diagnosticsOf(logger.Handler()).printf("Failed to close the logger before exiting. %s\n", err.Error())
+		}
+	}
+	exit(1)
+}
+
+// flush the outputs of handler, when it can be flushed as a MangoLogger can
+func flush(handler slog.Handler) {
+	flusher, ok := handler.(interface{ Flush(context.Context) error })
+	if !ok {
+		return
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := flusher.Flush(shutdown); err != nil {
+		diagnosticsOf(handler).printf("Failed to flush the logger. %s\n", err.Error())
+	}
+}
+
+// diagnosticsOf returns the diagnostics of handler when it is a MangoLogger, nil for the default ones otherwise
+func diagnosticsOf(handler slog.Handler) *diagnostics {
+	var failures *failureHandler
+	switch h := handler.(type) {
+	case MangoLogger:
+		failures = h.failures
+	case *MangoLogger:
+		if h != nil {
+			failures = h.failures
+		}
+	}
+	if failures == nil {
+		return nil
+	}
+	return failures.diagnostics
+}
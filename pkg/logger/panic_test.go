@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecover(t *testing.T) {
+	sink := &sliceSink{}
+	logger := slog.New(newSinkTestLogger(sink))
+
+	func() {
+		defer Recover(context.Background(), WithRecoverLogger(logger))
+		panic(errors.New("nil map"))
+	}()
+	func() {
+		defer Recover(context.Background(), WithRecoverLogger(logger))
+	}()
+
+	assert.Len(t, sink.entries, 1, "nothing is logged without a panic")
+	entry := sink.entries[0]
+	assert.Equal(t, slog.LevelError, entry.Level)
+	assert.Equal(t, "panic: nil map", entry.Message)
+	assert.Equal(t, "nil map", entry.Attributes[PanicKey])
+	assert.Equal(t, "nil map", entry.Attributes["error"])
+	assert.Contains(t, entry.Attributes[StackKey], "panic_test.go")
+	assert.NotContains(t, entry.Attributes, FatalKey)
+}
+
+func TestRecover_Repanic(t *testing.T) {
+	sink := &sliceSink{}
+	logger := slog.New(newSinkTestLogger(sink))
+
+	assert.PanicsWithValue(t, "boom", func() {
+		defer Recover(context.Background(), WithRecoverLogger(logger), WithRepanic())
+		panic("boom")
+	})
+	assert.Len(t, sink.entries, 1)
+	assert.Equal(t, true, sink.entries[0].Attributes[FatalKey])
+}
+
+func TestGo(t *testing.T) {
+	sink := &sliceSink{}
+	logger := slog.New(newSinkTestLogger(sink))
+	done := make(chan struct{})
+
+	ctx := context.WithValue(context.Background(), OPERATION, "worker")
+	Go(ctx, func(ctx context.Context) {
+		defer close(done)
+		var m map[string]int
+		m[ctx.Value(OPERATION).(string)]++
+	}, WithRecoverLogger(logger))
+	<-done
+
+	assert.Eventually(t, func() bool {
+		sink.mu.Lock()
+		defer sink.mu.Unlock()
+		return len(sink.entries) == 1
+	}, time.Second, 10*time.Millisecond)
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	assert.Equal(t, "worker", sink.entries[0].Operation)
+	assert.True(t, strings.HasPrefix(sink.entries[0].Message.(string), "panic: assignment to entry in nil map"))
+}
+
+// closeCounter records the Close calls of a handler
+type closeCounter struct {
+	slog.Handler
+	closed int
+}
+
+func (c *closeCounter) Close(context.Context) error {
+	c.closed++
+	return nil
+}
+
+func TestFatal(t *testing.T) {
+	sink := &sliceSink{}
+	handler := &closeCounter{Handler: newSinkTestLogger(sink)}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(handler))
+	defer slog.SetDefault(previous)
+	code := -1
+	exit = func(c int) { code = c }
+	defer func() { exit = os.Exit }()
+
+	Fatal(context.Background(), "cannot start", "port", 8080)
+
+	assert.Equal(t, 1, code)
+	assert.Equal(t, 1, handler.closed)
+	assert.Len(t, sink.entries, 1)
+	assert.Equal(t, slog.LevelError, sink.entries[0].Level)
+	assert.Equal(t, map[string]interface{}{"port": int64(8080), FatalKey: true}, sink.entries[0].Attributes)
+}
+
+func TestDiagnosticsOf(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewMangoLogger(&LogConfig{
+		Out:         &OutConfig{File: &FileOutputConfig{}, Cli: &CliConfig{}},
+		MangoConfig: &MangoConfig{Failure: &FailureConfig{Diagnostics: &out}},
+	})
+	diagnosticsOf(logger).printf("Failed to flush the logger. %s\n", "boom")
+	diagnosticsOf(*logger).printf("Failed to close the logger before exiting. %s\n", "boom")
+	assert.Equal(t, "Failed to flush the logger. boom\nFailed to close the logger before exiting. boom\n", out.String())
+
+	assert.Nil(t, diagnosticsOf(slog.NewTextHandler(io.Discard, nil)))
+	assert.Nil(t, diagnosticsOf((*MangoLogger)(nil)))
+}
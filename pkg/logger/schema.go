@@ -0,0 +1,293 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// SchemaVersion of the StructuredLog json, written as schemaVersion in every entry
+// Bumped on any change of the field names, types or allowed values
+const SchemaVersion = "1"
+
+// ErrInvalidLogLine is wrapped by the errors of ValidateLogLine
+var ErrInvalidLogLine = errors.New("log line does not match the StructuredLog schema")
+
+// levelPattern matches the json of a slog.Level, e.g. INFO or WARN+2
+const levelPattern = `^(DEBUG|INFO|WARN|ERROR)([+-][0-9]+)?$`
+
+var (
+	levelType = reflect.TypeFor[slog.Level]()
+	anyType   = reflect.TypeFor[any]()
+)
+
+// schema is generated once, both for Schema and ValidateLogLine
+var schema = sync.OnceValue(generateSchema)
+
+// schemaAnyType is schema with any string allowed as type, for SchemaAnyType and ValidateLogLineAnyType
+var schemaAnyType = sync.OnceValue(func() map[string]any {
+	s := generateSchema()
+	s["properties"].(map[string]any)["type"] = map[string]any{
+		"type":        "string",
+		"description": "any type, as written outside strict mode",
+	}
+	return s
+})
+
+// Schema returns the JSON Schema (draft 2020-12) of the StructuredLog json of SchemaVersion
+// type is one of ALLOWED_TYPES, or unknownType when none is in the context
+func Schema() []byte {
+	return marshalSchema(schema())
+}
+
+// SchemaAnyType returns Schema relaxed to allow any string as type, as a logger without strict mode writes it
+func SchemaAnyType() []byte {
+	return marshalSchema(schemaAnyType())
+}
+
+func marshalSchema(s map[string]any) []byte {
+	content, _ := json.MarshalIndent(s, "", "  ")
+	return append(content, '\n')
+}
+
+// generateSchema describes the json fields of StructuredLog, with the service fields allowed at the top level
+func generateSchema() map[string]any {
+	root := schemaOfStruct(reflect.TypeFor[StructuredLog]())
+	properties := root["properties"].(map[string]any)
+	service := schemaOfStruct(reflect.TypeFor[ServiceMetadata]())
+	for name, property := range service["properties"].(map[string]any) {
+		properties[name] = property
+	}
+	properties["schemaVersion"] = map[string]any{"const": SchemaVersion}
+	properties["type"] = map[string]any{"enum": append(slices.Clone(ALLOWED_TYPES), "unknownType")}
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["title"] = "StructuredLog"
+	root["description"] = fmt.Sprintf("mango-go log entry, schemaVersion %s", SchemaVersion)
+	return root
+}
+
+// schemaOfStruct lists the json fields of t, those without omitempty are required
+func schemaOfStruct(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for _, field := range reflect.VisibleFields(t) {
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		omitEmpty := slices.Contains(strings.Split(options, ","), "omitempty")
+		properties[name] = schemaOf(field.Type, !omitEmpty)
+		if !omitEmpty {
+			required = append(required, name)
+		}
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// schemaOf t, nullable when a nil map, slice or pointer is written as null
+func schemaOf(t reflect.Type, nullable bool) map[string]any {
+	if t == levelType {
+		return map[string]any{"type": "string", "pattern": levelPattern}
+	}
+	if t == anyType {
+		return map[string]any{}
+	}
+	var s map[string]any
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem(), nullable)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Struct:
+		return schemaOfStruct(t)
+	case reflect.Slice, reflect.Array:
+		s = map[string]any{"type": "array", "items": schemaOf(t.Elem(), true)}
+	case reflect.Map:
+		s = map[string]any{"type": "object"}
+		if t.Elem() != anyType {
+			s["additionalProperties"] = schemaOf(t.Elem(), true)
+		}
+	default:
+		return map[string]any{}
+	}
+	if nullable {
+		s["type"] = []any{s["type"], "null"}
+	}
+	return s
+}
+
+// ValidateLogLine checks one json line of the file output against Schema, for the contract tests of consumers
+// The error wraps ErrInvalidLogLine and lists every mismatch
+func ValidateLogLine(line []byte) error {
+	return validateLine(schema(), line)
+}
+
+// ValidateLogLineAnyType checks the line against SchemaAnyType, for the output of a logger without strict mode
+func ValidateLogLineAnyType(line []byte) error {
+	return validateLine(schemaAnyType(), line)
+}
+
+func validateLine(s map[string]any, line []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	var entry any
+	if err := decoder.Decode(&entry); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidLogLine, err.Error())
+	}
+	if decoder.More() {
+		return fmt.Errorf("%w: more than one json value", ErrInvalidLogLine)
+	}
+	var mismatches []string
+	validate(s, entry, "", &mismatches)
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidLogLine, strings.Join(mismatches, "; "))
+	}
+	return nil
+}
+
+// validate value against the subset of JSON Schema generateSchema uses
+func validate(s map[string]any, value any, path string, mismatches *[]string) {
+	fail := func(format string, args ...any) {
+		name := path
+		if name == "" {
+			name = "entry"
+		}
+		*mismatches = append(*mismatches, name+": "+fmt.Sprintf(format, args...))
+	}
+	if expected, ok := s["const"]; ok && value != expected {
+		fail("%s is not %q", jsonText(value), expected)
+		return
+	}
+	if enum, ok := s["enum"].([]string); ok {
+		if text, isString := value.(string); !isString || !slices.Contains(enum, text) {
+			fail("%s is not one of %+q", jsonText(value), enum)
+		}
+		return
+	}
+	if t, ok := s["type"]; ok && !hasType(t, value) {
+		fail("%s is not of type %v", jsonText(value), t)
+		return
+	}
+	switch v := value.(type) {
+	case string:
+		if pattern, ok := s["pattern"].(string); ok && !compiled(pattern).MatchString(v) {
+			fail("%q does not match %s", v, pattern)
+		}
+	case json.Number:
+		// generateSchema only sets a minimum of 0
+		if _, ok := s["minimum"]; ok && strings.HasPrefix(v.String(), "-") {
+			fail("%s is below 0", v)
+		}
+	case []any:
+		if items, ok := s["items"].(map[string]any); ok {
+			for i, item := range v {
+				validate(items, item, fmt.Sprintf("%s[%d]", path, i), mismatches)
+			}
+		}
+	case map[string]any:
+		validateObject(s, v, path, mismatches, fail)
+	}
+}
+
+func validateObject(s map[string]any, object map[string]any, path string, mismatches *[]string, fail func(string, ...any)) {
+	prefix := path
+	if prefix != "" {
+		prefix += "."
+	}
+	required, _ := s["required"].([]string)
+	for _, name := range required {
+		if _, ok := object[name]; !ok {
+			fail("%s missing", name)
+		}
+	}
+	properties, _ := s["properties"].(map[string]any)
+	for _, name := range slices.Sorted(maps.Keys(object)) {
+		if property, ok := properties[name].(map[string]any); ok {
+			validate(property, object[name], prefix+name, mismatches)
+			continue
+		}
+		switch additional := s["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				fail("%s not allowed", name)
+			}
+		case map[string]any:
+			validate(additional, object[name], prefix+name, mismatches)
+		}
+	}
+}
+
+// hasType tells whether value, decoded with json.Number, is of the json type or one of the types
+func hasType(t any, value any) bool {
+	if types, ok := t.([]any); ok {
+		return slices.ContainsFunc(types, func(t any) bool { return hasType(t, value) })
+	}
+	switch t {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		return ok && !strings.ContainsAny(n.String(), ".eE")
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+// patterns are the compiled patterns of the schema
+var patterns sync.Map
+
+func compiled(pattern string) *regexp.Regexp {
+	if r, ok := patterns.Load(pattern); ok {
+		return r.(*regexp.Regexp)
+	}
+	r := regexp.MustCompile(pattern)
+	patterns.Store(pattern, r)
+	return r
+}
+
+func jsonText(value any) string {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(content)
+}
@@ -0,0 +1,130 @@
+package logger
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var updateSchema = flag.Bool("update-schema", false, "rewrite the published StructuredLog schema")
+
+const publishedSchema = "../../documentation/docs/assets/structured-log.v" + SchemaVersion + ".schema.json"
+
+func TestSchema_Published(t *testing.T) {
+	if *updateSchema {
+		assert.NoError(t, os.WriteFile(publishedSchema, Schema(), 0o644))
+	}
+	published, err := os.ReadFile(publishedSchema)
+	assert.NoError(t, err)
+	assert.Equal(t, string(published), string(Schema()), "run go test -run TestSchema_Published -update-schema, and bump SchemaVersion on a breaking change")
+}
+
+func TestSchema_Fields(t *testing.T) {
+	s := schema()
+	assert.Equal(t, []string{"type", "application", "operation", "correlationid", "logId", "level", "message", "attributes"}, s["required"])
+	properties := s["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"const": SchemaVersion}, properties["schemaVersion"])
+	assert.Equal(t, []string{BusinessType, SecurityType, PerformanceType, "unknownType"}, properties["type"].(map[string]any)["enum"])
+	assert.Equal(t, map[string]any{"type": []any{"object", "null"}}, properties["attributes"])
+	assert.Equal(t, map[string]any{"type": "integer", "minimum": 0}, properties["auditSeq"])
+	assert.Contains(t, properties, "hostname") // service fields at the top level
+	assert.Equal(t, false, properties["resource"].(map[string]any)["additionalProperties"])
+	assert.Equal(t, map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "integer"}},
+		properties["originalSizes"].(map[string]any)["properties"].(map[string]any)["attributes"])
+}
+
+func TestValidateLogLine_HandlerOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	newLogger := func(mango *MangoConfig) *slog.Logger {
+		mango.CorrelationId = &CorrelationIdConfig{AutoGenerate: true}
+		return slog.New(NewMangoLogger(&LogConfig{
+			Out: &OutConfig{
+				Enabled: true,
+				File:    &FileOutputConfig{Enabled: true, Debug: true, Path: path},
+				Cli:     &CliConfig{},
+				Syslog:  &SyslogConfig{},
+			},
+			MangoConfig: mango,
+		}))
+	}
+	ctx := context.WithValue(context.WithValue(context.WithValue(context.Background(),
+		TYPE, SecurityType), APPLICATION, "app"), OPERATION, "login")
+
+	plain := newLogger(&MangoConfig{})
+	plain.DebugContext(ctx, "debug", "n", 1, "f", 1.5, "d", time.Second, slog.Group("g", "err", errors.New("boom")))
+	plain.InfoContext(context.Background(), "no context")
+	newLogger(&MangoConfig{
+		Service: &ServiceConfig{Environment: "prod", Placement: ServicePlacementTopLevel, AutoDetect: true},
+		Limits:  &LimitsConfig{MaxMessageLength: 4, MaxStringLength: 2},
+	}).InfoContext(ctx, "truncated", "s", "long")
+	newLogger(&MangoConfig{
+		Service: &ServiceConfig{Region: "eu"},
+		Audit:   &AuditConfig{Enabled: true, Key: "k"},
+	}).ErrorContext(ctx, "sealed")
+	newLogger(&MangoConfig{
+		Strict:  true,
+		Failure: &FailureConfig{Strict: FailurePolicyEmit},
+	}).InfoContext(context.WithValue(context.Background(), TYPE, BusinessType), "emitted", "k", []any{1, "two"})
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer func() { _ = file.Close() }()
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+		assert.NoError(t, ValidateLogLine(scanner.Bytes()), scanner.Text())
+		assert.Contains(t, scanner.Text(), `{"schemaVersion":"`+SchemaVersion+`",`)
+	}
+	assert.Equal(t, 5, lines)
+}
+
+func TestValidateLogLineAnyType(t *testing.T) {
+	valid := `{"type":"Business","application":"a","operation":"o","correlationid":"c","logId":"l","level":"INFO","message":"m","attributes":null}`
+	misspelled := strings.Replace(valid, "Business", "Secruity", 1)
+	assert.ErrorContains(t, ValidateLogLine([]byte(misspelled)), `type: "Secruity" is not one of`)
+	assert.NoError(t, ValidateLogLineAnyType([]byte(misspelled)), "written as it is outside strict mode")
+	assert.NoError(t, ValidateLogLineAnyType([]byte(valid)))
+	assert.ErrorContains(t, ValidateLogLineAnyType([]byte(strings.Replace(valid, `"Business"`, `7`, 1))), `type: 7 is not of type string`)
+	assert.ErrorContains(t, ValidateLogLineAnyType([]byte(strings.Replace(valid, `"level"`, `"level2"`, 1))), `level2 not allowed`)
+
+	relaxed := schemaAnyType()["properties"].(map[string]any)["type"]
+	assert.Equal(t, "string", relaxed.(map[string]any)["type"])
+	assert.Contains(t, string(SchemaAnyType()), `"description": "any type, as written outside strict mode"`)
+	assert.Contains(t, string(Schema()), `"unknownType"`, "the strict schema is left as it is")
+}
+
+func TestValidateLogLine_Mismatches(t *testing.T) {
+	valid := `"type":"Business","application":"a","operation":"o","correlationid":"c","logId":"l","level":"INFO","message":"m","attributes":null`
+	assert.NoError(t, ValidateLogLine([]byte(`{"schemaVersion":"1",`+valid+`}`)))
+
+	tests := map[string]string{
+		`{` + valid + `,"schemaVersion":"0"}`:                      `schemaVersion: "0" is not "1"`,
+		`{` + strings.Replace(valid, "Business", "Audit", 1) + `}`: `type: "Audit" is not one of ["Business" "Security" "Performance" "unknownType"]`,
+		`{` + valid[:len(valid)-len(`,"attributes":null`)] + `}`:   `entry: attributes missing`,
+		`{` + valid + `,"level2":1}`:                               `entry: level2 not allowed`,
+		`{` + valid + `,"auditSeq":-1}`:                            `auditSeq: -1 is below 0`,
+		`{` + valid + `,"pid":1.5}`:                                `pid: 1.5 is not of type integer`,
+		`{` + valid + `,"violations":["a",2]}`:                     `violations[1]: 2 is not of type string`,
+		`{` + valid + `,"resource":{"env":"prod"}}`:                `resource: env not allowed`,
+		`{` + valid + `,"originalSizes":{"attributes":{"a":"1"}}}`: `originalSizes.attributes.a: "1" is not of type integer`,
+		`{` + strings.Replace(valid, "INFO", "LOUD", 1) + `}`:      `level: "LOUD" does not match`,
+		`[]`:       `entry: [] is not of type object`,
+		`{} {}`:    `more than one json value`,
+		`not json`: `invalid character`,
+	}
+	for line, want := range tests {
+		err := ValidateLogLine([]byte(line))
+		assert.ErrorIs(t, err, ErrInvalidLogLine, line)
+		assert.ErrorContains(t, err, want, line)
+	}
+}
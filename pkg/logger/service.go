@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"os"
+	"runtime/debug"
+)
+
+// ServiceMetadata describes the service logging the entry
+type ServiceMetadata struct {
+	Environment string `json:"environment,omitempty"`
+	Region      string `json:"region,omitempty"`
+	Version     string `json:"serviceVersion,omitempty"`
+	Hostname    string `json:"hostname,omitempty"`
+	Pid         int    `json:"pid,omitempty"`
+	Commit      string `json:"commit,omitempty"`
+}
+
+// service is the metadata of the configuration, resolved once
+type service struct {
+	application string
+	metadata    *ServiceMetadata // nil when there is none
+	topLevel    bool
+}
+
+func newService(config *MangoConfig) *service {
+	if config == nil || config.Service == nil {
+		return nil
+	}
+	c := config.Service
+	metadata := &ServiceMetadata{
+		Environment: c.Environment,
+		Region:      c.Region,
+		Version:     c.Version,
+		Hostname:    c.Hostname,
+		Commit:      c.Commit,
+	}
+	if c.AutoDetect {
+		detectService(metadata)
+	}
+	s := &service{application: c.Application, topLevel: c.Placement == ServicePlacementTopLevel}
+	if *metadata != (ServiceMetadata{}) {
+		s.metadata = metadata
+	}
+	return s
+}
+
+// detectService fills the hostname, pid, version and commit not configured
+func detectService(metadata *ServiceMetadata) {
+	if metadata.Hostname == "" {
+		metadata.Hostname, _ = os.Hostname()
+	}
+	metadata.Pid = os.Getpid()
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	if metadata.Version == "" && info.Main.Version != "(devel)" {
+		metadata.Version = info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && metadata.Commit == "" {
+			metadata.Commit = setting.Value
+		}
+	}
+}
+
+// The methods are nil safe, a logger without service configuration adds nothing
+
+// apply sets the default application and the metadata of the entry
+func (s *service) apply(log *StructuredLog) {
+	if s == nil {
+		return
+	}
+	if s.application != "" {
+		log.Application = s.application
+	}
+	if s.metadata != nil {
+		metadata := *s.metadata // a copy per entry, the sinks may keep and change them
+		log.Service = &metadata
+	}
+	log.serviceTopLevel = s.topLevel
+}
+
+// names is true when the service has a default application, so APPLICATION isn't required in the context
+func (s *service) names() bool {
+	return s != nil && s.application != ""
+}
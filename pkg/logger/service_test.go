@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newServiceTestLogger(sink EntrySink, config *ServiceConfig) *MangoLogger {
+	return NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled: true,
+			File:    &FileOutputConfig{},
+			Cli:     &CliConfig{},
+			Syslog:  &SyslogConfig{},
+			Sink:    sink,
+		},
+		MangoConfig: &MangoConfig{
+			Strict:        true,
+			CorrelationId: &CorrelationIdConfig{AutoGenerate: true},
+			Service:       config,
+		},
+	})
+}
+
+func TestService_Resource(t *testing.T) {
+	sink := &sliceSink{}
+	handler := newServiceTestLogger(sink, &ServiceConfig{Application: "checkout-api", Environment: "prod", Region: "eu-west-1", Version: "1.4.2"})
+	ctx := context.WithValue(context.WithValue(context.Background(), TYPE, BusinessType), OPERATION, "cart-create")
+
+	// APPLICATION is not required in strict mode, the service names it
+	assert.NoError(t, handler.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelInfo, "cart created", 0)))
+	assert.NoError(t, handler.Handle(context.WithValue(ctx, APPLICATION, "batch"), slog.NewRecord(time.Now(), slog.LevelInfo, "from context", 0)))
+
+	entry := sink.entries[0]
+	assert.Equal(t, "checkout-api", entry.Application)
+	assert.Equal(t, &ServiceMetadata{Environment: "prod", Region: "eu-west-1", Version: "1.4.2"}, entry.Service)
+	assert.Equal(t, "batch", sink.entries[1].Application)
+
+	content, err := json.Marshal(entry)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"attributes":{},"resource":{"environment":"prod","region":"eu-west-1","serviceVersion":"1.4.2"}}`)
+
+	entry.Service.Region = "changed"
+	assert.Equal(t, "eu-west-1", sink.entries[1].Service.Region, "each entry has its own copy")
+}
+
+func TestService_TopLevel(t *testing.T) {
+	sink := &sliceSink{}
+	handler := newServiceTestLogger(sink, &ServiceConfig{Environment: "prod", Placement: ServicePlacementTopLevel})
+	ctx := context.WithValue(context.WithValue(context.Background(), TYPE, BusinessType), OPERATION, "cart-create")
+
+	err := handler.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelInfo, "no application", 0))
+	assert.ErrorIs(t, err, errStrictModeOn, "without a default application APPLICATION is still required")
+
+	ctx = context.WithValue(ctx, APPLICATION, "checkout-api")
+	slog.New(handler).InfoContext(ctx, "top level", "items", 3)
+	content, err := json.Marshal(sink.entries[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"correlationid":"`)
+	assert.Contains(t, string(content), `","environment":"prod","logId":"`)
+	assert.Contains(t, string(content), `"attributes":{"items":3}`)
+	assert.NotContains(t, string(content), `"resource"`)
+}
+
+func TestService_AutoDetect(t *testing.T) {
+	hostname, _ := os.Hostname()
+	s := newService(&MangoConfig{Service: &ServiceConfig{AutoDetect: true, Commit: "abc123"}})
+
+	assert.Equal(t, hostname, s.metadata.Hostname)
+	assert.Equal(t, os.Getpid(), s.metadata.Pid)
+	assert.Equal(t, "abc123", s.metadata.Commit, "configured values are kept")
+
+	assert.Nil(t, newService(&MangoConfig{}))
+	assert.Nil(t, newService(&MangoConfig{Service: &ServiceConfig{Application: "checkout-api"}}).metadata)
+}
+
+func TestService_AuditMacStaysLast(t *testing.T) {
+	log := StructuredLog{
+		Attributes:      map[string]interface{}{"a": 1},
+		order:           attrOrder{{key: "a"}},
+		Service:         &ServiceMetadata{Environment: "prod"},
+		serviceTopLevel: true,
+		AuditMac:        "mac",
+	}
+	content, err := json.Marshal(log)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(content), `"attributes":{"a":1},"auditMac":"mac"}`), string(content))
+	assert.Contains(t, string(content), `"correlationid":"","environment":"prod","logId":""`)
+}
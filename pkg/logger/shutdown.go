@@ -0,0 +1,167 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// ErrLoggerClosed is returned by Handle once the logger is closed
+var ErrLoggerClosed = errors.New("mango logger is closed")
+
+// lifecycle lets Close wait for the entries being handled, shared by the handlers derived from a MangoLogger
+// No lock is held while an entry is handled, so an ErrorHandler or Sink logging again can't block Close
+type lifecycle struct {
+	mu       sync.Mutex
+	closed   bool
+	inFlight int
+
+	// idle is closed once the last entry in flight is handled after close
+	idle chan struct{}
+}
+
+// begin returns false when the logger is closed, otherwise end must be called once the entry is handled
+func (l *lifecycle) begin() bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return false
+	}
+	l.inFlight++
+	return true
+}
+
+func (l *lifecycle) end() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	if l.inFlight == 0 && l.idle != nil {
+		close(l.idle)
+		l.idle = nil
+	}
+}
+
+// close returns false when already closed, otherwise it waits for the entries in flight until ctx is done
+// The entries still in flight past ctx fail to write to the files, closed meanwhile
+func (l *lifecycle) close(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return false, nil
+	}
+	l.closed = true
+	if l.inFlight == 0 {
+		l.mu.Unlock()
+		return true, nil
+	}
+	idle := make(chan struct{})
+	l.idle = idle
+	l.mu.Unlock()
+
+	select {
+	case <-idle:
+		return true, nil
+	case <-ctx.Done():
+		return true, fmt.Errorf("entries still being handled: %w", ctx.Err())
+	}
+}
+
+// Flush ships the entries pending in the http and otlp outputs and fsyncs the log files
+// ctx bounds the time spent shipping
+func (sl MangoLogger) Flush(ctx context.Context) error {
+	var errs []error
+	if sl.httpShipper != nil {
+		errs = append(errs, sl.httpShipper.flush(ctx))
+	}
+	if sl.otlpShipper != nil {
+		errs = append(errs, sl.otlpShipper.flush(ctx))
+	}
+	if sl.file != nil && sl.Config.Out.File.Enabled {
+		errs = append(errs, sl.file.Sync())
+	}
+	for _, route := range sl.routes {
+		errs = append(errs, route.file.Sync())
+	}
+	return errors.Join(errs...)
+}
+
+// Close waits for the entries being handled, flushes as Flush does, then closes the files and connections
+// Handle fails with ErrLoggerClosed afterwards, closing again is a no-op. Call it on shutdown, e.g. on SIGTERM:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	err := handler.Close(ctx)
+func (sl MangoLogger) Close(ctx context.Context) error {
+	var errs []error
+	if sl.lifecycle != nil {
+		closing, err := sl.lifecycle.close(ctx)
+		if !closing {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+
+	if sl.httpShipper != nil {
+		errs = append(errs, sl.httpShipper.close(ctx))
+	}
+	if sl.otlpShipper != nil {
+		errs = append(errs, sl.otlpShipper.close(ctx))
+	}
+	if sl.file != nil {
+		if sl.Config.Out.File.Enabled {
+			errs = append(errs, sl.file.Sync())
+		}
+		errs = append(errs, sl.file.Close())
+	}
+	for _, route := range sl.routes {
+		errs = append(errs, route.file.Sync(), route.file.Close())
+	}
+	if sl.journal != nil {
+		errs = append(errs, sl.journal.close())
+	}
+	return errors.Join(errs...)
+}
+
+// Sync fsyncs the file lumberjack writes to, through a handle of its own as lumberjack doesn't export its file
+// lumberjack writes straight without buffering, so what it wrote is synced. It is a no-op when there is no file yet
+func (f *rotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	file, err := os.OpenFile(f.filename(), os.O_WRONLY, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err == nil {
+		err = file.Sync()
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to sync %s: %w", f.filename(), err)
+	}
+	return nil
+}
+
+func (j *journal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.conn == nil {
+		return nil
+	}
+	err := j.conn.Close()
+	j.conn = nil
+	return err
+}
@@ -0,0 +1,190 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/natefinch/lumberjack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMangoLogger_FlushShipsPending(t *testing.T) {
+	c, server := newCollector(t)
+	logger := newHttpTestLogger(&HttpOutputConfig{Enabled: true, Endpoint: server.URL, BatchSize: 100, FlushInterval: time.Hour})
+	defer func() { _ = logger.Close(context.Background()) }()
+
+	logRecord(t, logger, slog.LevelInfo, "pending")
+	_, entries := c.received()
+	assert.Empty(t, entries)
+
+	assert.NoError(t, logger.Flush(context.Background()))
+	_, entries = c.received()
+	assert.Len(t, entries, 1)
+	assert.NoError(t, logger.Flush(context.Background()), "nothing pending")
+}
+
+func TestMangoLogger_Close(t *testing.T) {
+	c, server := newCollector(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	logger := NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled: true,
+			File: &FileOutputConfig{
+				Enabled: true,
+				Path:    path,
+				Routes:  []FileRouteConfig{{Levels: []slog.Level{slog.LevelError}, Path: filepath.Join(dir, "errors.log")}},
+			},
+			Cli:    &CliConfig{},
+			Syslog: &SyslogConfig{},
+			Http:   &HttpOutputConfig{Enabled: true, Endpoint: server.URL, BatchSize: 100, FlushInterval: time.Hour},
+		},
+		MangoConfig: &MangoConfig{CorrelationId: &CorrelationIdConfig{AutoGenerate: true}},
+	})
+
+	logRecord(t, logger, slog.LevelInfo, "last words")
+	logRecord(t, logger, slog.LevelError, "routed")
+	assert.NoError(t, logger.Close(context.Background()))
+
+	_, entries := c.received()
+	assert.Len(t, entries, 2, "the pending entries are shipped on close")
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "last words")
+
+	err = logger.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "too late", 0))
+	assert.ErrorIs(t, err, ErrLoggerClosed)
+	derived := logger.WithAttrs([]slog.Attr{slog.String("k", "v")})
+	assert.ErrorIs(t, derived.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "too late", 0)), ErrLoggerClosed)
+	content, _ = os.ReadFile(path)
+	assert.NotContains(t, string(content), "too late", "the file isn't reopened")
+
+	assert.NoError(t, logger.Close(context.Background()), "closing again is a no-op")
+}
+
+func TestMangoLogger_CloseWaitsForHandle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	logger := NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled: true,
+			File:    &FileOutputConfig{Enabled: true, Path: path},
+			Cli:     &CliConfig{},
+			Syslog:  &SyslogConfig{},
+		},
+		MangoConfig: &MangoConfig{CorrelationId: &CorrelationIdConfig{AutoGenerate: true}},
+	})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	handled := 0
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				if logger.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "entry", 0)) == nil {
+					mu.Lock()
+					handled++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	time.Sleep(time.Millisecond)
+	assert.NoError(t, logger.Close(context.Background()))
+	wg.Wait()
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, handled, strings.Count(string(content), "\n"), "every entry handled before Close is in the file")
+}
+
+func TestMangoLogger_CloseWithoutConstructor(t *testing.T) {
+	logger := MangoLogger{Config: &LogConfig{Out: &OutConfig{File: &FileOutputConfig{}}}}
+	assert.NoError(t, logger.Flush(context.Background()))
+	assert.NoError(t, logger.Close(context.Background()))
+}
+
+// reentrantSink logs again from WriteEntry, as a sink or an ErrorHandler reporting through the logger may
+type reentrantSink struct {
+	logger  *MangoLogger
+	closing chan struct{}
+	nested  error
+}
+
+func (s *reentrantSink) WriteEntry(log StructuredLog) error {
+	if log.Message != "closing" {
+		return nil
+	}
+	close(s.closing)
+	for closed := false; !closed; time.Sleep(time.Millisecond) {
+		s.logger.lifecycle.mu.Lock()
+		closed = s.logger.lifecycle.closed
+		s.logger.lifecycle.mu.Unlock()
+	}
+	s.nested = s.logger.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "nested", 0))
+	return nil
+}
+
+func TestMangoLogger_CloseWithReentrantLog(t *testing.T) {
+	sink := &reentrantSink{closing: make(chan struct{})}
+	logger := newSinkTestLogger(sink)
+	sink.logger = logger
+
+	closed := make(chan error)
+	go func() {
+		<-sink.closing
+		closed <- logger.Close(context.Background())
+	}()
+	assert.NoError(t, logger.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "closing", 0)))
+	select {
+	case err := <-closed:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close deadlocked on the nested entry")
+	}
+	assert.ErrorIs(t, sink.nested, ErrLoggerClosed)
+}
+
+func TestMangoLogger_CloseBoundedByContext(t *testing.T) {
+	logger := MangoLogger{Config: &LogConfig{Out: &OutConfig{File: &FileOutputConfig{}}}, lifecycle: &lifecycle{}}
+	assert.True(t, logger.lifecycle.begin()) // an entry that never ends
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, logger.Close(ctx), context.DeadlineExceeded)
+	assert.False(t, logger.lifecycle.begin())
+	logger.lifecycle.end()
+}
+
+func TestRotatingFile_Sync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f := newRotatingFile(&lumberjack.Logger{Filename: path}, &FileOutputConfig{Path: path}, nil)
+	assert.NoError(t, f.Sync(), "no file yet")
+
+	_, err := f.Write([]byte("entry\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Sync())
+	assert.NoError(t, f.Close())
+	assert.NoError(t, f.Sync(), "closed")
+}
+
+func TestRotatingFile_WriteAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f := newRotatingFile(&lumberjack.Logger{Filename: path}, &FileOutputConfig{Path: path}, nil)
+	_, err := f.Write([]byte("entry\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	assert.NoError(t, os.Remove(path))
+
+	_, err = f.Write([]byte("late\n"))
+	assert.ErrorIs(t, err, ErrLoggerClosed)
+	assert.NoError(t, f.Rotate())
+	assert.NoFileExists(t, path, "the file isn't reopened")
+}
@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"log/slog"
+	"maps"
+	"sync"
+	"testing"
+	"testing/slogtest"
+)
+
+// sliceSink keeps the entries written to it
+type sliceSink struct {
+	mu      sync.Mutex
+	entries []StructuredLog
+}
+
+func (s *sliceSink) WriteEntry(log StructuredLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, log)
+	return nil
+}
+
+// results maps the entries to the shape slogtest expects, the attributes at the top level
+func (s *sliceSink) results() []map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var results []map[string]any
+	for _, entry := range s.entries {
+		result := map[string]any{
+			slog.LevelKey:   entry.Level,
+			slog.MessageKey: entry.Message,
+		}
+		if entry.Timestamp != "" {
+			result[slog.TimeKey] = entry.Timestamp
+		}
+		maps.Copy(result, entry.Attributes)
+		results = append(results, result)
+	}
+	return results
+}
+
+func newSinkTestLogger(sink EntrySink) *MangoLogger {
+	return NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled: true,
+			File:    &FileOutputConfig{},
+			Cli:     &CliConfig{},
+			Syslog:  &SyslogConfig{},
+			Sink:    sink,
+		},
+		MangoConfig: &MangoConfig{CorrelationId: &CorrelationIdConfig{AutoGenerate: true}},
+	})
+}
+
+func TestMangoLogger_Slogtest(t *testing.T) {
+	var sink *sliceSink
+	slogtest.Run(t, func(t *testing.T) slog.Handler {
+		sink = &sliceSink{}
+		return newSinkTestLogger(sink)
+	}, func(t *testing.T) map[string]any {
+		results := sink.results()
+		if len(results) != 1 {
+			t.Fatalf("expected 1 entry, got %d", len(results))
+		}
+		return results[0]
+	})
+}
+
+func TestMangoLogger_DerivedHandlersDontShareAttrs(t *testing.T) {
+	sink := &sliceSink{}
+	parent := slog.New(newSinkTestLogger(sink)).With("a", 1)
+	parent.With("b", 2).Info("first")
+	parent.With("c", 3).Info("second")
+
+	if _, ok := sink.results()[1]["b"]; ok {
+		t.Fatalf("the second logger got the attribute of the first one: %v", sink.results()[1])
+	}
+}
@@ -0,0 +1,149 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// levelPrefixes are the line prefixes setting the level of a line, matched case insensitively
+var levelPrefixes = []struct {
+	prefix string
+	level  slog.Level
+}{
+	{"DEBUG", slog.LevelDebug},
+	{"INFO", slog.LevelInfo},
+	{"WARNING", slog.LevelWarn},
+	{"WARN", slog.LevelWarn},
+	{"ERROR", slog.LevelError},
+}
+
+// WriterOption customises the Writer returned by NewWriter
+type WriterOption func(*Writer)
+
+// WithFallback sets key to value in the context of the lines whose context doesn't have key, e.g. so strict mode passes:
+//
+//	mangolog.NewWriter(handler, slog.LevelInfo, mangolog.WithFallback(mangolog.TYPE, mangolog.BusinessType))
+func WithFallback(key ctxKey, value string) WriterOption {
+	return func(w *Writer) {
+		if _, ok := w.ctx.Value(key).(string); !ok {
+			w.ctx = context.WithValue(w.ctx, key, value)
+		}
+	}
+}
+
+// WithWriterContext logs the lines with ctx, context.Background() by default - Apply it before WithFallback
+func WithWriterContext(ctx context.Context) WriterOption {
+	return func(w *Writer) {
+		w.ctx = ctx
+	}
+}
+
+// Writer is an io.Writer logging each line written to it as an entry of its handler
+// A line starting with a level, as in "ERROR: failed" or "[warn] slow", is logged at that level without it
+type Writer struct {
+	handler slog.Handler
+	level   slog.Level
+	ctx     context.Context
+
+	mu      sync.Mutex
+	partial []byte // the end of the last write, up to its newline
+}
+
+// NewWriter returns a Writer logging the lines at level, unless they start with another one
+func NewWriter(handler slog.Handler, level slog.Level, options ...WriterOption) *Writer {
+	w := &Writer{handler: handler, level: level, ctx: context.Background()}
+	for _, option := range options {
+		option(w)
+	}
+	return w
+}
+
+// Write logs each complete line of p, keeping an incomplete last line until the next Write or Flush
+// It returns the errors of the handler, having consumed all of p regardless
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.partial = append(w.partial, p...)
+	var errs []error
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if err := w.logLine(string(w.partial[:i])); err != nil {
+			errs = append(errs, err)
+		}
+		w.partial = w.partial[i+1:]
+	}
+	w.partial = bytes.Clone(w.partial) // not holding on to a large buffer for a few bytes
+	return len(p), errors.Join(errs...)
+}
+
+// Flush logs the incomplete line left by the last Write, if any
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	line := string(w.partial)
+	w.partial = nil
+	return w.logLine(line)
+}
+
+func (w *Writer) logLine(line string) error {
+	line = strings.TrimRight(line, "\r")
+	level, message := parseLevel(line, w.level)
+	if strings.TrimSpace(message) == "" || !w.handler.Enabled(w.ctx, level) {
+		return nil
+	}
+	return w.handler.Handle(w.ctx, slog.NewRecord(time.Now(), level, message, 0))
+}
+
+// parseLevel returns the level the line starts with, as "ERROR:", "ERROR " or "[ERROR]", and the rest of the line
+func parseLevel(line string, level slog.Level) (slog.Level, string) {
+	trimmed := strings.TrimLeft(line, " \t")
+	bracketed := strings.HasPrefix(trimmed, "[")
+	if bracketed {
+		trimmed = trimmed[1:]
+	}
+	for _, prefix := range levelPrefixes {
+		if len(trimmed) < len(prefix.prefix) || !strings.EqualFold(trimmed[:len(prefix.prefix)], prefix.prefix) {
+			continue
+		}
+		rest := trimmed[len(prefix.prefix):]
+		switch {
+		case bracketed && strings.HasPrefix(rest, "]"):
+			rest = rest[1:]
+		case !bracketed && (strings.HasPrefix(rest, ":") || strings.HasPrefix(rest, " ")):
+			rest = strings.TrimPrefix(rest, ":")
+		default:
+			continue // e.g. "INFORMATION" or "[WARN" is not a level
+		}
+		return prefix.level, strings.TrimLeft(rest, " \t")
+	}
+	return level, line
+}
+
+// RedirectStdLog sends the output of the standard log package to handler, as Writer does with the lines
+// The flags and prefix of the standard logger are cleared, the entries having their own timestamp
+// It returns a function restoring the standard logger:
+//
+//	restore := mangolog.RedirectStdLog(handler, slog.LevelInfo, ctx, mangolog.WithFallback(mangolog.OPERATION, "stdlog"))
+//	defer restore()
+func RedirectStdLog(handler slog.Handler, level slog.Level, ctx context.Context, options ...WriterOption) func() {
+	writer := NewWriter(handler, level, append([]WriterOption{WithWriterContext(ctx)}, options...)...)
+	output, flags, prefix := log.Writer(), log.Flags(), log.Prefix()
+	log.SetOutput(writer)
+	log.SetFlags(0)
+	log.SetPrefix("")
+	return func() {
+		_ = writer.Flush()
+		log.SetOutput(output)
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+	}
+}
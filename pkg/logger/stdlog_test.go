@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		line    string
+		level   slog.Level
+		message string
+	}{
+		{"ERROR: disk full", slog.LevelError, "disk full"},
+		{"error disk full", slog.LevelError, "disk full"},
+		{"  [warn] slow query", slog.LevelWarn, "slow query"},
+		{"WARNING: deprecated", slog.LevelWarn, "deprecated"},
+		{"Debug: cache miss", slog.LevelDebug, "cache miss"},
+		{"INFO:", slog.LevelInfo, ""},
+		{"INFORMATION only", slog.LevelWarn, "INFORMATION only"},
+		{"[WARN slow", slog.LevelWarn, "[WARN slow"},
+		{"close error: boom", slog.LevelWarn, "close error: boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			level, message := parseLevel(tt.line, slog.LevelWarn)
+			assert.Equal(t, tt.level, level)
+			assert.Equal(t, tt.message, message)
+		})
+	}
+}
+
+func TestWriter_Lines(t *testing.T) {
+	sink := &sliceSink{}
+	handler := newSinkTestLogger(sink)
+	handler.Config.MangoConfig.Strict = true
+	w := NewWriter(handler, slog.LevelInfo,
+		WithWriterContext(context.WithValue(context.Background(), OPERATION, "from-context")),
+		WithFallback(OPERATION, "stdlog"),
+		WithFallback(TYPE, BusinessType),
+		WithFallback(APPLICATION, "checkout-api"))
+
+	n, err := fmt.Fprint(w, "first line\r\nERROR: second ")
+	assert.NoError(t, err)
+	assert.Equal(t, 26, n)
+	_, err = fmt.Fprint(w, "line\n\n   \nlast")
+	assert.NoError(t, err)
+	assert.Len(t, sink.entries, 2, "empty lines are skipped and the last one waits for its newline")
+	assert.NoError(t, w.Flush())
+	assert.NoError(t, w.Flush(), "nothing left")
+
+	assert.Len(t, sink.entries, 3)
+	assert.Equal(t, "first line", sink.entries[0].Message)
+	assert.Equal(t, slog.LevelInfo, sink.entries[0].Level)
+	assert.Equal(t, "second line", sink.entries[1].Message)
+	assert.Equal(t, slog.LevelError, sink.entries[1].Level)
+	assert.Equal(t, "last", sink.entries[2].Message)
+	assert.Equal(t, "from-context", sink.entries[0].Operation, "the fallback doesn't override the context")
+	assert.Equal(t, BusinessType, sink.entries[0].Type)
+	assert.Equal(t, "checkout-api", sink.entries[0].Application)
+}
+
+func TestWriter_HandlerErrors(t *testing.T) {
+	sink := &sliceSink{}
+	handler := newSinkTestLogger(sink)
+	handler.Config.MangoConfig.Strict = true
+	w := NewWriter(handler, slog.LevelInfo)
+
+	n, err := w.Write([]byte("one\ntwo\n"))
+	assert.Equal(t, 8, n)
+	assert.True(t, errors.Is(err, errStrictModeOn))
+	assert.Empty(t, sink.entries)
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	log.SetFlags(log.LstdFlags)
+	log.SetPrefix("app: ")
+	sink := &sliceSink{}
+	ctx := context.WithValue(context.Background(), CORRELATION_ID, "a52b0129-9d49-4f29-acbb-3575aa4442f4")
+	restore := RedirectStdLog(newSinkTestLogger(sink), slog.LevelWarn, ctx, WithFallback(OPERATION, "stdlog"))
+
+	log.Printf("close error: %v", "boom")
+	log.Print("DEBUG: details")
+	log.Print("unterminated")
+	restore()
+
+	assert.Equal(t, log.LstdFlags, log.Flags())
+	assert.Equal(t, "app: ", log.Prefix())
+	assert.Len(t, sink.entries, 3)
+	assert.Equal(t, "close error: boom", sink.entries[0].Message)
+	assert.Equal(t, slog.LevelWarn, sink.entries[0].Level)
+	assert.Equal(t, "stdlog", sink.entries[0].Operation)
+	assert.Equal(t, "a52b0129-9d49-4f29-acbb-3575aa4442f4", sink.entries[0].Correlationid)
+	assert.Equal(t, slog.LevelDebug, sink.entries[1].Level)
+	log.SetFlags(log.LstdFlags)
+	log.SetPrefix("")
+}
@@ -1,11 +1,24 @@
 package logger
 
-import "log/slog"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+)
 
 // StructuredLog is the structure of every log entry (output)
 type StructuredLog struct {
-	// Timestamp of the log entry
-	Timestamp string `json:"ts"`
+	// SchemaVersion of the entry json, see Schema
+	SchemaVersion string `json:"schemaVersion,omitempty"`
+
+	// Timestamp of the log entry, omitted for records without a time
+	Timestamp string `json:"ts,omitempty"`
 
 	// Type of the log entry. One of: Security, Business, or Performance
 	Type string `json:"type"`
@@ -20,6 +33,12 @@ type StructuredLog struct {
 	// Correlationid from the caller or self generated allowing to relate different systems around one
 	Correlationid string `json:"correlationid"`
 
+	// TraceId of the trace this entry belongs to, when TRACE_ID is present in the context
+	TraceId string `json:"traceId,omitempty"`
+
+	// SpanId of the span this entry belongs to, when SPAN_ID is present in the context
+	SpanId string `json:"spanId,omitempty"`
+
 	// LogId is a unique identifier for each log entry - Helps in referring to logs when searching
 	LogId string `json:"logId"`
 
@@ -31,13 +50,217 @@ type StructuredLog struct {
 
 	// Attributes set with slog or on the logger
 	Attributes map[string]interface{} `json:"attributes"`
+
+	// Service metadata, under "resource" or at the top level as configured in MangoConfig.Service
+	Service *ServiceMetadata `json:"resource,omitempty"`
+
+	// Truncated is set when a limit of MangoConfig.Limits cut the entry
+	Truncated bool `json:"truncated,omitempty"`
+
+	// OriginalSizes of what was cut
+	OriginalSizes *OriginalSizes `json:"originalSizes,omitempty"`
+
+	// Violations of strict mode, when emitted anyway with the emit or fallback failure policy
+	Violations []string `json:"violations,omitempty"`
+
+	// AuditSeq is the sequence number of a sealed Security entry in the audit chain
+	AuditSeq uint64 `json:"auditSeq,omitempty"`
+
+	// AuditPrev is the AuditMac of the previous sealed entry, empty when the chain (re)starts
+	AuditPrev string `json:"auditPrev,omitempty"`
+
+	// AuditMac is the HMAC of the entry, including AuditSeq and AuditPrev - Must remain the last field
+	AuditMac string `json:"auditMac,omitempty"`
+
+	// order of the Attributes keys as logged, nil for entries not built by a MangoLogger
+	order attrOrder
+
+	// pc of the logging call, 0 when unknown
+	pc uintptr
+
+	// serviceTopLevel writes the Service fields at the top level, before logId
+	serviceTopLevel bool
+}
+
+// OriginalSizes of the parts of a truncated entry
+type OriginalSizes struct {
+	// Message length in bytes
+	Message int `json:"message,omitempty"`
+
+	// Attributes lengths in bytes of the cut strings, by path, e.g. "req.body"
+	Attributes map[string]int `json:"attributes,omitempty"`
+
+	// AttributeCount before attributes were dropped
+	AttributeCount int `json:"attributeCount,omitempty"`
+
+	// Entry size in bytes of the json entry
+	Entry int `json:"entry,omitempty"`
+}
+
+// attrOrder is the order of the keys of an attributes map, with the order of the nested groups
+type attrOrder []attrKey
+
+type attrKey struct {
+	key   string
+	group attrOrder
+}
+
+// plainLog has the fields of StructuredLog without its MarshalJSON
+type plainLog StructuredLog
+
+// MarshalJSON writes the attributes in the order they were logged, handler attributes first
+// Keys added to Attributes afterwards follow in alphabetical order
+func (log StructuredLog) MarshalJSON() ([]byte, error) {
+	attributes, ordered := log.Attributes, log.order != nil && log.Attributes != nil
+	if ordered {
+		log.Attributes = nil
+	}
+	service, topLevel := log.Service, log.serviceTopLevel && log.Service != nil
+	if topLevel {
+		log.Service = nil
+	}
+	content, err := json.Marshal(plainLog(log))
+	if err != nil || (!ordered && !topLevel) {
+		return content, err
+	}
+
+	var b bytes.Buffer
+	if ordered {
+		// attributes is the last field that can hold a null, the following ones being strings, numbers or the resource
+		placeholder := []byte(`"attributes":null`)
+		i := bytes.LastIndex(content, placeholder)
+		if i < 0 {
+			return nil, errors.New("attributes missing from the marshalled entry")
+		}
+		b.Write(content[:i])
+		b.WriteString(`"attributes":`)
+		if err := writeOrdered(&b, attributes, log.order); err != nil {
+			return nil, err
+		}
+		b.Write(content[i+len(placeholder):])
+		content = bytes.Clone(b.Bytes())
+	}
+	if topLevel {
+		// the fields before logId are strings, where a quote is escaped, so the first match is the field
+		fields, err := json.Marshal(service)
+		if err != nil {
+			return nil, err
+		}
+		i := bytes.Index(content, []byte(`"logId":`))
+		if i < 0 || len(fields) <= 2 {
+			return content, nil
+		}
+		b.Reset()
+		b.Write(content[:i])
+		b.Write(fields[1 : len(fields)-1])
+		b.WriteByte(',')
+		b.Write(content[i:])
+		content = b.Bytes()
+	}
+	return content, nil
+}
+
+// writeOrdered writes attributes as a json object, the keys of order first
+func writeOrdered(b *bytes.Buffer, attributes map[string]interface{}, order attrOrder) error {
+	written := make(map[string]bool, len(attributes))
+	b.WriteByte('{')
+	write := func(key string, group attrOrder) error {
+		value, ok := attributes[key]
+		if !ok || written[key] {
+			return nil
+		}
+		if len(written) > 0 {
+			b.WriteByte(',')
+		}
+		written[key] = true
+		name, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		if nested, ok := value.(map[string]interface{}); ok {
+			return writeOrdered(b, nested, group)
+		}
+		content, err := json.Marshal(value)
+		if err != nil {
+			// e.g. a func or a channel logged with slog.Any, the entry is still written
+			content, _ = json.Marshal(fmt.Sprint(value))
+		}
+		b.Write(content)
+		return nil
+	}
+	for _, key := range order {
+		if err := write(key.key, key.group); err != nil {
+			return err
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(attributes)) {
+		if err := write(key, nil); err != nil {
+			return err
+		}
+	}
+	b.WriteByte('}')
+	return nil
 }
 
 // Helper function to convert []slog.Attr to a map[string]interface{}
+// Groups become nested maps, LogValuer values are resolved and durations are in nanoseconds
 func ToMap(attrs []slog.Attr) map[string]interface{} {
+	return toMap(attrs, DurationFormatNanoseconds)
+}
+
+func toMap(attrs []slog.Attr, durationFormat string) map[string]interface{} {
 	result := make(map[string]interface{})
 	for _, attr := range attrs {
-		result[attr.Key] = attr.Value.Any()
+		value := attr.Value.Resolve()
+		if value.Kind() == slog.KindGroup {
+			result[attr.Key] = toMap(value.Group(), durationFormat)
+		} else {
+			result[attr.Key] = toJsonValue(value, durationFormat)
+		}
 	}
 	return result
 }
+
+// toJsonValue converts a resolved, non group, value to what encodes as json the way we want it
+func toJsonValue(value slog.Value, durationFormat string) interface{} {
+	switch value.Kind() {
+	case slog.KindDuration:
+		if durationFormat == DurationFormatString {
+			return value.Duration().String()
+		}
+		return value.Duration().Nanoseconds()
+	case slog.KindTime:
+		return value.Time().Format(RFC3339NanoMC)
+	case slog.KindFloat64:
+		// json has no NaN nor infinity, they would fail the whole entry
+		if f := value.Float64(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		return value.Float64()
+	case slog.KindAny:
+		// errors usually have no exported field and would encode as {}
+		if err, ok := value.Any().(error); ok {
+			if _, ok := err.(json.Marshaler); !ok {
+				return err.Error()
+			}
+		}
+		return value.Any()
+	default:
+		return value.Any()
+	}
+}
+
+// orderOf returns the order of the keys of merged attributes, as mergeAttrs leaves them
+func orderOf(attrs []slog.Attr) attrOrder {
+	order := make(attrOrder, 0, len(attrs))
+	for _, attr := range attrs {
+		key := attrKey{key: attr.Key}
+		if value := attr.Value.Resolve(); value.Kind() == slog.KindGroup {
+			key.group = orderOf(value.Group())
+		}
+		order = append(order, key)
+	}
+	return order
+}
@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func marshalledAttributes(t *testing.T, log StructuredLog) string {
+	content, err := json.Marshal(log)
+	assert.NoError(t, err)
+	var fields map[string]json.RawMessage
+	assert.NoError(t, json.Unmarshal(content, &fields))
+	return string(fields["attributes"])
+}
+
+func TestStructuredLog_MarshalJSONKeepsOrder(t *testing.T) {
+	sink := &sliceSink{}
+	logger := slog.New(newSinkTestLogger(sink)).With("zone", "eu", "app", "cart")
+	for range 5 {
+		logger.Info("ordered", "user", "u1", slog.Group("req", "path", "/b", "method", "GET"), "app", "basket", "count", 2)
+	}
+
+	for _, entry := range sink.entries {
+		assert.Equal(t, `{"zone":"eu","app":"basket","user":"u1","req":{"path":"/b","method":"GET"},"count":2}`, marshalledAttributes(t, entry))
+	}
+}
+
+func TestStructuredLog_MarshalJSONAddedKeys(t *testing.T) {
+	sink := &sliceSink{}
+	slog.New(newSinkTestLogger(sink)).Info("added", "b", 1, "a", 2)
+
+	entry := sink.entries[0]
+	entry.Attributes["d"] = 3
+	entry.Attributes["c"] = 4
+	delete(entry.Attributes, "b")
+	assert.Equal(t, `{"a":2,"c":4,"d":3}`, marshalledAttributes(t, entry))
+}
+
+func TestStructuredLog_MarshalJSONFieldOrder(t *testing.T) {
+	log := StructuredLog{
+		Message:    map[string]interface{}{"attributes": nil},
+		Attributes: map[string]interface{}{"b": 1, "a": 2},
+		order:      attrOrder{{key: "b"}, {key: "a"}},
+		Violations: []string{`"attributes":null`},
+		AuditSeq:   1,
+		AuditMac:   "mac",
+	}
+
+	content, err := json.Marshal(log)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"message":{"attributes":null},"attributes":{"b":1,"a":2},"violations":["\"attributes\":null"]`)
+	assert.True(t, strings.HasSuffix(string(content), `,"auditMac":"mac"}`))
+
+	log.order = nil
+	content, err = json.Marshal(log)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"attributes":{"a":2,"b":1}`)
+
+	log.Attributes = nil
+	content, err = json.Marshal(log)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"attributes":null`)
+}
+
+func TestStructuredLog_Kinds(t *testing.T) {
+	at := time.Date(2025, 1, 15, 9, 53, 34, 717000000, time.UTC)
+	attrs := []slog.Attr{
+		slog.String("string", "s"),
+		slog.Int64("int", -1),
+		slog.Uint64("uint", 1),
+		slog.Float64("float", 1.5),
+		slog.Float64("nan", math.NaN()),
+		slog.Float64("inf", math.Inf(-1)),
+		slog.Bool("bool", true),
+		slog.Duration("duration", 1500*time.Millisecond),
+		slog.Time("time", at),
+		slog.Any("error", errors.New("boom")),
+		slog.Any("func", func() {}),
+		slog.Group("group", slog.Duration("nested", time.Second)),
+	}
+
+	log := StructuredLog{Attributes: toMap(attrs, DurationFormatNanoseconds), order: orderOf(attrs)}
+	encoded := marshalledAttributes(t, log)
+	assert.True(t, strings.HasPrefix(encoded, `{"string":"s","int":-1,"uint":1,"float":1.5,"nan":"NaN","inf":"-Inf","bool":true,`+
+		`"duration":1500000000,"time":"2025-01-15T09:53:34.717Z","error":"boom","func":"0x`), encoded)
+	assert.True(t, strings.HasSuffix(encoded, `","group":{"nested":1000000000}}`), encoded)
+
+	asStrings := toMap(attrs, DurationFormatString)
+	assert.Equal(t, "1.5s", asStrings["duration"])
+	assert.Equal(t, map[string]interface{}{"nested": "1s"}, asStrings["group"])
+}
+
+func TestMangoLogger_DurationFormat(t *testing.T) {
+	sink := &sliceSink{}
+	handler := newSinkTestLogger(sink)
+	handler.Config.MangoConfig.DurationFormat = DurationFormatString
+	slog.New(handler).Info("took", "elapsed", 250*time.Millisecond)
+
+	assert.Equal(t, `{"elapsed":"250ms"}`, marshalledAttributes(t, sink.entries[0]))
+	assert.Equal(t, DurationFormatNanoseconds, MangoLogger{}.durationFormat())
+}
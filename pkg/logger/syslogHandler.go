@@ -8,6 +8,9 @@ import (
 	"log/syslog"
 )
 
+// newSyslogWriter is overridden in tests to fail without a syslog daemon
+var newSyslogWriter = syslog.New
+
 func (sl MangoLogger) handleSyslogOutput(log *StructuredLog, jsonOut []byte) error {
 
 	var severity = syslog.LOG_EMERG
@@ -21,59 +24,61 @@ func (sl MangoLogger) handleSyslogOutput(log *StructuredLog, jsonOut []byte) err
 	case slog.LevelError:
 		severity = syslog.LOG_ERR
 	default:
-		fmt.Println("Record level not one of: debug, info, warn or error")
+		sl.diagnostic("Record level not one of: debug, info, warn or error\n")
 	}
 	if severity == syslog.LOG_EMERG {
 		return fmt.Errorf("record level not one of: debug, info, warn or error")
 	}
 
+	// the priority is local, as the configuration is shared by the concurrent calls
+	var priority syslog.Priority
 	switch sl.Config.Out.Syslog.Facility {
 	case SyslogFacilityKern:
-		sl.Config.Out.Syslog.priority = syslog.LOG_KERN | severity
+		priority = syslog.LOG_KERN | severity
 	case SyslogFacilityUser:
-		sl.Config.Out.Syslog.priority = syslog.LOG_USER | severity
+		priority = syslog.LOG_USER | severity
 	case SyslogFacilityMail:
-		sl.Config.Out.Syslog.priority = syslog.LOG_MAIL | severity
+		priority = syslog.LOG_MAIL | severity
 	case SyslogFacilityDaemon:
-		sl.Config.Out.Syslog.priority = syslog.LOG_DAEMON | severity
+		priority = syslog.LOG_DAEMON | severity
 	case SyslogFacilityAuth:
-		sl.Config.Out.Syslog.priority = syslog.LOG_AUTH | severity
+		priority = syslog.LOG_AUTH | severity
 	case SyslogFacilitySyslog:
-		sl.Config.Out.Syslog.priority = syslog.LOG_SYSLOG | severity
+		priority = syslog.LOG_SYSLOG | severity
 	case SyslogFacilityNews:
-		sl.Config.Out.Syslog.priority = syslog.LOG_NEWS | severity
+		priority = syslog.LOG_NEWS | severity
 	case SyslogFacilityUucp:
-		sl.Config.Out.Syslog.priority = syslog.LOG_UUCP | severity
+		priority = syslog.LOG_UUCP | severity
 	case SyslogFacilityCron:
-		sl.Config.Out.Syslog.priority = syslog.LOG_CRON | severity
+		priority = syslog.LOG_CRON | severity
 	case SyslogFacilityAuthpriv:
-		sl.Config.Out.Syslog.priority = syslog.LOG_AUTHPRIV | severity
+		priority = syslog.LOG_AUTHPRIV | severity
 	case SyslogFacilityFtp:
-		sl.Config.Out.Syslog.priority = syslog.LOG_FTP | severity
+		priority = syslog.LOG_FTP | severity
 	case SyslogFacilityLocal0:
-		sl.Config.Out.Syslog.priority = syslog.LOG_LOCAL0 | severity
+		priority = syslog.LOG_LOCAL0 | severity
 	case SyslogFacilityLocal1:
-		sl.Config.Out.Syslog.priority = syslog.LOG_LOCAL1 | severity
+		priority = syslog.LOG_LOCAL1 | severity
 	case SyslogFacilityLocal2:
-		sl.Config.Out.Syslog.priority = syslog.LOG_LOCAL2 | severity
+		priority = syslog.LOG_LOCAL2 | severity
 	case SyslogFacilityLocal3:
-		sl.Config.Out.Syslog.priority = syslog.LOG_LOCAL3 | severity
+		priority = syslog.LOG_LOCAL3 | severity
 	case SyslogFacilityLocal4:
-		sl.Config.Out.Syslog.priority = syslog.LOG_LOCAL4 | severity
+		priority = syslog.LOG_LOCAL4 | severity
 	case SyslogFacilityLocal5:
-		sl.Config.Out.Syslog.priority = syslog.LOG_LOCAL5 | severity
+		priority = syslog.LOG_LOCAL5 | severity
 	case SyslogFacilityLocal6:
-		sl.Config.Out.Syslog.priority = syslog.LOG_LOCAL6 | severity
+		priority = syslog.LOG_LOCAL6 | severity
 	case SyslogFacilityLocal7:
-		sl.Config.Out.Syslog.priority = syslog.LOG_LOCAL7 | severity
+		priority = syslog.LOG_LOCAL7 | severity
 	default:
-		fmt.Println("Facility level not valid")
+		sl.diagnostic("Facility level not valid\n")
 		return fmt.Errorf("facility level not valid")
 	}
 
-	syslogWriter, err := syslog.New(syslog.Priority(sl.Config.Out.Syslog.priority), log.Application)
+	syslogWriter, err := newSyslogWriter(priority, log.Application)
 	if err != nil {
-		fmt.Println("Error writing to syslog")
+		sl.diagnostic("Error writing to syslog. %s\n", err.Error())
 		return fmt.Errorf("error writing to syslog: %w", err)
 	}
 
@@ -3,6 +3,7 @@
 package logger
 
 import (
+	"bytes"
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"log/slog"
@@ -126,3 +127,27 @@ func TestHandleSyslogOutput_SyslogWriterCloseError(t *testing.T) {
 	err := logger.handleSyslogOutput(log, []byte(`{"msg":"close test"}`))
 	assert.NoError(t, err)
 }
+
+func TestHandleSyslogOutput_FailingDiagnosticsBounded(t *testing.T) {
+	orig := newSyslogWriter
+	newSyslogWriter = func(syslog.Priority, string) (*syslog.Writer, error) {
+		return nil, errors.New("no syslog daemon")
+	}
+	defer func() { newSyslogWriter = orig }()
+
+	var out bytes.Buffer
+	logger := createTestLogger(SyslogFacilityUser)
+	logger.failures = newFailureHandler(nil)
+	logger.failures.diagnostics.out = &out
+	for range 100 {
+		err := logger.handleSyslogOutput(&StructuredLog{Level: slog.LevelInfo, Application: "testApp"}, []byte(`{"msg":"lost"}`))
+		assert.ErrorContains(t, err, "no syslog daemon")
+	}
+	invalid := createTestLogger("invalid_facility")
+	invalid.failures = logger.failures
+	for range 100 {
+		_ = logger.handleSyslogOutput(&StructuredLog{Level: slog.Level(999)}, nil)
+		_ = invalid.handleSyslogOutput(&StructuredLog{Level: slog.LevelInfo}, nil)
+	}
+	assert.Equal(t, "Error writing to syslog. no syslog daemon\nRecord level not one of: debug, info, warn or error\nFacility level not valid\n", out.String())
+}
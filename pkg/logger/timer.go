@@ -0,0 +1,184 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Attributes of the Performance entries logged by the timers
+const (
+	DurationMsKey      = "durationMs"
+	OutcomeKey         = "outcome"
+	SloMsKey           = "sloMs"
+	PhasesKey          = "phases"
+	ParentOperationKey = "parentOperation"
+)
+
+// Outcomes of a timed operation
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// DoneFunc stops a timer and logs its Performance entry, returning the measured duration
+// The args are slog style key/value pairs or slog.Attr. A non nil error sets the outcome to failure and is logged
+// under "error", Outcome overrides the outcome. Only the first call logs, later ones return the same duration
+type DoneFunc func(args ...any) time.Duration
+
+// TimerOption customises a timer
+type TimerOption func(*timer)
+
+// WithLogger logs the entry with logger instead of slog.Default()
+func WithLogger(logger *slog.Logger) TimerOption {
+	return func(t *timer) {
+		t.logger = logger
+	}
+}
+
+// WithSLO upgrades the entry to WARN when the duration exceeds slo
+func WithSLO(slo time.Duration) TimerOption {
+	return func(t *timer) {
+		t.slo = slo
+	}
+}
+
+// WithErrorThreshold upgrades the entry to ERROR when the duration exceeds threshold
+func WithErrorThreshold(threshold time.Duration) TimerOption {
+	return func(t *timer) {
+		t.errorThreshold = threshold
+	}
+}
+
+// WithNow replaces time.Now, for tests
+func WithNow(now func() time.Time) TimerOption {
+	return func(t *timer) {
+		t.now = now
+	}
+}
+
+// Outcome sets the outcome of the timed operation when passed to a DoneFunc, e.g. "timeout"
+func Outcome(outcome string) slog.Attr {
+	return slog.String(OutcomeKey, outcome)
+}
+
+type timerCtxKey struct{}
+
+// timer of one operation, collecting the durations of the timers nested in it
+type timer struct {
+	ctx            context.Context
+	operation      string
+	parent         *timer
+	logger         *slog.Logger
+	slo            time.Duration
+	errorThreshold time.Duration
+	now            func() time.Time
+	start          time.Time
+
+	once     sync.Once
+	duration time.Duration
+
+	mu     sync.Mutex
+	phases map[string]time.Duration
+}
+
+// StartTimer starts timing operation, logging a Performance entry when the returned DoneFunc is called:
+//
+//	done := mangolog.StartTimer(ctx, "checkout", mangolog.WithSLO(500*time.Millisecond))
+//	defer func() { done(err) }()
+//
+// When ctx comes from StartTimerContext the timing is also reported as a phase of that timer
+func StartTimer(ctx context.Context, operation string, options ...TimerOption) DoneFunc {
+	_, done := StartTimerContext(ctx, operation, options...)
+	return done
+}
+
+// StartTimerContext is StartTimer returning a context carrying the timer
+// Timers started from the returned context are nested: their durations are reported under "phases" in this timer's entry
+func StartTimerContext(ctx context.Context, operation string, options ...TimerOption) (context.Context, DoneFunc) {
+	t := &timer{
+		ctx:       ctx,
+		operation: operation,
+		now:       time.Now,
+	}
+	t.parent, _ = ctx.Value(timerCtxKey{}).(*timer)
+	for _, option := range options {
+		option(t)
+	}
+	t.start = t.now()
+	return context.WithValue(ctx, timerCtxKey{}, t), t.done
+}
+
+func (t *timer) done(args ...any) time.Duration {
+	t.once.Do(func() {
+		t.duration = t.now().Sub(t.start)
+		if t.parent != nil {
+			t.parent.addPhase(t.operation, t.duration)
+		}
+		t.log(args)
+	})
+	return t.duration
+}
+
+func (t *timer) addPhase(operation string, duration time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.phases == nil {
+		t.phases = map[string]time.Duration{}
+	}
+	t.phases[operation] += duration // a phase run several times reports the total
+}
+
+func (t *timer) log(args []any) {
+	logger := t.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	level := slog.LevelInfo
+	if t.slo > 0 && t.duration > t.slo {
+		level = slog.LevelWarn
+	}
+	if t.errorThreshold > 0 && t.duration > t.errorThreshold {
+		level = slog.LevelError
+	}
+
+	attrs := []any{slog.Float64(DurationMsKey, milliseconds(t.duration)), Outcome(OutcomeSuccess)}
+	if t.slo > 0 {
+		attrs = append(attrs, slog.Float64(SloMsKey, milliseconds(t.slo)))
+	}
+	if t.parent != nil {
+		attrs = append(attrs, slog.String(ParentOperationKey, t.parent.operation))
+	}
+	t.mu.Lock()
+	if len(t.phases) > 0 {
+		phases := make(map[string]interface{}, len(t.phases))
+		for operation, duration := range t.phases {
+			phases[operation] = milliseconds(duration)
+		}
+		attrs = append(attrs, slog.Any(PhasesKey, phases))
+	}
+	t.mu.Unlock()
+
+	for _, arg := range args {
+		switch value := arg.(type) {
+		case nil:
+			// a nil error, as in done(err)
+		case error:
+			attrs = append(attrs, Outcome(OutcomeFailure), slog.String("error", value.Error()))
+		default:
+			attrs = append(attrs, value)
+		}
+	}
+
+	ctx := context.WithValue(t.ctx, TYPE, PerformanceType)
+	ctx = context.WithValue(ctx, OPERATION, t.operation)
+	logger.Log(ctx, level, fmt.Sprintf("%s took %s", t.operation, t.duration.Round(time.Microsecond)), attrs...)
+}
+
+// milliseconds with microsecond precision
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,150 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingHandler keeps the records and the contexts they were logged with
+type recordingHandler struct {
+	records  []slog.Record
+	contexts []context.Context
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordingHandler) Handle(ctx context.Context, record slog.Record) error {
+	h.records = append(h.records, record)
+	h.contexts = append(h.contexts, ctx)
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *recordingHandler) WithGroup(string) slog.Handler { return h }
+
+func (h *recordingHandler) attrs(i int) map[string]interface{} {
+	return ToMap(getAllAttrs(h.records[i]))
+}
+
+// steppingClock advances by step on every call
+func steppingClock(step time.Duration) func() time.Time {
+	now := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+}
+
+func TestStartTimer_LogsPerformanceEntry(t *testing.T) {
+	h := &recordingHandler{}
+	ctx := context.WithValue(context.Background(), TYPE, BusinessType)
+	done := StartTimer(ctx, "checkout", WithLogger(slog.New(h)), WithNow(steppingClock(1500*time.Microsecond)))
+
+	assert.Equal(t, 1500*time.Microsecond, done(slog.Int("items", 3), "country", "IE"))
+	assert.Equal(t, 1500*time.Microsecond, done(), "only the first call logs")
+
+	assert.Len(t, h.records, 1)
+	assert.Equal(t, slog.LevelInfo, h.records[0].Level)
+	assert.Equal(t, "checkout took 1.5ms", h.records[0].Message)
+	assert.Equal(t, PerformanceType, h.contexts[0].Value(TYPE))
+	assert.Equal(t, "checkout", h.contexts[0].Value(OPERATION))
+	attrs := h.attrs(0)
+	assert.Equal(t, 1.5, attrs[DurationMsKey])
+	assert.Equal(t, OutcomeSuccess, attrs[OutcomeKey])
+	assert.Equal(t, int64(3), attrs["items"])
+	assert.Equal(t, "IE", attrs["country"])
+	assert.NotContains(t, attrs, SloMsKey)
+}
+
+func TestStartTimer_Outcome(t *testing.T) {
+	h := &recordingHandler{}
+	logger := WithLogger(slog.New(h))
+
+	var err error
+	StartTimer(context.Background(), "ok", logger)(err)
+	StartTimer(context.Background(), "failed", logger)(errors.New("card declined"))
+	StartTimer(context.Background(), "custom", logger)(Outcome("timeout"))
+
+	assert.Equal(t, OutcomeSuccess, h.attrs(0)[OutcomeKey])
+	assert.NotContains(t, h.attrs(0), "error")
+	assert.Equal(t, OutcomeFailure, h.attrs(1)[OutcomeKey])
+	assert.Equal(t, "card declined", h.attrs(1)["error"])
+	assert.Equal(t, "timeout", h.attrs(2)[OutcomeKey])
+}
+
+func TestStartTimer_Thresholds(t *testing.T) {
+	tests := []struct {
+		name  string
+		step  time.Duration
+		level slog.Level
+	}{
+		{"within slo", 100 * time.Millisecond, slog.LevelInfo},
+		{"at slo", 200 * time.Millisecond, slog.LevelInfo},
+		{"over slo", 300 * time.Millisecond, slog.LevelWarn},
+		{"over error threshold", time.Second, slog.LevelError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &recordingHandler{}
+			StartTimer(context.Background(), "search", WithLogger(slog.New(h)), WithNow(steppingClock(tt.step)),
+				WithSLO(200*time.Millisecond), WithErrorThreshold(500*time.Millisecond))()
+			assert.Equal(t, tt.level, h.records[0].Level)
+			assert.Equal(t, 200.0, h.attrs(0)[SloMsKey])
+		})
+	}
+}
+
+func TestStartTimerContext_Phases(t *testing.T) {
+	h := &recordingHandler{}
+	logger := WithLogger(slog.New(h))
+	clock := WithNow(steppingClock(10 * time.Millisecond))
+
+	ctx, done := StartTimerContext(context.Background(), "checkout", logger, clock)
+	StartTimer(ctx, "load-cart", logger, clock)()
+	paymentCtx, paymentDone := StartTimerContext(ctx, "payment", logger, clock)
+	StartTimer(paymentCtx, "fraud-check", logger, clock)()
+	paymentDone()
+	StartTimer(ctx, "load-cart", logger, clock)()
+	done()
+
+	assert.Len(t, h.records, 5)
+	assert.Equal(t, "load-cart", h.contexts[0].Value(OPERATION))
+	assert.Equal(t, "checkout", h.attrs(0)[ParentOperationKey])
+	assert.Equal(t, "payment", h.attrs(1)[ParentOperationKey])
+	assert.Equal(t, map[string]interface{}{"fraud-check": 10.0}, h.attrs(2)[PhasesKey])
+
+	checkout := h.attrs(4)
+	assert.Equal(t, "checkout", h.contexts[4].Value(OPERATION))
+	assert.NotContains(t, checkout, ParentOperationKey)
+	assert.Equal(t, map[string]interface{}{"load-cart": 20.0, "payment": 30.0}, checkout[PhasesKey])
+	assert.Equal(t, 90.0, checkout[DurationMsKey])
+}
+
+func TestStartTimer_MangoLogger(t *testing.T) {
+	dir := t.TempDir()
+	mango := NewMangoLogger(&LogConfig{
+		Out: &OutConfig{
+			Enabled: true,
+			File:    &FileOutputConfig{Enabled: true, Path: filepath.Join(dir, "perf.log")},
+			Cli:     &CliConfig{},
+			Syslog:  &SyslogConfig{},
+		},
+		MangoConfig: &MangoConfig{CorrelationId: &CorrelationIdConfig{AutoGenerate: true}},
+	})
+	ctx := context.WithValue(context.Background(), APPLICATION, "checkout-api")
+	StartTimer(ctx, "checkout", WithLogger(slog.New(mango)), WithSLO(time.Nanosecond))()
+
+	line := readLines(t, filepath.Join(dir, "perf.log"))
+	assert.True(t, strings.HasSuffix(line, "\n"))
+	assert.Contains(t, line, `"type":"Performance","application":"checkout-api","operation":"checkout"`)
+	assert.Contains(t, line, `"level":"WARN"`)
+	assert.Contains(t, line, `"outcome":"success"`)
+}
@@ -21,6 +21,15 @@ const (
 	OPERATION      ctxKey = "operation"
 )
 
+// Optional trace context fields, carried through to the outputs when present in the Context
+const (
+	// TRACE_ID is the W3C trace-id (32 lowercase hex characters) of the current trace
+	TRACE_ID ctxKey = "traceid"
+
+	// SPAN_ID is the W3C parent-id (16 lowercase hex characters) of the current span
+	SPAN_ID ctxKey = "spanid"
+)
+
 // ALLOWED_TYPES are the allowed values for TYPE
 var ALLOWED_TYPES = []string{
 	BusinessType,
@@ -29,6 +38,7 @@ var ALLOWED_TYPES = []string{
 }
 
 // REQUIRED_FIELDS are the fields checked against when MangoConfig.Strict is set
+// Read by every call of every logger, only change it before logging
 var REQUIRED_FIELDS = []ctxKey{
 	TYPE,
 	APPLICATION,